@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/audit"
+)
+
+// runAudit lists what was written to the audit file, oldest first:
+//
+//	audit [-url u] [-client id] [-limit n] [-json]
+//
+// Only file sinks can be queried; entries sent to stdout live wherever the process's output went.
+func runAudit(cfg *config.Config, args []string) error {
+	var file *config.AuditFileSink
+	for _, sink := range cfg.Audit.Sinks {
+		if sink.File != nil {
+			file = sink.File
+			break
+		}
+	}
+	if file == nil {
+		return errors.New("no audit file sink is configured; add one to audit.sinks")
+	}
+
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	url := fs.String("url", "", "Only show changes to this post")
+	client := fs.String("client", "", "Only show requests from this client_id")
+	limit := fs.Int("limit", 0, "Only show the most recent entries (default: all)")
+	asJson := fs.Bool("json", false, "Print the entries as JSON lines rather than a table")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+
+	entries, err := audit.ReadFile(file, audit.Filter{Url: *url, ClientId: *client})
+	if err != nil {
+		return err
+	}
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[len(entries)-*limit:]
+	}
+
+	if *asJson {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tREQUEST\tME\tCLIENT\tADDRESS\tACTION\tURL\tSTATUS\tCHANGES")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", e.Time.Format(time.RFC3339), orDash(e.RequestId), orDash(e.Me), orDash(e.ClientId), orDash(e.RemoteAddr), e.Action, orDash(e.Url), e.Status, formatChanges(e.Changes))
+	}
+
+	return w.Flush()
+}
+
+// formatChanges summarizes an update as i.e. "replace=content add=category".
+func formatChanges(c *audit.Changes) string {
+	if c.Empty() {
+		return "-"
+	}
+
+	var parts []string
+	for _, p := range []struct {
+		op    string
+		names []string
+	}{{"replace", c.Replace}, {"add", c.Add}, {"delete", c.Delete}} {
+		if len(p.names) > 0 {
+			parts = append(parts, p.op+"="+strings.Join(p.names, ","))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/jobs"
+)
+
+// runJobs inspects the persistent job queue:
+//
+//	jobs list         show queued and dead jobs
+//	jobs retry <id>   move a dead job back to the queue ("all" retries every dead job)
+//
+// A running server picks up retried jobs within a minute.
+func runJobs(cfg *config.Config, args []string) error {
+	if cfg.Jobs.Path == "" {
+		return errors.New("jobs.path is not set; the job queue only exists in the server's memory")
+	}
+
+	runner, err := jobs.NewRunner(&cfg.Jobs)
+	if err != nil {
+		return err
+	}
+
+	sub := "list"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	switch sub {
+	case "list":
+		return listJobs(runner)
+	case "retry":
+		if len(args) != 2 {
+			return errors.New("usage: jobs retry <id|all>")
+		}
+		return retryJobs(runner, args[1])
+	default:
+		return fmt.Errorf("unknown jobs command %q", sub)
+	}
+}
+
+func listJobs(runner *jobs.Runner) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tID\tKIND\tATTEMPTS\tNEXT RUN\tLAST ERROR")
+
+	for _, job := range runner.Pending() {
+		fmt.Fprintf(w, "queued\t%s\t%s\t%d\t%s\t%s\n", job.Id, job.Kind, job.Attempts, job.NotBefore.Format(time.RFC3339), job.LastError)
+	}
+	for _, job := range runner.Dead() {
+		fmt.Fprintf(w, "dead\t%s\t%s\t%d\t-\t%s\n", job.Id, job.Kind, job.Attempts, job.LastError)
+	}
+
+	return w.Flush()
+}
+
+func retryJobs(runner *jobs.Runner, id string) error {
+	ids := []string{id}
+	if id == "all" {
+		ids = nil
+		for _, job := range runner.Dead() {
+			ids = append(ids, job.Id)
+		}
+	}
+
+	for _, id := range ids {
+		if err := runner.Retry(id); err != nil {
+			return err
+		}
+		fmt.Printf("queued %s for retry\n", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/indieauth"
+)
+
+// runPassword reads a password from the first line of standard input and prints its hash for the
+// indieauth.password_hash setting:
+//
+//	read -rs pw && echo "$pw" | scribble password
+func runPassword(cfg *config.Config, args []string) error {
+	fmt.Fprintln(os.Stderr, "password (read from standard input):")
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("could not read password: %w", err)
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return errors.New("the password must not be empty")
+	}
+
+	hash, err := indieauth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(hash)
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/storage/content"
+	contentfactory "github.com/indieinfra/scribble/storage/content/factory"
+	"github.com/indieinfra/scribble/storage/search"
+	searchfactory "github.com/indieinfra/scribble/storage/search/factory"
+)
+
+func runReindex(cfg *config.Config, args []string) error {
+	idx, err := searchfactory.Create(&cfg.Search)
+	if err != nil {
+		return err
+	}
+	if idx == nil {
+		return errors.New("search is disabled in the configuration")
+	}
+	if embedded, ok := idx.(*search.EmbeddedIndex); ok && !embedded.Persistent() {
+		return errors.New("search.embedded.path must be set to persist a rebuilt index")
+	}
+
+	store, err := contentfactory.Create(&cfg.Content)
+	if err != nil {
+		return err
+	}
+	defer cleanupContentStore(store)
+
+	lister, ok := store.(content.Lister)
+	if !ok {
+		return fmt.Errorf("content strategy %q does not support listing", cfg.Content.Strategy)
+	}
+
+	n, err := search.Rebuild(context.Background(), idx, lister)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("indexed %d documents", n)
+	return nil
+}
+
+func cleanupContentStore(store content.ContentStore) {
+	if gitStore, ok := store.(*content.GitContentStore); ok {
+		if err := gitStore.Cleanup(); err != nil {
+			log.Printf("error during cleanup: %v", err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"strings"
@@ -10,11 +11,23 @@ import (
 	"github.com/indieinfra/scribble/server"
 )
 
+// commands are the subcommands accepted after the flags. Running without a command serves micropub.
+var commands = map[string]func(cfg *config.Config, args []string) error{
+	"serve":    runServe,
+	"reindex":  runReindex,
+	"jobs":     runJobs,
+	"password": runPassword,
+	"token":    runToken,
+	"audit":    runAudit,
+}
+
 func main() {
 	log.SetPrefix("scribble: ")
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile | log.Lmsgprefix)
 
 	configFile := flag.String("config", "config.yml", "Path to the configuration file (i.e., /etc/scribble.yaml)")
+	siteName := flag.String("site", "", "The site to run the command for when several are configured, by host and path prefix (i.e., example.org/blog)")
+	flag.Usage = usage
 	flag.Parse()
 
 	if len(strings.Trim(*configFile, " ")) == 0 {
@@ -22,6 +35,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	name := "serve"
+	if flag.NArg() > 0 {
+		name = flag.Arg(0)
+	}
+
+	command, ok := commands[name]
+	if !ok {
+		log.Printf("unknown command %q", name)
+		flag.Usage()
+		os.Exit(1)
+	}
+
 	log.Println("loading configuration...")
 	cfg, err := config.LoadConfig(*configFile)
 	if err != nil {
@@ -29,8 +54,34 @@ func main() {
 		return
 	}
 
-	log.Println("starting server...")
-	if err := server.StartServer(cfg); err != nil {
-		log.Fatalf("server exited with error: %v", err)
+	switch {
+	case *siteName != "":
+		if cfg, err = cfg.Site(*siteName); err != nil {
+			log.Fatalf("failed to load configuration: %v", err)
+		}
+	case len(cfg.Sites) > 0 && name != "serve" && name != "password":
+		log.Fatalf("%s works on one site at a time; pick one with -site", name)
+	}
+
+	if err := command(cfg, flag.Args()[min(1, flag.NArg()):]); err != nil {
+		log.Fatalf("%s failed: %v", name, err)
 	}
 }
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\nCommands:\n", os.Args[0])
+	fmt.Fprintln(out, "  serve     run the micropub server (default)")
+	fmt.Fprintln(out, "  reindex   rebuild the search index from the content store")
+	fmt.Fprintln(out, "  jobs      list background jobs, or \"jobs retry <id|all>\" to retry failed ones")
+	fmt.Fprintln(out, "  password  hash a password read from standard input for indieauth.password_hash")
+	fmt.Fprintln(out, "  token     create, list or revoke personal access tokens")
+	fmt.Fprintln(out, "  audit     list audited changes, optionally by -url or -client")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+func runServe(cfg *config.Config, args []string) error {
+	log.Println("starting server...")
+	return server.StartServer(cfg)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/indieauth"
+)
+
+// runToken manages personal access tokens:
+//
+//	token create [-scope s] [-label l] [-expires d] [-me url]   create a token and print it, once
+//	token list                                                  show the tokens, without revealing them
+//	token revoke <id>                                           revoke a token
+//
+// A running server picks up changes on the next request.
+func runToken(cfg *config.Config, args []string) error {
+	if cfg.Auth.PersonalTokensPath == "" {
+		return errors.New("auth.personal_tokens_path is not set; personal access tokens are disabled")
+	}
+
+	store, err := indieauth.NewTokenStore(cfg.Auth.PersonalTokensPath)
+	if err != nil {
+		return err
+	}
+
+	sub := "list"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	switch sub {
+	case "create":
+		return createToken(cfg, store, args[1:])
+	case "list":
+		return listTokens(store)
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New("usage: token revoke <id>")
+		}
+		return revokeToken(store, args[1])
+	default:
+		return fmt.Errorf("unknown token command %q", sub)
+	}
+}
+
+func createToken(cfg *config.Config, store *indieauth.TokenStore, args []string) error {
+	fs := flag.NewFlagSet("token create", flag.ContinueOnError)
+	scope := fs.String("scope", "create", "Space-separated scopes the token grants")
+	label := fs.String("label", "personal", "What the token is for, shown as its client")
+	expires := fs.Duration("expires", 0, "How long the token is valid, i.e. 720h (default: never expires)")
+	me := fs.String("me", cfg.Micropub.MeUrl, "The author the token posts as")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", fs.Args())
+	}
+	if *expires < 0 {
+		return errors.New("-expires must not be negative")
+	}
+	if !(&auth.TokenDetails{Me: *me}).HasAnyMe(auth.Identities(cfg)) {
+		return fmt.Errorf("%s is not one of the site's authors", *me)
+	}
+
+	token, t, err := store.Issue(*me, *label, *scope, *expires)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "created token %s; it is shown only once:\n", t.Id)
+	fmt.Println(token)
+	return nil
+}
+
+func listTokens(store *indieauth.TokenStore) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tME\tLABEL\tSCOPE\tCREATED\tEXPIRES\tLAST USED")
+
+	for _, t := range store.List() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.Id, t.Me, t.ClientId, t.Scope, t.IssuedAt.Format(time.RFC3339), formatTime(t.ExpiresAt, "never"), formatTime(t.LastUsedAt, "-"))
+	}
+
+	return w.Flush()
+}
+
+func revokeToken(store *indieauth.TokenStore, id string) error {
+	ok, err := store.RevokeId(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no token with id %q", id)
+	}
+
+	fmt.Printf("revoked %s\n", id)
+	return nil
+}
+
+func formatTime(t time.Time, zero string) string {
+	if t.IsZero() {
+		return zero
+	}
+
+	return t.Format(time.RFC3339)
+}
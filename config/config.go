@@ -7,12 +7,15 @@ import (
 	"github.com/spf13/viper"
 )
 
-func (c *Config) Validate() error {
+func newValidator() *validator.Validate {
 	validate := validator.New(validator.WithRequiredStructEnabled())
 	validate.RegisterValidation("abspath", ValidateAbsPath)
 	validate.RegisterValidation("localpath", ValidateLocalpath)
+	return validate
+}
 
-	if err := validate.Struct(c); err != nil {
+func (c *Config) Validate() error {
+	if err := newValidator().Struct(c); err != nil {
 		return err
 	}
 
@@ -35,6 +38,15 @@ func LoadConfig(file string) (*Config, error) {
 		return nil, err
 	}
 
+	// With several sites, the top level is only a template; each site must be complete instead.
+	if len(cfg.Sites) > 0 {
+		if err := loadSites(v, &cfg); err != nil {
+			log.Println("validate fail")
+			return nil, err
+		}
+		return &cfg, nil
+	}
+
 	if err := cfg.Validate(); err != nil {
 		log.Println("validate fail")
 		return nil, err
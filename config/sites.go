@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+// Site is one of several sites served by an instance, picked by the request's host, path prefix
+// or both.
+type Site struct {
+	// Host selects the site by the Host header, without the port.
+	Host string `mapstructure:"host" validate:"required_without=PathPrefix,omitempty,hostname_rfc1123"`
+	// PathPrefix selects the site by the start of the request path, e.g. "/family". The prefix is
+	// removed before routing, so the site's endpoints live beneath it.
+	PathPrefix string `mapstructure:"path_prefix" validate:"omitempty,startswith=/,endsnotwith=/"`
+	// Overrides are the site's settings, in the same layout as the top-level configuration.
+	Overrides map[string]any `mapstructure:",remain"`
+	// Config is the site's complete configuration: the top level with the overrides applied.
+	Config *Config `mapstructure:"-" validate:"-"`
+}
+
+// Name identifies the site in logs and on the command line.
+func (s Site) Name() string {
+	return s.Host + s.PathPrefix
+}
+
+// Site returns the configuration of the named site, as given by Site.Name.
+func (c *Config) Site(name string) (*Config, error) {
+	for _, s := range c.Sites {
+		if s.Name() == name {
+			return s.Config, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no site named %q", name)
+}
+
+// loadSites builds each site's configuration from the top-level settings and its overrides.
+func loadSites(v *viper.Viper, cfg *Config) error {
+	base := v.AllSettings()
+	delete(base, "sites")
+
+	names := map[string]bool{}
+	for i := range cfg.Sites {
+		site := &cfg.Sites[i]
+		if err := newValidator().Struct(site); err != nil {
+			return err
+		}
+		if names[site.Name()] {
+			return fmt.Errorf("site %q is configured more than once", site.Name())
+		}
+		names[site.Name()] = true
+
+		sv := viper.New()
+		// Merging changes nested maps in place, so each site starts from its own copy.
+		if err := sv.MergeConfigMap(copySettings(base)); err != nil {
+			return err
+		}
+		if err := sv.MergeConfigMap(site.Overrides); err != nil {
+			return fmt.Errorf("site %q: %w", site.Name(), err)
+		}
+
+		var sc Config
+		if err := sv.Unmarshal(&sc); err != nil {
+			return fmt.Errorf("site %q: %w", site.Name(), err)
+		}
+		if err := sc.Validate(); err != nil {
+			return fmt.Errorf("site %q: %w", site.Name(), err)
+		}
+		site.Config = &sc
+	}
+
+	return checkSharedFiles(cfg.Sites)
+}
+
+func copySettings(settings map[string]any) map[string]any {
+	out := make(map[string]any, len(settings))
+	for k, v := range settings {
+		if nested, ok := v.(map[string]any); ok {
+			v = copySettings(nested)
+		}
+		out[k] = v
+	}
+
+	return out
+}
+
+// checkSharedFiles refuses sites that would keep their state in the same files, which they would
+// overwrite for each other.
+func checkSharedFiles(sites []Site) error {
+	seen := map[string]string{}
+	for _, s := range sites {
+		c := s.Config
+		files := map[string]string{
+			"jobs.path":                 c.Jobs.Path,
+			"webmention.status_path":    c.Webmention.StatusPath,
+			"indieauth.tokens_path":     c.IndieAuth.TokensPath,
+			"auth.personal_tokens_path": c.Auth.PersonalTokensPath,
+		}
+		if c.Search.Embedded != nil {
+			files["search.embedded.path"] = c.Search.Embedded.Path
+		}
+		for _, sink := range c.Audit.Sinks {
+			if sink.File != nil {
+				files["audit.sinks.file.path"] = sink.File.Path
+			}
+		}
+
+		for setting, path := range files {
+			if path == "" {
+				continue
+			}
+			if other, ok := seen[path]; ok {
+				return fmt.Errorf("sites %q and %q share %s %q; give each site its own", other, s.Name(), setting, path)
+			}
+			seen[path] = s.Name()
+		}
+	}
+
+	return nil
+}
@@ -1,11 +1,26 @@
 package config
 
+import "time"
+
 type Config struct {
-	Debug    bool     `mapstructure:"debug"`
-	Server   Server   `mapstructure:"server"`
-	Micropub Micropub `mapstructure:"micropub"`
-	Content  Content  `mapstructure:"content"`
-	Media    Media    `mapstructure:"media"`
+	Debug        bool         `mapstructure:"debug"`
+	Server       Server       `mapstructure:"server"`
+	Micropub     Micropub     `mapstructure:"micropub"`
+	Content      Content      `mapstructure:"content"`
+	Media        Media        `mapstructure:"media"`
+	Search       Search       `mapstructure:"search"`
+	Syndication  Syndication  `mapstructure:"syndication"`
+	Jobs         Jobs         `mapstructure:"jobs"`
+	Webmention   Webmention   `mapstructure:"webmention"`
+	WebSub       WebSub       `mapstructure:"websub"`
+	ReplyContext ReplyContext `mapstructure:"reply_context"`
+	Expiry       Expiry       `mapstructure:"expiry"`
+	Auth         Auth         `mapstructure:"auth"`
+	IndieAuth    IndieAuth    `mapstructure:"indieauth"`
+	Audit        Audit        `mapstructure:"audit"`
+	// Sites lets one instance serve several sites. Each site inherits the rest of this
+	// configuration and overrides what it needs to; the top level then only serves as a template.
+	Sites []Site `mapstructure:"sites"`
 }
 
 type Server struct {
@@ -13,6 +28,7 @@ type Server struct {
 	Port      int          `mapstructure:"port" validate:"required,min=1,max=65535"`
 	PublicUrl string       `mapstructure:"public_url" validate:"required,url"`
 	Limits    ServerLimits `mapstructure:"limits"`
+	RateLimit RateLimit    `mapstructure:"rate_limit"`
 }
 
 type ServerLimits struct {
@@ -21,9 +37,165 @@ type ServerLimits struct {
 	MaxMultipartMem uint `mapstructure:"max_multipart_mem" validate:"required"`
 }
 
+// RateLimit configures per-client request budgets and the cap on concurrent writes. Budgets left
+// at zero don't limit anything.
+type RateLimit struct {
+	Read  RateBudget `mapstructure:"read"`
+	Write RateBudget `mapstructure:"write"`
+	Media RateBudget `mapstructure:"media"`
+	// Webmention limits the public webmention endpoint, which takes no token, by address alone.
+	Webmention RateBudget `mapstructure:"webmention"`
+	// Auth limits the built-in IndieAuth server's endpoints, which take no token either, by address.
+	Auth RateBudget `mapstructure:"auth"`
+	// Keys are what requests are counted by: "token", "client" (client_id) and "ip". Each key gets
+	// the whole budget to itself. Defaults to all three.
+	Keys []string `mapstructure:"keys" validate:"dive,oneof=token client ip"`
+	// ForwardedFor takes the client's address from the last X-Forwarded-For entry, for instances
+	// behind a reverse proxy.
+	ForwardedFor bool `mapstructure:"forwarded_for"`
+	// MaxInFlightWrites caps how many writes may be in progress at once; the rest are turned away
+	// rather than queueing up on the content store. Zero means no cap.
+	MaxInFlightWrites int `mapstructure:"max_in_flight_writes" validate:"gte=0"`
+}
+
+// RateBudget is a token bucket: PerMinute requests a minute on average, with bursts of up to Burst.
+type RateBudget struct {
+	PerMinute float64 `mapstructure:"per_minute" validate:"gte=0"`
+	// Burst defaults to a tenth of PerMinute, and at least 1.
+	Burst int `mapstructure:"burst" validate:"gte=0"`
+}
+
 type Micropub struct {
-	MeUrl         string `mapstructure:"me_url" validate:"required,url"`
-	TokenEndpoint string `mapstructure:"token_endpoint" validate:"required,url"`
+	MeUrl string `mapstructure:"me_url" validate:"required,url"`
+	// TokenVerification picks how access tokens are checked: "token-endpoint" (the default) sends
+	// them to TokenEndpoint, "introspection" posts them to an introspection endpoint, "builtin"
+	// checks them against the tokens issued by the built-in IndieAuth server, and "jwt" verifies
+	// signed JWT access tokens locally.
+	TokenVerification string        `mapstructure:"token_verification" validate:"omitempty,oneof=token-endpoint introspection builtin jwt"`
+	TokenEndpoint     string        `mapstructure:"token_endpoint" validate:"required_without=TokenVerification,required_if=TokenVerification token-endpoint,omitempty,url"`
+	Introspection     Introspection `mapstructure:"introspection"`
+	Jwt               Jwt           `mapstructure:"jwt"`
+	Contacts          Contacts      `mapstructure:"contacts"`
+	PostTypes         []PostType    `mapstructure:"post_types" validate:"dive"`
+	Channels          []Channel     `mapstructure:"channels" validate:"dive"`
+	// DraftsPath is an optional directory, relative to the content path, that drafts are stored in
+	// until they are published.
+	DraftsPath string `mapstructure:"drafts_path" validate:"omitempty,localpath"`
+	// ScheduledPath is an optional directory, relative to the content path, that future-dated posts
+	// are stored in until they are published.
+	ScheduledPath string `mapstructure:"scheduled_path" validate:"omitempty,localpath"`
+	// PrivatePath is an optional directory, relative to the content path, that private posts are
+	// stored in, away from the public site.
+	PrivatePath string `mapstructure:"private_path" validate:"omitempty,localpath"`
+	// PrivateReaders are the client IDs allowed to read private posts through q=source. When empty,
+	// only tokens issued to MeUrl may, so other authors' clients can't.
+	PrivateReaders []string `mapstructure:"private_readers" validate:"dive,url"`
+	// Authors are the identities, besides MeUrl, allowed to post to the site, each with optional
+	// settings of their own. An entry for MeUrl itself gives the owner settings too.
+	Authors []Author `mapstructure:"authors" validate:"dive"`
+	// Policies restrict what particular clients may do, beyond the scopes of their tokens. The first
+	// policy matching a token applies.
+	Policies []Policy `mapstructure:"policies" validate:"dive"`
+}
+
+// Author is someone allowed to post to the site, identified by their IndieWeb profile URL.
+type Author struct {
+	Me string `mapstructure:"me" validate:"required,url"`
+	// Name and Photo make up the h-card set as the author property of the author's new posts.
+	Name  string `mapstructure:"name"`
+	Photo string `mapstructure:"photo" validate:"omitempty,url"`
+	// Path is a directory, relative to the content path, that the author's posts are stored beneath.
+	Path string `mapstructure:"path" validate:"omitempty,localpath"`
+	// Commit is the git author recorded for the author's changes. Defaults to scribble's own.
+	Commit CommitAuthor `mapstructure:"commit"`
+	// Scopes limits the scopes the author's tokens are honoured with. Empty allows any.
+	Scopes []string `mapstructure:"scopes" validate:"dive,oneof=read create draft update delete undelete media"`
+}
+
+type CommitAuthor struct {
+	Name  string `mapstructure:"name"`
+	Email string `mapstructure:"email" validate:"omitempty,email"`
+}
+
+// Policy restricts the tokens of a client, of a profile, or of a client acting for a profile.
+type Policy struct {
+	// ClientId and Me select the tokens the policy applies to. Either may be left empty to match any.
+	ClientId string `mapstructure:"client_id" validate:"required_without=Me"`
+	Me       string `mapstructure:"me" validate:"omitempty,url"`
+	// Deny refuses the client outright.
+	Deny bool `mapstructure:"deny"`
+	// ReadOnly allows queries, but no changes or uploads.
+	ReadOnly bool `mapstructure:"read_only"`
+	// PostTypes limits the types of post the client may create, e.g. "note" or "photo". Empty allows
+	// any.
+	PostTypes []string `mapstructure:"post_types"`
+	// Properties limits the properties the client may set when creating or updating posts. Empty
+	// allows any.
+	Properties []string `mapstructure:"properties"`
+	// MaxPayloadSize overrides server.limits.max_payload_size for the client. It applies when the
+	// token is sent in the Authorization header, as the body has to be read to find any other token.
+	MaxPayloadSize uint `mapstructure:"max_payload_size"`
+}
+
+// Introspection configures token introspection (RFC 7662), used when TokenVerification is
+// "introspection".
+type Introspection struct {
+	// Endpoint is the introspection endpoint. When empty it is discovered from the indieauth-metadata
+	// of MeUrl.
+	Endpoint string `mapstructure:"endpoint" validate:"omitempty,url"`
+	// ClientId and ClientSecret authenticate this server to the endpoint with HTTP Basic auth.
+	ClientId     string `mapstructure:"client_id"`
+	ClientSecret string `mapstructure:"client_secret"`
+	// Token authenticates this server to the endpoint as a bearer token, for servers that issue one
+	// instead of client credentials.
+	Token string `mapstructure:"token" validate:"excluded_with=ClientId"`
+}
+
+// Jwt configures local verification of JWT access tokens, used when TokenVerification is "jwt".
+type Jwt struct {
+	// Jwks is the URL or local file of the JSON Web Key Set holding the signing keys.
+	Jwks string `mapstructure:"jwks"`
+	// Issuer must match the iss claim.
+	Issuer string `mapstructure:"issuer" validate:"omitempty,url"`
+	// Audience must be among the aud claim. Defaults to Server.PublicUrl.
+	Audience string `mapstructure:"audience"`
+	// Algorithms are the accepted signing algorithms. Defaults to RS256, PS256, ES256 and EdDSA.
+	Algorithms []string `mapstructure:"algorithms" validate:"dive,oneof=RS256 RS384 RS512 PS256 PS384 PS512 ES256 ES384 ES512 EdDSA"`
+	// RefreshInterval is how often the key set is reloaded to pick up rotated keys. Defaults to 10m.
+	RefreshInterval time.Duration `mapstructure:"refresh_interval" validate:"gte=0"`
+	// Leeway allows for clock skew when checking exp and nbf. Defaults to 1m.
+	Leeway time.Duration `mapstructure:"leeway" validate:"gte=0"`
+}
+
+type PostType struct {
+	Type               string   `mapstructure:"type" validate:"required"`
+	Name               string   `mapstructure:"name" validate:"required"`
+	Properties         []string `mapstructure:"properties"`
+	RequiredProperties []string `mapstructure:"required_properties"`
+	// Expires is how long posts of this type stay up unless the client sends mp-expires. Zero means
+	// they never expire.
+	Expires time.Duration `mapstructure:"expires"`
+}
+
+type Channel struct {
+	Uid  string `mapstructure:"uid" validate:"required"`
+	Name string `mapstructure:"name" validate:"required"`
+	// Path is the directory, relative to the content path, that posts in this channel are stored in.
+	Path string `mapstructure:"path" validate:"omitempty,localpath"`
+}
+
+type Contacts struct {
+	// Path is an optional JSON file in the content repository holding a list of contacts.
+	Path    string    `mapstructure:"path" validate:"omitempty,localpath"`
+	Entries []Contact `mapstructure:"entries" validate:"dive"`
+}
+
+type Contact struct {
+	Name     string            `mapstructure:"name" validate:"required"`
+	Nickname string            `mapstructure:"nickname"`
+	Url      string            `mapstructure:"url" validate:"omitempty,url"`
+	Photo    string            `mapstructure:"photo" validate:"omitempty,url"`
+	Silos    map[string]string `mapstructure:"silos"`
 }
 
 type Content struct {
@@ -71,3 +243,142 @@ type S3MediaStrategy struct {
 	Prefix         string `mapstructure:"prefix"`
 	PublicUrl      string `mapstructure:"public_url" validate:"omitempty,url"`
 }
+
+type Search struct {
+	Strategy string                  `mapstructure:"strategy" validate:"omitempty,oneof=none embedded"`
+	Embedded *EmbeddedSearchStrategy `mapstructure:"embedded"`
+}
+
+type EmbeddedSearchStrategy struct {
+	// Path is the file the index is persisted to. When empty, the index is kept in memory
+	// and rebuilt from the content store on startup.
+	Path string `mapstructure:"path"`
+}
+
+type Syndication struct {
+	Targets []SyndicationTarget `mapstructure:"targets" validate:"dive"`
+}
+
+type SyndicationTarget struct {
+	Uid      string               `mapstructure:"uid" validate:"required"`
+	Name     string               `mapstructure:"name" validate:"required"`
+	Type     string               `mapstructure:"type" validate:"required,oneof=mastodon webhook"`
+	Service  SyndicationService   `mapstructure:"service"`
+	Mastodon *MastodonSyndication `mapstructure:"mastodon" validate:"required_if=Type mastodon"`
+	Webhook  *WebhookSyndication  `mapstructure:"webhook" validate:"required_if=Type webhook"`
+}
+
+type SyndicationService struct {
+	Name  string `mapstructure:"name"`
+	Url   string `mapstructure:"url" validate:"omitempty,url"`
+	Photo string `mapstructure:"photo" validate:"omitempty,url"`
+}
+
+type MastodonSyndication struct {
+	// Instance is the base URL of any Mastodon-compatible server, e.g. https://mastodon.social
+	Instance    string `mapstructure:"instance" validate:"required,url"`
+	AccessToken string `mapstructure:"access_token" validate:"required"`
+	Visibility  string `mapstructure:"visibility" validate:"omitempty,oneof=public unlisted private direct"`
+}
+
+type WebhookSyndication struct {
+	Url string `mapstructure:"url" validate:"required,url"`
+	// Secret, when set, is used to sign request bodies with HMAC-SHA256 (X-Scribble-Signature header).
+	// Retried deliveries carry the same Idempotency-Key header, the post's URL.
+	Secret string `mapstructure:"secret"`
+}
+
+type Jobs struct {
+	// Path is the directory holding the job queue and the dead-letter list. When empty, jobs are
+	// kept in memory and pending ones are lost on restart.
+	Path        string `mapstructure:"path"`
+	Concurrency int    `mapstructure:"concurrency" validate:"gte=0"`
+	MaxAttempts int    `mapstructure:"max_attempts" validate:"gte=0"`
+}
+
+type Audit struct {
+	// Sinks receive an entry for every create, update, delete, undelete and upload. Without any,
+	// nothing is audited.
+	Sinks []AuditSink `mapstructure:"sinks" validate:"dive"`
+}
+
+type AuditSink struct {
+	Type string         `mapstructure:"type" validate:"required,oneof=file stdout"`
+	File *AuditFileSink `mapstructure:"file" validate:"required_if=Type file"`
+}
+
+type AuditFileSink struct {
+	// Path is the JSON lines file entries are appended to. Once it grows past MaxSize bytes it is
+	// renamed to Path.1, older files moving up to Path.2 and so on, keeping at most MaxFiles of them.
+	Path     string `mapstructure:"path" validate:"required"`
+	MaxSize  uint   `mapstructure:"max_size"`
+	MaxFiles int    `mapstructure:"max_files" validate:"gte=0"`
+}
+
+type Webmention struct {
+	// Send enables outgoing webmentions to the sites posts reply to, like, repost, bookmark or link.
+	Send bool `mapstructure:"send"`
+	// StatusPath is the JSON file recording the delivery status of sent webmentions. When empty,
+	// statuses are kept in memory.
+	StatusPath string `mapstructure:"status_path"`
+	// Receive enables the public webmention endpoint at /webmention. The content store must support
+	// storing mentions.
+	Receive bool `mapstructure:"receive"`
+	// Moderate holds received mentions as pending until they are approved.
+	Moderate bool `mapstructure:"moderate"`
+}
+
+type WebSub struct {
+	// Hubs are notified whenever content changes.
+	Hubs []string `mapstructure:"hubs" validate:"dive,url"`
+	// Feeds are the feed URLs announced to the hubs. They may contain {channel} and {type}
+	// placeholders, filled in from the changed post.
+	Feeds []string `mapstructure:"feeds"`
+	// Debounce is how long changes are collected before the hubs are notified. Defaults to 10s.
+	Debounce time.Duration `mapstructure:"debounce"`
+}
+
+type ReplyContext struct {
+	// Enabled fetches the pages a new post replies to, likes, reposts or bookmarks and embeds an h-cite
+	// describing each.
+	Enabled bool `mapstructure:"enabled"`
+	// Mode is "replace" (the default) to swap the URL for the h-cite, or "append" to keep the URL and
+	// add the h-cite after it.
+	Mode string `mapstructure:"mode" validate:"omitempty,oneof=replace append"`
+	// Timeout bounds each fetch. Defaults to 5s.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// MaxBytes caps how much of each page is read. Defaults to 1MB.
+	MaxBytes int64 `mapstructure:"max_bytes" validate:"gte=0"`
+}
+
+type Expiry struct {
+	// DeleteMedia also removes uploaded photos, videos and audio when a post expires.
+	DeleteMedia bool `mapstructure:"delete_media"`
+}
+
+type Auth struct {
+	// Timeout bounds each request to the token endpoint. Defaults to 5s.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Attempts is how often a token endpoint that is down or overloaded is tried before giving up.
+	// Defaults to 3.
+	Attempts int `mapstructure:"attempts" validate:"min=0"`
+	// CacheTTL is how long a verified token is remembered. Defaults to 2m.
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+	// NegativeCacheTTL is how long a rejected token is remembered. Defaults to 30s.
+	NegativeCacheTTL time.Duration `mapstructure:"negative_cache_ttl"`
+	// PersonalTokensPath is the JSON file of personal access tokens managed with "scribble token".
+	// They are checked before any other kind of token. Empty disables them.
+	PersonalTokensPath string `mapstructure:"personal_tokens_path"`
+}
+
+// IndieAuth configures the built-in IndieAuth server, used when Micropub.TokenVerification is
+// "builtin".
+type IndieAuth struct {
+	// PasswordHash is the site owner's password, as printed by "scribble password".
+	PasswordHash string `mapstructure:"password_hash"`
+	// TokensPath is the JSON file holding issued tokens. When empty, tokens are kept in memory and
+	// are lost on restart.
+	TokensPath string `mapstructure:"tokens_path"`
+	// TokenLifetime is how long issued access tokens are valid. Zero means they don't expire.
+	TokenLifetime time.Duration `mapstructure:"token_lifetime" validate:"gte=0"`
+}
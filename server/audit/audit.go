@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/middleware"
+)
+
+// Entry records one mutating request: who made it, from where, what it asked for and how it ended.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	RequestId  string    `json:"request_id,omitempty"`
+	Me         string    `json:"me,omitempty"`
+	ClientId   string    `json:"client_id,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Action     string    `json:"action"`
+	Url        string    `json:"url,omitempty"`
+	// Changes summarizes what an update touched.
+	Changes *Changes `json:"changes,omitempty"`
+	// Status is the HTTP status the request was answered with.
+	Status int `json:"status"`
+}
+
+// Changes names the properties an update replaced, added to and deleted from.
+type Changes struct {
+	Replace []string `json:"replace,omitempty"`
+	Add     []string `json:"add,omitempty"`
+	Delete  []string `json:"delete,omitempty"`
+}
+
+// Empty reports whether the update touched nothing.
+func (c *Changes) Empty() bool {
+	return c == nil || len(c.Replace)+len(c.Add)+len(c.Delete) == 0
+}
+
+// Sink stores audit entries somewhere.
+type Sink interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+// Factory builds a sink for the provided sink config.
+type Factory func(cfg *config.AuditSink) (Sink, error)
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Factory{}
+)
+
+// Register adds or replaces a sink factory for the given sink type.
+func Register(kind string, factory Factory) {
+	mu.Lock()
+	registry[kind] = factory
+	mu.Unlock()
+}
+
+func init() {
+	Register("file", func(cfg *config.AuditSink) (Sink, error) {
+		return NewFileSink(cfg.File)
+	})
+	Register("stdout", func(cfg *config.AuditSink) (Sink, error) {
+		return NewStdoutSink(), nil
+	})
+}
+
+// Log hands every entry to each of its sinks. A nil Log records nothing.
+type Log struct {
+	mu    sync.Mutex
+	sinks []Sink
+	now   func() time.Time
+}
+
+// NewLog builds the configured sinks, or returns nil if there are none.
+func NewLog(cfg *config.Audit) (*Log, error) {
+	if len(cfg.Sinks) == 0 {
+		return nil, nil
+	}
+
+	l := &Log{now: time.Now}
+	for i := range cfg.Sinks {
+		sink := &cfg.Sinks[i]
+
+		mu.RLock()
+		factory, ok := registry[sink.Type]
+		mu.RUnlock()
+		if !ok {
+			_ = l.Close()
+			return nil, fmt.Errorf("unknown audit sink type %q", sink.Type)
+		}
+
+		s, err := factory(sink)
+		if err != nil {
+			_ = l.Close()
+			return nil, fmt.Errorf("failed to set up %s audit sink: %w", sink.Type, err)
+		}
+		l.sinks = append(l.sinks, s)
+	}
+
+	return l, nil
+}
+
+// Record writes entry to every sink. A sink that fails, or can't rotate, is logged rather than
+// failing the request that was audited, as the change has already been made by then.
+func (l *Log) Record(entry Entry) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = l.now().UTC()
+	}
+
+	for _, s := range l.sinks {
+		if err := s.Write(entry); err != nil {
+			log.Printf("error: audit sink failed on the entry for %s %s: %v", entry.Action, entry.Url, err)
+		}
+	}
+}
+
+// Close closes every sink.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var errs []error
+	for _, s := range l.sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close audit sinks: %v", errs)
+	}
+
+	return nil
+}
+
+// Operation is a mutating request being audited. It is used as the request's ResponseWriter so the
+// status the request ends with is recorded.
+type Operation struct {
+	http.ResponseWriter
+	log    *Log
+	entry  Entry
+	status int
+}
+
+// Begin starts auditing a request for action on url. For creates and uploads url is left empty and
+// taken from the Location of the response. Done must be called once the response is written.
+func (l *Log) Begin(w http.ResponseWriter, r *http.Request, action string, url string) *Operation {
+	op := &Operation{ResponseWriter: w, log: l, entry: Entry{
+		RequestId:  middleware.GetRequestId(r.Context()),
+		RemoteAddr: middleware.RemoteIp(r, false),
+		Action:     action,
+		Url:        url,
+	}}
+	if details := auth.GetToken(r.Context()); details != nil {
+		op.entry.Me, op.entry.ClientId = details.Me, details.ClientId
+	}
+
+	return op
+}
+
+// SetChanges records what an update touches.
+func (op *Operation) SetChanges(c *Changes) {
+	if c.Empty() {
+		return
+	}
+
+	for _, names := range []*[]string{&c.Replace, &c.Add, &c.Delete} {
+		slices.Sort(*names)
+		*names = slices.Compact(*names)
+	}
+	op.entry.Changes = c
+}
+
+func (op *Operation) WriteHeader(status int) {
+	if op.status == 0 {
+		op.status = status
+	}
+	op.ResponseWriter.WriteHeader(status)
+}
+
+func (op *Operation) Write(b []byte) (int, error) {
+	if op.status == 0 {
+		op.status = http.StatusOK
+	}
+	return op.ResponseWriter.Write(b)
+}
+
+// Done records the operation.
+func (op *Operation) Done() {
+	if op.log == nil {
+		return
+	}
+
+	entry := op.entry
+	entry.Status = op.status
+	if entry.Status == 0 {
+		entry.Status = http.StatusOK
+	}
+	if entry.Url == "" {
+		entry.Url = op.Header().Get("Location")
+	}
+
+	op.log.Record(entry)
+}
@@ -0,0 +1,180 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/middleware"
+)
+
+type memorySink struct {
+	entries []Entry
+}
+
+func (s *memorySink) Write(e Entry) error { s.entries = append(s.entries, e); return nil }
+func (s *memorySink) Close() error        { return nil }
+
+func TestOperation_RecordsRequest(t *testing.T) {
+	sink := &memorySink{}
+	l := &Log{sinks: []Sink{sink}, now: func() time.Time { return time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) }}
+
+	var req *http.Request
+	handler := middleware.RequestId(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r.WithContext(auth.AddToken(r.Context(), &auth.TokenDetails{Me: "https://example.org/", ClientId: "https://quill.example/"}))
+		op := l.Begin(w, req, "create", "")
+		w = op
+		w.Header().Set("Location", "https://example.org/post")
+		w.WriteHeader(http.StatusCreated)
+		op.Done()
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set(middleware.RequestIdHeader, "abc-123")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(sink.entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(sink.entries))
+	}
+	want := Entry{
+		Time:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		RequestId:  "abc-123",
+		Me:         "https://example.org/",
+		ClientId:   "https://quill.example/",
+		RemoteAddr: "192.0.2.1",
+		Action:     "create",
+		Url:        "https://example.org/post",
+		Status:     http.StatusCreated,
+	}
+	if got := sink.entries[0]; got != want {
+		t.Fatalf("unexpected entry\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestOperation_NilLog(t *testing.T) {
+	var l *Log
+	rr := httptest.NewRecorder()
+	op := l.Begin(rr, httptest.NewRequest(http.MethodPost, "/", nil), "delete", "https://example.org/post")
+	op.WriteHeader(http.StatusNoContent)
+	op.Done()
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected the response to pass through, got %d", rr.Code)
+	}
+}
+
+func TestFileSink_RotatesAndReads(t *testing.T) {
+	cfg := &config.AuditFileSink{Path: filepath.Join(t.TempDir(), "audit", "audit.jsonl"), MaxSize: 200, MaxFiles: 2}
+	sink, err := NewFileSink(cfg)
+	if err != nil {
+		t.Fatalf("failed to open sink: %v", err)
+	}
+
+	urls := []string{"https://example.org/a", "https://example.org/b", "https://example.org/c", "https://example.org/d", "https://example.org/a"}
+	for _, url := range urls {
+		entry := Entry{Time: time.Now().UTC(), ClientId: "https://quill.example/", Action: "update", Url: url, Status: http.StatusNoContent, Changes: &Changes{Replace: []string{"content"}}}
+		if err := sink.Write(entry); err != nil {
+			t.Fatalf("failed to write entry: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("failed to close sink: %v", err)
+	}
+
+	if _, err := os.Stat(cfg.Path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most %d rotated files, stat gave %v", cfg.MaxFiles, err)
+	}
+
+	all, err := ReadFile(cfg, Filter{})
+	if err != nil {
+		t.Fatalf("failed to read entries: %v", err)
+	}
+	if len(all) == 0 || len(all) >= len(urls) {
+		t.Fatalf("expected the oldest entries to be rotated away, got %d of %d", len(all), len(urls))
+	}
+	if all[len(all)-1].Url != "https://example.org/a" || all[len(all)-2].Url != "https://example.org/d" {
+		t.Fatalf("expected entries oldest first, got %+v", all)
+	}
+
+	matched, err := ReadFile(cfg, Filter{Url: "https://example.org/a/", ClientId: "https://QUILL.example"})
+	if err != nil {
+		t.Fatalf("failed to read entries: %v", err)
+	}
+	if len(matched) != 1 || matched[0].Changes == nil || matched[0].Changes.Replace[0] != "content" {
+		t.Fatalf("unexpected filtered entries %+v", matched)
+	}
+}
+
+func TestFileSink_KeepsWritingWhenRotationFails(t *testing.T) {
+	cfg := &config.AuditFileSink{Path: filepath.Join(t.TempDir(), "audit.jsonl"), MaxSize: 200, MaxFiles: 2}
+	sink, err := NewFileSink(cfg)
+	if err != nil {
+		t.Fatalf("failed to open sink: %v", err)
+	}
+	defer sink.Close()
+
+	// The oldest rotated file can't be removed while it is a directory with something in it.
+	if err := os.MkdirAll(filepath.Join(cfg.Path+".2", "stuck"), 0o755); err != nil {
+		t.Fatalf("failed to block rotation: %v", err)
+	}
+
+	urls := []string{"https://example.org/a", "https://example.org/b", "https://example.org/c"}
+	failures := 0
+	for _, url := range urls {
+		if err := sink.Write(Entry{Time: time.Now().UTC(), Action: "update", Url: url, Status: http.StatusNoContent}); err != nil {
+			failures++
+		}
+	}
+	if failures == 0 {
+		t.Fatalf("expected the failed rotation to be reported")
+	}
+
+	all, err := ReadFile(&config.AuditFileSink{Path: cfg.Path, MaxFiles: 1}, Filter{})
+	if err != nil {
+		t.Fatalf("failed to read entries: %v", err)
+	}
+	if len(all) != len(urls) {
+		t.Fatalf("expected every entry to be kept in the current file, got %+v", all)
+	}
+
+	if err := os.RemoveAll(cfg.Path + ".2"); err != nil {
+		t.Fatalf("failed to unblock rotation: %v", err)
+	}
+	if err := sink.Write(Entry{Time: time.Now().UTC(), Action: "delete", Url: "https://example.org/d", Status: http.StatusNoContent}); err != nil {
+		t.Fatalf("expected rotation to succeed once unblocked, got %v", err)
+	}
+	if _, err := os.Stat(cfg.Path + ".1"); err != nil {
+		t.Fatalf("expected the file to be rotated, stat gave %v", err)
+	}
+}
+
+func TestNewLog(t *testing.T) {
+	if l, err := NewLog(&config.Audit{}); l != nil || err != nil {
+		t.Fatalf("expected no log without sinks, got %v, %v", l, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := NewLog(&config.Audit{Sinks: []config.AuditSink{{Type: "file", File: &config.AuditFileSink{Path: path}}}})
+	if err != nil {
+		t.Fatalf("failed to create log: %v", err)
+	}
+	l.Record(Entry{Action: "delete", Url: "https://example.org/post", Status: http.StatusNoContent})
+	if err := l.Close(); err != nil {
+		t.Fatalf("failed to close log: %v", err)
+	}
+
+	entries, err := ReadFile(&config.AuditFileSink{Path: path}, Filter{})
+	if err != nil || len(entries) != 1 || entries[0].Time.IsZero() {
+		t.Fatalf("expected one timestamped entry, got %+v, %v", entries, err)
+	}
+
+	if _, err := NewLog(&config.Audit{Sinks: []config.AuditSink{{Type: "carrier-pigeon"}}}); err == nil {
+		t.Fatalf("expected an unknown sink type to be refused")
+	}
+}
@@ -0,0 +1,218 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/indieinfra/scribble/config"
+)
+
+const (
+	defaultMaxSize  = 10_000_000
+	defaultMaxFiles = 5
+)
+
+// FileSink appends entries to a JSON lines file, rotating it once it grows too big.
+type FileSink struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	f    *os.File
+	size int64
+}
+
+// NewFileSink opens, or creates, the audit file.
+func NewFileSink(cfg *config.AuditFileSink) (*FileSink, error) {
+	s := &FileSink{path: cfg.Path, maxSize: int64(cfg.MaxSize), maxFiles: cfg.MaxFiles}
+	if s.maxSize == 0 {
+		s.maxSize = defaultMaxSize
+	}
+	if s.maxFiles == 0 {
+		s.maxFiles = defaultMaxFiles
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	s.f, s.size = f, info.Size()
+	return nil
+}
+
+func (s *FileSink) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	// A file that can't be rotated keeps growing rather than losing entries; rotation is tried again
+	// on the next write.
+	var rotateErr error
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			rotateErr = fmt.Errorf("failed to rotate %s, still writing to the current file: %w", s.path, err)
+		}
+	}
+
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return errors.Join(err, rotateErr)
+}
+
+// rotate moves the current file to path.1, shifting older ones up and dropping the oldest. The
+// current file is only closed once a new one is open, so a failure leaves the sink writing to it.
+func (s *FileSink) rotate() error {
+	if err := os.Remove(rotatedPath(s.path, s.maxFiles)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := s.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(rotatedPath(s.path, i), rotatedPath(s.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(s.path, rotatedPath(s.path, 1)); err != nil {
+		return err
+	}
+
+	old := s.f
+	if err := s.open(); err != nil {
+		// Put the still open file back, so it isn't rotated away while entries are written to it.
+		if restoreErr := os.Rename(rotatedPath(s.path, 1), s.path); restoreErr != nil {
+			return errors.Join(err, restoreErr)
+		}
+		return err
+	}
+	if err := old.Close(); err != nil {
+		log.Printf("warning: failed to close rotated audit file %s: %v", rotatedPath(s.path, 1), err)
+	}
+
+	return nil
+}
+
+func (s *FileSink) Close() error {
+	return s.f.Close()
+}
+
+func rotatedPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// WriterSink writes entries as JSON lines to a stream.
+type WriterSink struct {
+	enc *json.Encoder
+}
+
+// NewStdoutSink writes entries to standard output, for whatever collects the process's logs.
+func NewStdoutSink() *WriterSink {
+	return &WriterSink{enc: json.NewEncoder(os.Stdout)}
+}
+
+func (s *WriterSink) Write(entry Entry) error {
+	return s.enc.Encode(entry)
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// Filter picks entries by URL, client or both. Empty fields match everything.
+type Filter struct {
+	Url      string
+	ClientId string
+}
+
+// Matches reports whether the entry passes the filter.
+func (f Filter) Matches(e Entry) bool {
+	if f.Url != "" && strings.TrimSuffix(e.Url, "/") != strings.TrimSuffix(f.Url, "/") {
+		return false
+	}
+	if f.ClientId != "" && normalizeClientId(e.ClientId) != normalizeClientId(f.ClientId) {
+		return false
+	}
+
+	return true
+}
+
+func normalizeClientId(id string) string {
+	return strings.TrimSuffix(strings.ToLower(id), "/")
+}
+
+// ReadFile reads the entries of a file sink that match filter, oldest first, including those in
+// rotated files.
+func ReadFile(cfg *config.AuditFileSink, filter Filter) ([]Entry, error) {
+	maxFiles := cfg.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = defaultMaxFiles
+	}
+
+	var entries []Entry
+	for i := maxFiles; i >= 0; i-- {
+		path := cfg.Path
+		if i > 0 {
+			path = rotatedPath(cfg.Path, i)
+		}
+
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err = readEntries(f, filter, entries)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	return entries, nil
+}
+
+func readEntries(r io.Reader, filter Filter, entries []Entry) ([]Entry, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return entries, err
+		}
+		if filter.Matches(e) {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, sc.Err()
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCachedTokens bounds the token cache, so a flood of made-up tokens can't grow it without limit.
+const maxCachedTokens = 10_000
+
+type cacheEntry struct {
+	// details is nil for a token that was rejected.
+	details *TokenDetails
+	expires time.Time
+}
+
+// tokenCache remembers verification results for a while. Tokens are keyed by their hash, so the
+// cache never holds a usable token.
+type tokenCache struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func newTokenCache(ttl time.Duration, negativeTTL time.Duration) *tokenCache {
+	return &tokenCache{ttl: ttl, negativeTTL: negativeTTL, now: time.Now, entries: map[string]cacheEntry{}}
+}
+
+// get returns the cached result for token: its details, or nil details for a rejected token. ok is
+// false if nothing is cached.
+func (c *tokenCache) get(token string) (details *TokenDetails, ok bool) {
+	key := HashToken(token)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return entry.details, true
+}
+
+// put remembers the result for token; nil details record a rejection.
+func (c *tokenCache) put(token string, details *TokenDetails) {
+	ttl := c.ttl
+	if details == nil {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCachedTokens {
+		for key, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+	}
+	if len(c.entries) >= maxCachedTokens {
+		clear(c.entries)
+	}
+
+	expires := now.Add(ttl)
+	if details != nil && details.ExpiresAt != 0 {
+		// Never vouch for a token past its own expiry.
+		if exp := time.Unix(details.ExpiresAt, 0); exp.Before(expires) {
+			expires = exp
+		}
+	}
+
+	c.entries[HashToken(token)] = cacheEntry{details: details, expires: expires}
+}
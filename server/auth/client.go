@@ -0,0 +1,332 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+)
+
+const (
+	defaultTimeout          = 5 * time.Second
+	defaultAttempts         = 3
+	defaultCacheTTL         = 2 * time.Minute
+	defaultNegativeCacheTTL = 30 * time.Second
+
+	// retryBackoff is the wait before the first retry; it doubles with every further attempt.
+	retryBackoff = 200 * time.Millisecond
+	// defaultRetryAfter is suggested to clients when the token endpoint gave no Retry-After of its own.
+	defaultRetryAfter = 30 * time.Second
+)
+
+// Token verification styles selectable in config.Micropub.
+const (
+	// VerificationIntrospection checks tokens with token introspection (RFC 7662).
+	VerificationIntrospection = "introspection"
+	// VerificationBuiltin checks tokens against those issued by the built-in IndieAuth server.
+	VerificationBuiltin = "builtin"
+	// VerificationJwt verifies tokens locally as signed JWTs, against a JSON Web Key Set.
+	VerificationJwt = "jwt"
+)
+
+// TokenClient verifies tokens against an IndieAuth token endpoint or introspection endpoint,
+// remembering the results for a while so the endpoint isn't asked on every request.
+type TokenClient struct {
+	endpoint string
+	me       string
+	debug    bool
+	client   *http.Client
+	attempts int
+	cache    *tokenCache
+	sleep    func(ctx context.Context, d time.Duration) error
+
+	// identities are the profiles tokens may belong to: me and the site's other authors.
+	identities []string
+
+	// introspect selects introspection over the legacy token endpoint check.
+	introspect   bool
+	clientId     string
+	clientSecret string
+	bearer       string
+
+	// endpointMu guards endpoint while the introspection endpoint is being discovered.
+	endpointMu sync.Mutex
+}
+
+// NewTokenClient creates a client for the configured token endpoint. A nil client gets one with the
+// configured timeout.
+func NewTokenClient(cfg *config.Config, client *http.Client) *TokenClient {
+	timeout := cfg.Auth.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if client == nil {
+		client = &http.Client{Timeout: timeout}
+	}
+
+	attempts := cfg.Auth.Attempts
+	if attempts <= 0 {
+		attempts = defaultAttempts
+	}
+
+	ttl := cfg.Auth.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	negativeTTL := cfg.Auth.NegativeCacheTTL
+	if negativeTTL <= 0 {
+		negativeTTL = defaultNegativeCacheTTL
+	}
+
+	tc := &TokenClient{
+		endpoint:   cfg.Micropub.TokenEndpoint,
+		me:         cfg.Micropub.MeUrl,
+		debug:      cfg.Debug,
+		client:     client,
+		attempts:   attempts,
+		cache:      newTokenCache(ttl, negativeTTL),
+		sleep:      sleep,
+		identities: Identities(cfg),
+	}
+
+	if cfg.Micropub.TokenVerification == VerificationIntrospection {
+		tc.introspect = true
+		tc.endpoint = cfg.Micropub.Introspection.Endpoint
+		tc.clientId = cfg.Micropub.Introspection.ClientId
+		tc.clientSecret = cfg.Micropub.Introspection.ClientSecret
+		tc.bearer = cfg.Micropub.Introspection.Token
+	}
+
+	return tc
+}
+
+// Verify asks the token or introspection endpoint about token, unless a recent answer is cached.
+func (tc *TokenClient) Verify(ctx context.Context, token string) (*TokenDetails, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: empty token", ErrInvalidToken)
+	}
+
+	if details, ok := tc.cache.get(token); ok {
+		if details == nil {
+			return nil, fmt.Errorf("%w: rejected by token endpoint", ErrInvalidToken)
+		}
+		return details, nil
+	}
+
+	var lastErr *UnavailableError
+	for attempt := range tc.attempts {
+		if attempt > 0 {
+			if err := tc.sleep(ctx, retryBackoff<<(attempt-1)); err != nil {
+				return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: err}
+			}
+		}
+
+		var details *TokenDetails
+		var err error
+		if tc.introspect {
+			details, err = tc.introspectToken(ctx, token)
+		} else {
+			details, err = tc.request(ctx, token)
+		}
+		if errors.As(err, &lastErr) {
+			continue
+		}
+		if err != nil {
+			tc.cache.put(token, nil)
+			return nil, err
+		}
+
+		tc.cache.put(token, details)
+		return details, nil
+	}
+
+	return nil, lastErr
+}
+
+// request makes a single call to the token endpoint.
+func (tc *TokenClient) request(ctx context.Context, token string) (*TokenDetails, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tc.endpoint, nil)
+	if err != nil {
+		return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: fmt.Errorf("could not create request for token endpoint: %w", err)}
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := tc.client.Do(req)
+	if err != nil {
+		return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: fmt.Errorf("request to token endpoint failed: %w", err)}
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil, &UnavailableError{RetryAfter: retryAfter(res.Header), Err: fmt.Errorf("token endpoint returned status %d", res.StatusCode)}
+	case res.StatusCode != http.StatusOK:
+		if tc.debug {
+			log.Printf("debug: token failed validation at token endpoint (status %d)", res.StatusCode)
+		}
+		return nil, fmt.Errorf("%w: token endpoint returned status %d", ErrInvalidToken, res.StatusCode)
+	}
+
+	details := &TokenDetails{}
+	if err := json.NewDecoder(res.Body).Decode(details); err != nil {
+		return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: fmt.Errorf("token endpoint provided bad data: %w", err)}
+	}
+
+	return tc.checkDetails(details)
+}
+
+// introspectionResponse is the answer of an introspection endpoint.
+type introspectionResponse struct {
+	Active   bool   `json:"active"`
+	Me       string `json:"me"`
+	ClientId string `json:"client_id"`
+	Scope    string `json:"scope"`
+	Exp      int64  `json:"exp"`
+	Iat      int64  `json:"iat"`
+}
+
+// introspectToken makes a single call to the introspection endpoint, discovering it first if it
+// isn't configured.
+func (tc *TokenClient) introspectToken(ctx context.Context, token string) (*TokenDetails, error) {
+	endpoint, err := tc.introspectionEndpoint(ctx)
+	if err != nil {
+		return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: fmt.Errorf("could not discover introspection endpoint: %w", err)}
+	}
+
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: fmt.Errorf("could not create request for introspection endpoint: %w", err)}
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	switch {
+	case tc.clientId != "":
+		// Client credentials are form-encoded before going into Basic auth (RFC 6749, 2.3.1); a
+		// client ID is a URL, and its colon would otherwise split it.
+		req.SetBasicAuth(url.QueryEscape(tc.clientId), url.QueryEscape(tc.clientSecret))
+	case tc.bearer != "":
+		req.Header.Set("Authorization", "Bearer "+tc.bearer)
+	}
+
+	res, err := tc.client.Do(req)
+	if err != nil {
+		return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: fmt.Errorf("request to introspection endpoint failed: %w", err)}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, res.Body)
+		// An inactive token is reported with 200, so any other status is a problem with the endpoint
+		// or with this server's credentials, not with the token.
+		if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+			log.Printf("warning: introspection endpoint refused this server's credentials (status %d)", res.StatusCode)
+		}
+		return nil, &UnavailableError{RetryAfter: retryAfter(res.Header), Err: fmt.Errorf("introspection endpoint returned status %d", res.StatusCode)}
+	}
+
+	var ir introspectionResponse
+	if err := json.NewDecoder(res.Body).Decode(&ir); err != nil {
+		return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: fmt.Errorf("introspection endpoint provided bad data: %w", err)}
+	}
+
+	if !ir.Active {
+		if tc.debug {
+			log.Println("debug: introspection endpoint reported the token as inactive")
+		}
+		return nil, fmt.Errorf("%w: token is not active", ErrInvalidToken)
+	}
+
+	details := &TokenDetails{Me: ir.Me, ClientId: ir.ClientId, Scope: ir.Scope, ExpiresAt: ir.Exp}
+	if ir.Iat > 0 {
+		details.IssuedAt = uint(ir.Iat)
+	}
+	return tc.checkDetails(details)
+}
+
+// introspectionEndpoint returns the configured introspection endpoint, or discovers it from the
+// indieauth-metadata of me and remembers it.
+func (tc *TokenClient) introspectionEndpoint(ctx context.Context) (string, error) {
+	tc.endpointMu.Lock()
+	defer tc.endpointMu.Unlock()
+
+	if tc.endpoint != "" {
+		return tc.endpoint, nil
+	}
+
+	endpoint, err := discoverIntrospectionEndpoint(ctx, tc.client, tc.me)
+	if err != nil {
+		return "", err
+	}
+	if tc.debug {
+		log.Printf("debug: discovered introspection endpoint %s", endpoint)
+	}
+
+	tc.endpoint = endpoint
+	return endpoint, nil
+}
+
+// Identities are the profile URLs tokens may belong to: the site's own and those of its other
+// authors.
+func Identities(cfg *config.Config) []string {
+	identities := []string{cfg.Micropub.MeUrl}
+	for _, a := range cfg.Micropub.Authors {
+		identities = append(identities, a.Me)
+	}
+
+	return identities
+}
+
+// checkDetails makes sure a token the endpoint vouched for belongs to this site and hasn't expired.
+func (tc *TokenClient) checkDetails(details *TokenDetails) (*TokenDetails, error) {
+	if details.Expired(tc.cache.now()) {
+		return nil, fmt.Errorf("%w: token expired at %s", ErrInvalidToken, time.Unix(details.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	}
+
+	if details.Me == "" {
+		log.Println("warning: token endpoint did not include \"me\" information - cannot verify token")
+		return nil, fmt.Errorf("%w: token endpoint did not say who the token belongs to", ErrInvalidToken)
+	}
+
+	if !details.HasAnyMe(tc.identities) {
+		if tc.debug {
+			log.Printf("debug: received a valid token that did not belong to this instance (me=%q)", details.Me)
+		}
+		return nil, fmt.Errorf("%w: token belongs to %q", ErrInvalidToken, details.Me)
+	}
+
+	return details, nil
+}
+
+// retryAfter reads a Retry-After header given in seconds, falling back to defaultRetryAfter.
+func retryAfter(h http.Header) time.Duration {
+	if secs, err := strconv.Atoi(h.Get("Retry-After")); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+
+	return defaultRetryAfter
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
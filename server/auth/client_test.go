@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*TokenClient, *atomic.Int32) {
+	t.Helper()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	tc := NewTokenClient(&config.Config{Micropub: config.Micropub{MeUrl: "https://example.org/", TokenEndpoint: srv.URL}}, nil)
+	tc.sleep = func(context.Context, time.Duration) error { return nil }
+	return tc, &calls
+}
+
+func TestTokenClient_VerifiesAndCaches(t *testing.T) {
+	tc, calls := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"me":"https://EXAMPLE.org","client_id":"https://app.example/","scope":"create"}`))
+	})
+
+	for range 2 {
+		details, err := tc.Verify(context.Background(), "good")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if details.ClientId != "https://app.example/" || !details.HasScope(ScopeCreate) {
+			t.Fatalf("unexpected details %+v", details)
+		}
+	}
+
+	for range 2 {
+		if _, err := tc.Verify(context.Background(), "bad"); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("expected invalid token, got %v", err)
+		}
+	}
+
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected one request per token, got %d", n)
+	}
+
+	if _, err := tc.Verify(context.Background(), ""); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected empty token to be invalid, got %v", err)
+	}
+}
+
+func TestTokenClient_ExpiredCacheEntriesAreRechecked(t *testing.T) {
+	tc, calls := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"me":"https://example.org/","scope":"create"}`))
+	})
+
+	now := time.Now()
+	tc.cache.now = func() time.Time { return now }
+
+	_, _ = tc.Verify(context.Background(), "token")
+	now = now.Add(defaultCacheTTL)
+	_, _ = tc.Verify(context.Background(), "token")
+
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected the token to be checked again after the cache expired, got %d requests", n)
+	}
+}
+
+func TestTokenClient_RejectsForeignMe(t *testing.T) {
+	tc, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"me":"https://someone-else.example/","scope":"create"}`))
+	})
+
+	if _, err := tc.Verify(context.Background(), "token"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected token for another site to be invalid, got %v", err)
+	}
+}
+
+func TestTokenClient_AcceptsOtherAuthors(t *testing.T) {
+	tc, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"me":"https://alice.example/","scope":"create"}`))
+	})
+	tc.identities = Identities(&config.Config{Micropub: config.Micropub{
+		MeUrl:   "https://example.org/",
+		Authors: []config.Author{{Me: "https://alice.example"}},
+	}})
+
+	if _, err := tc.Verify(context.Background(), "token"); err != nil {
+		t.Fatalf("expected a co-author's token to be accepted, got %v", err)
+	}
+}
+
+func TestTokenClient_UnavailableAfterRetries(t *testing.T) {
+	tc, calls := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := tc.Verify(context.Background(), "token")
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) || unavailable.RetryAfter != 2*time.Minute {
+		t.Fatalf("expected unavailable error with Retry-After, got %v", err)
+	}
+	if n := calls.Load(); n != defaultAttempts {
+		t.Fatalf("expected %d attempts, got %d", defaultAttempts, n)
+	}
+
+	// Outages are not cached; the next request asks again.
+	_, _ = tc.Verify(context.Background(), "token")
+	if n := calls.Load(); n != 2*defaultAttempts {
+		t.Fatalf("expected failures not to be cached, got %d requests", n)
+	}
+}
+
+func TestTokenClient_RecoversOnRetry(t *testing.T) {
+	var failed atomic.Bool
+	tc, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if failed.CompareAndSwap(false, true) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"me":"https://example.org/","scope":"create"}`))
+	})
+
+	if _, err := tc.Verify(context.Background(), "token"); err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+}
+
+func newIntrospectionClient(t *testing.T, handler http.HandlerFunc) *TokenClient {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{Micropub: config.Micropub{
+		MeUrl:             "https://example.org/",
+		TokenVerification: VerificationIntrospection,
+		Introspection:     config.Introspection{Endpoint: srv.URL, ClientId: "https://micropub.example.org/", ClientSecret: "secret"},
+	}}
+	tc := NewTokenClient(cfg, nil)
+	tc.sleep = func(context.Context, time.Duration) error { return nil }
+	return tc
+}
+
+func TestTokenClient_Introspection(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	tc := newIntrospectionClient(t, func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		user, _ = url.QueryUnescape(user)
+		if r.Method != http.MethodPost || !ok || user != "https://micropub.example.org/" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.PostFormValue("token") {
+		case "good":
+			_, _ = fmt.Fprintf(w, `{"active":true,"me":"https://example.org/","client_id":"https://app.example/","scope":"create update","exp":%d}`, exp)
+		case "expired":
+			_, _ = fmt.Fprintf(w, `{"active":true,"me":"https://example.org/","scope":"create","exp":%d}`, time.Now().Add(-time.Minute).Unix())
+		default:
+			_, _ = w.Write([]byte(`{"active":false}`))
+		}
+	})
+
+	details, err := tc.Verify(context.Background(), "good")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if details.ClientId != "https://app.example/" || !details.HasScope(ScopeUpdate) || details.ExpiresAt != exp {
+		t.Fatalf("unexpected details %+v", details)
+	}
+
+	for _, token := range []string{"expired", "revoked"} {
+		if _, err := tc.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("token %q: expected invalid token, got %v", token, err)
+		}
+	}
+}
+
+func TestTokenClient_IntrospectionCredentialsRefused(t *testing.T) {
+	tc := newIntrospectionClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	var unavailable *UnavailableError
+	if _, err := tc.Verify(context.Background(), "token"); !errors.As(err, &unavailable) {
+		t.Fatalf("expected a refused introspection request to be an outage, not a bad token; got %v", err)
+	}
+}
+
+func TestTokenCache_EndsAtTokenExpiry(t *testing.T) {
+	now := time.Now()
+	c := newTokenCache(time.Hour, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.put("token", &TokenDetails{Me: "https://example.org/", ExpiresAt: now.Add(time.Minute).Unix()})
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := c.get("token"); ok {
+		t.Fatalf("expected the cache entry to end when the token expires")
+	}
+}
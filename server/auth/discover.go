@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	// metadataRel is the rel value a profile page uses to point at its IndieAuth server metadata.
+	metadataRel = "indieauth-metadata"
+	// maxDiscoveryBody caps how much of the profile page and the metadata document is read.
+	maxDiscoveryBody = 1 << 20
+)
+
+// errNoMetadata is returned when the profile page does not link to IndieAuth server metadata.
+var errNoMetadata = errors.New("profile does not advertise indieauth-metadata")
+
+var (
+	linkValuePattern = regexp.MustCompile(`<([^>]*)>([^<]*)`)
+	relParamPattern  = regexp.MustCompile(`(?i);\s*rel\s*=\s*(?:"([^"]*)"|([^\s";,]+))`)
+)
+
+// serverMetadata is the part of the IndieAuth server metadata document this server cares about.
+type serverMetadata struct {
+	IntrospectionEndpoint string `json:"introspection_endpoint"`
+}
+
+// discoverIntrospectionEndpoint finds the introspection endpoint of the IndieAuth server that
+// vouches for me, by following the profile page's indieauth-metadata link.
+func discoverIntrospectionEndpoint(ctx context.Context, client *http.Client, me string) (string, error) {
+	metadataUrl, err := discoverMetadata(ctx, client, me)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := fetch(ctx, client, metadataUrl, "application/json")
+	if err != nil {
+		return "", fmt.Errorf("could not fetch indieauth metadata: %w", err)
+	}
+	defer res.Body.Close()
+
+	var metadata serverMetadata
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxDiscoveryBody)).Decode(&metadata); err != nil {
+		return "", fmt.Errorf("indieauth metadata is not valid JSON: %w", err)
+	}
+	if metadata.IntrospectionEndpoint == "" {
+		return "", fmt.Errorf("indieauth metadata at %s has no introspection_endpoint", metadataUrl)
+	}
+
+	return resolve(res.Request.URL, metadata.IntrospectionEndpoint)
+}
+
+// discoverMetadata finds the metadata URL on the profile page me: the first Link header with
+// rel=indieauth-metadata wins, then the first <link> element with it in the HTML.
+func discoverMetadata(ctx context.Context, client *http.Client, me string) (string, error) {
+	res, err := fetch(ctx, client, me, "text/html, */*;q=0.5")
+	if err != nil {
+		return "", fmt.Errorf("could not fetch profile page: %w", err)
+	}
+	defer res.Body.Close()
+
+	base := res.Request.URL
+
+	for _, header := range res.Header.Values("Link") {
+		if href, ok := hrefFromLinkHeader(header); ok {
+			return resolve(base, href)
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", errNoMetadata
+	}
+
+	doc, err := html.Parse(io.LimitReader(res.Body, maxDiscoveryBody))
+	if err != nil {
+		return "", err
+	}
+
+	if href, ok := hrefFromHtml(doc); ok {
+		return resolve(base, href)
+	}
+
+	return "", errNoMetadata
+}
+
+// fetch GETs target, treating any non-2xx status as an error.
+func fetch(ctx context.Context, client *http.Client, target string, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s returned status %d", target, res.StatusCode)
+	}
+
+	return res, nil
+}
+
+func hrefFromLinkHeader(header string) (string, bool) {
+	for _, m := range linkValuePattern.FindAllStringSubmatch(header, -1) {
+		for _, rel := range relParamPattern.FindAllStringSubmatch(m[2], -1) {
+			if hasMetadataRel(rel[1] + rel[2]) {
+				return m[1], true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func hrefFromHtml(n *html.Node) (string, bool) {
+	if n.Type == html.ElementNode && n.Data == "link" {
+		var href, rel string
+		hasHref := false
+		for _, attr := range n.Attr {
+			switch attr.Key {
+			case "href":
+				href, hasHref = attr.Val, true
+			case "rel":
+				rel = attr.Val
+			}
+		}
+
+		if hasHref && hasMetadataRel(rel) {
+			return href, true
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href, ok := hrefFromHtml(c); ok {
+			return href, true
+		}
+	}
+
+	return "", false
+}
+
+func hasMetadataRel(rel string) bool {
+	return slices.Contains(strings.Fields(strings.ToLower(rel)), metadataRel)
+}
+
+func resolve(base *url.URL, href string) (string, error) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+)
+
+func TestDiscoverIntrospectionEndpoint(t *testing.T) {
+	cases := []struct {
+		name string
+		page func(w http.ResponseWriter)
+	}{
+		{"link header", func(w http.ResponseWriter) {
+			w.Header().Set("Link", `</metadata>; rel="indieauth-metadata"`)
+		}},
+		{"html link", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = fmt.Fprint(w, `<html><head><link rel="indieauth-metadata" href="/metadata"></head></html>`)
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					tc.page(w)
+				case "/metadata":
+					w.Header().Set("Content-Type", "application/json")
+					_, _ = w.Write([]byte(`{"issuer":"` + srv.URL + `/","introspection_endpoint":"/introspect"}`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			endpoint, err := discoverIntrospectionEndpoint(context.Background(), srv.Client(), srv.URL+"/")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if endpoint != srv.URL+"/introspect" {
+				t.Fatalf("expected %s/introspect, got %q", srv.URL, endpoint)
+			}
+		})
+	}
+}
+
+func TestTokenClient_DiscoversIntrospectionEndpoint(t *testing.T) {
+	var discoveries int
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			discoveries++
+			w.Header().Set("Link", `</metadata>; rel="indieauth-metadata"`)
+		case "/metadata":
+			_, _ = w.Write([]byte(`{"introspection_endpoint":"` + srv.URL + `/introspect"}`))
+		case "/introspect":
+			_, _ = fmt.Fprintf(w, `{"active":true,"me":%q,"scope":"create"}`, srv.URL+"/")
+		}
+	}))
+	defer srv.Close()
+
+	tc := NewTokenClient(&config.Config{Micropub: config.Micropub{MeUrl: srv.URL + "/", TokenVerification: VerificationIntrospection}}, srv.Client())
+	tc.sleep = func(context.Context, time.Duration) error { return nil }
+
+	for _, token := range []string{"one", "two"} {
+		if _, err := tc.Verify(context.Background(), token); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if discoveries != 1 {
+		t.Fatalf("expected the endpoint to be discovered once, got %d", discoveries)
+	}
+}
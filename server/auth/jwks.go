@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJwksRefresh = 10 * time.Minute
+	// minJwksRefresh spaces out reloads triggered by tokens signed with an unknown key, so a flood of
+	// made-up key IDs can't hammer the key set's server.
+	minJwksRefresh = time.Minute
+	// maxJwksBody caps how much of a key set is read.
+	maxJwksBody = 1 << 20
+)
+
+// jwk is a JSON Web Key as found in a key set (RFC 7517). Only the members needed for signature
+// verification are read.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// verificationKey is a public key from the key set.
+type verificationKey struct {
+	kid string
+	alg string
+	key crypto.PublicKey
+}
+
+// keySet holds the keys of a JWKS file or URL, reloading them every refresh interval and when a
+// token names a key it doesn't know, so rotated keys are picked up.
+type keySet struct {
+	source  string
+	client  *http.Client
+	refresh time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	keys     []verificationKey
+	loadedAt time.Time
+	// triedAt is when a load was last attempted, successful or not.
+	triedAt time.Time
+}
+
+func newKeySet(source string, client *http.Client, refresh time.Duration) *keySet {
+	if refresh <= 0 {
+		refresh = defaultJwksRefresh
+	}
+
+	return &keySet{source: source, client: client, refresh: refresh, now: time.Now}
+}
+
+// lookup returns the candidate keys for a token's kid; without a kid every key is a candidate. The
+// key set is reloaded first when it is stale, or when kid is unknown. An error is returned only if
+// no keys could be loaded at all.
+func (ks *keySet) lookup(ctx context.Context, kid string) ([]verificationKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	now := ks.now()
+	if ks.loadedAt.IsZero() || now.Sub(ks.loadedAt) >= ks.refresh {
+		ks.reload(ctx, now)
+	}
+
+	matches := ks.match(kid)
+	if len(matches) == 0 && kid != "" {
+		ks.reload(ctx, now)
+		matches = ks.match(kid)
+	}
+
+	if ks.keys == nil {
+		return nil, fmt.Errorf("no keys loaded from %s", ks.source)
+	}
+
+	return matches, nil
+}
+
+// reload replaces the keys with a fresh copy of the key set, unless it was tried less than
+// minJwksRefresh ago. On failure the old keys are kept. The caller must hold ks.mu.
+func (ks *keySet) reload(ctx context.Context, now time.Time) {
+	if !ks.triedAt.IsZero() && now.Sub(ks.triedAt) < minJwksRefresh {
+		return
+	}
+	ks.triedAt = now
+
+	keys, err := ks.load(ctx)
+	if err != nil {
+		log.Printf("error: could not load JWKS from %s: %v", ks.source, err)
+		return
+	}
+
+	ks.keys = keys
+	ks.loadedAt = now
+}
+
+func (ks *keySet) match(kid string) []verificationKey {
+	var out []verificationKey
+	for _, k := range ks.keys {
+		if kid == "" || k.kid == kid {
+			out = append(out, k)
+		}
+	}
+
+	return out
+}
+
+func (ks *keySet) load(ctx context.Context) ([]verificationKey, error) {
+	var data []byte
+	if strings.HasPrefix(ks.source, "https://") || strings.HasPrefix(ks.source, "http://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.source, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/jwk-set+json, application/json")
+
+		res, err := ks.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("key set returned status %d", res.StatusCode)
+		}
+		if data, err = io.ReadAll(io.LimitReader(res.Body, maxJwksBody)); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if data, err = os.ReadFile(ks.source); err != nil {
+			return nil, err
+		}
+	}
+
+	return parseJwks(data)
+}
+
+// parseJwks decodes a key set, keeping the signing keys of supported types.
+func parseJwks(data []byte) ([]verificationKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("key set is not valid JSON: %w", err)
+	}
+
+	keys := []verificationKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+		if err != nil {
+			log.Printf("warning: skipping key %q in JWKS: %v", k.Kid, err)
+			continue
+		}
+		keys = append(keys, verificationKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("key set has no usable signing keys")
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || n.BitLen() < 2048 {
+			return nil, errors.New("RSA key is too weak")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != size {
+			return nil, errors.New("malformed EC key")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != size {
+			return nil, errors.New("malformed EC key")
+		}
+		key, err := ecdsa.ParseUncompressedPublicKey(curve, append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC key: %w", err)
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("malformed Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("malformed key parameter")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
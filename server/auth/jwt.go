@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+)
+
+const defaultJwtLeeway = time.Minute
+
+// defaultJwtAlgorithms are accepted when none are configured.
+var defaultJwtAlgorithms = []string{"RS256", "PS256", "ES256", "EdDSA"}
+
+// JwtVerifier verifies signed JWT access tokens locally, without asking the server that issued
+// them. The claims are mapped onto TokenDetails, so handlers can't tell how a token was checked.
+type JwtVerifier struct {
+	keys       *keySet
+	issuer     string
+	audience   string
+	identities []string
+	algorithms []string
+	leeway     time.Duration
+	debug      bool
+	now        func() time.Time
+}
+
+var _ Verifier = (*JwtVerifier)(nil)
+
+// NewJwtVerifier creates a verifier for the configured key set and claims. A nil client gets one
+// with the configured timeout, used when the key set is a URL.
+func NewJwtVerifier(cfg *config.Config, client *http.Client) (*JwtVerifier, error) {
+	jc := cfg.Micropub.Jwt
+	if jc.Jwks == "" {
+		return nil, errors.New("micropub.jwt.jwks is required to verify JWT access tokens")
+	}
+	if jc.Issuer == "" {
+		return nil, errors.New("micropub.jwt.issuer is required to verify JWT access tokens")
+	}
+
+	if client == nil {
+		timeout := cfg.Auth.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+
+	audience := jc.Audience
+	if audience == "" {
+		audience = cfg.Server.PublicUrl
+	}
+	algorithms := jc.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultJwtAlgorithms
+	}
+	leeway := jc.Leeway
+	if leeway <= 0 {
+		leeway = defaultJwtLeeway
+	}
+
+	return &JwtVerifier{
+		keys:       newKeySet(jc.Jwks, client, jc.RefreshInterval),
+		issuer:     jc.Issuer,
+		audience:   audience,
+		identities: Identities(cfg),
+		algorithms: algorithms,
+		leeway:     leeway,
+		debug:      cfg.Debug,
+		now:        time.Now,
+	}, nil
+}
+
+type jwtHeader struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+type jwtClaims struct {
+	Iss      string   `json:"iss"`
+	Aud      audience `json:"aud"`
+	Exp      *float64 `json:"exp"`
+	Nbf      *float64 `json:"nbf"`
+	Iat      *float64 `json:"iat"`
+	Me       string   `json:"me"`
+	ClientId string   `json:"client_id"`
+	Azp      string   `json:"azp"`
+	Scope    string   `json:"scope"`
+	Scp      []string `json:"scp"`
+}
+
+// audience is the aud claim, which may be a single string or a list.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return errors.New("aud must be a string or a list of strings")
+	}
+	*a = many
+	return nil
+}
+
+// Verify checks the token's signature and its iss, aud, exp, nbf and me claims.
+func (v *JwtVerifier) Verify(ctx context.Context, token string) (*TokenDetails, error) {
+	details, err := v.verify(ctx, token)
+	if err != nil && v.debug && errors.Is(err, ErrInvalidToken) {
+		log.Printf("debug: JWT failed validation: %v", err)
+	}
+
+	return details, err
+}
+
+func (v *JwtVerifier) verify(ctx context.Context, token string) (*TokenDetails, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", ErrInvalidToken)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: bad JWT header: %v", ErrInvalidToken, err)
+	}
+	if !slices.Contains(v.algorithms, header.Alg) {
+		return nil, fmt.Errorf("%w: algorithm %q is not accepted", ErrInvalidToken, header.Alg)
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("%w: unsupported critical header parameters %v", ErrInvalidToken, header.Crit)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad JWT signature encoding", ErrInvalidToken)
+	}
+
+	keys, err := v.keys.lookup(ctx, header.Kid)
+	if err != nil {
+		return nil, &UnavailableError{RetryAfter: defaultRetryAfter, Err: err}
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if k.alg != "" && k.alg != header.Alg {
+			continue
+		}
+		if verifySignature(header.Alg, k.key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w: signature does not match any key (kid %q)", ErrInvalidToken, header.Kid)
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: bad JWT claims: %v", ErrInvalidToken, err)
+	}
+
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	scope := claims.Scope
+	if scope == "" {
+		scope = strings.Join(claims.Scp, " ")
+	}
+	clientId := claims.ClientId
+	if clientId == "" {
+		clientId = claims.Azp
+	}
+
+	details := &TokenDetails{Me: claims.Me, ClientId: clientId, Scope: scope, ExpiresAt: int64(*claims.Exp)}
+	if claims.Iat != nil && *claims.Iat > 0 {
+		details.IssuedAt = uint(*claims.Iat)
+	}
+
+	return details, nil
+}
+
+func (v *JwtVerifier) checkClaims(claims jwtClaims) error {
+	now := v.now()
+
+	switch {
+	case claims.Iss != v.issuer:
+		return fmt.Errorf("%w: issued by %q", ErrInvalidToken, claims.Iss)
+	case !slices.ContainsFunc(claims.Aud, func(aud string) bool { return normalizeMe(aud) == normalizeMe(v.audience) }):
+		return fmt.Errorf("%w: not meant for this audience (aud %v)", ErrInvalidToken, []string(claims.Aud))
+	case claims.Exp == nil:
+		return fmt.Errorf("%w: no exp claim", ErrInvalidToken)
+	case now.Add(-v.leeway).After(numericDate(*claims.Exp)):
+		return fmt.Errorf("%w: expired", ErrInvalidToken)
+	case claims.Nbf != nil && now.Add(v.leeway).Before(numericDate(*claims.Nbf)):
+		return fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	case claims.Me == "":
+		return fmt.Errorf("%w: no me claim", ErrInvalidToken)
+	case !(&TokenDetails{Me: claims.Me}).HasAnyMe(v.identities):
+		return fmt.Errorf("%w: token belongs to %q", ErrInvalidToken, claims.Me)
+	}
+
+	return nil
+}
+
+func numericDate(seconds float64) time.Time {
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// ecdsaCurves pairs each ECDSA algorithm with the only curve it may be used with.
+var ecdsaCurves = map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+
+// verifySignature checks a JWS signature made with alg by key over signed.
+func verifySignature(alg string, key any, signed []byte, signature []byte) bool {
+	if alg == "EdDSA" {
+		pub, ok := key.(ed25519.PublicKey)
+		return ok && ed25519.Verify(pub, signed, signature)
+	}
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return false
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch alg[:2] {
+	case "RS":
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, hash, digest, signature) == nil
+	case "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(pub, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve.Params().Name != ecdsaCurves[alg] {
+			return false
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(pub, digest, r, s)
+	}
+
+	return false
+}
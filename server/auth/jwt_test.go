@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+)
+
+type testKey struct {
+	kid  string
+	alg  string
+	priv crypto.Signer
+}
+
+func (k testKey) jwk(t *testing.T) map[string]string {
+	t.Helper()
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := k.priv.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": k.kid, "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+	case *ecdsa.PublicKey:
+		raw, err := pub.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (len(raw) - 1) / 2
+		return map[string]string{"kty": "EC", "kid": k.kid, "crv": "P-256", "x": b64(raw[1 : 1+size]), "y": b64(raw[1+size:])}
+	case ed25519.PublicKey:
+		return map[string]string{"kty": "OKP", "kid": k.kid, "crv": "Ed25519", "x": b64(pub)}
+	}
+
+	t.Fatalf("unsupported key %T", k.priv)
+	return nil
+}
+
+func (k testKey) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": k.alg, "kid": k.kid, "typ": "at+jwt"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+
+	var sig []byte
+	var err error
+	switch priv := k.priv.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(priv, []byte(signed))
+	case *ecdsa.PrivateKey:
+		digest := crypto.SHA256.New()
+		digest.Write([]byte(signed))
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, priv, digest.Sum(nil))
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case *rsa.PrivateKey:
+		digest := crypto.SHA256.New()
+		digest.Write([]byte(signed))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest.Sum(nil))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + b64(sig)
+}
+
+func newTestKeys(t *testing.T) []testKey {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []testKey{{"rsa", "RS256", rsaKey}, {"ec", "ES256", ecKey}, {"ed", "EdDSA", edKey}}
+}
+
+func writeJwks(t *testing.T, path string, keys ...testKey) {
+	t.Helper()
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, k.jwk(t))
+	}
+
+	data, _ := json.Marshal(set)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestJwtVerifier(t *testing.T, jwks string) *JwtVerifier {
+	t.Helper()
+
+	cfg := &config.Config{
+		Server: config.Server{PublicUrl: "https://scribble.example.org"},
+		Micropub: config.Micropub{
+			MeUrl: "https://example.org/",
+			Jwt:   config.Jwt{Jwks: jwks, Issuer: "https://auth.example.org/"},
+		},
+	}
+	v, err := NewJwtVerifier(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":       "https://auth.example.org/",
+		"aud":       []string{"https://scribble.example.org/"},
+		"exp":       time.Now().Add(time.Hour).Unix(),
+		"iat":       time.Now().Unix(),
+		"me":        "https://example.org/",
+		"client_id": "https://app.example/",
+		"scope":     "create update",
+	}
+}
+
+func TestJwtVerifier_ValidTokens(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks(t, path, keys...)
+	v := newTestJwtVerifier(t, path)
+
+	for _, k := range keys {
+		details, err := v.Verify(context.Background(), k.sign(t, validClaims()))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", k.alg, err)
+		}
+		if details.ClientId != "https://app.example/" || !details.HasScope(ScopeUpdate) || details.ExpiresAt == 0 {
+			t.Fatalf("%s: unexpected details %+v", k.alg, details)
+		}
+	}
+}
+
+func TestJwtVerifier_RejectsBadClaims(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks(t, path, keys...)
+	v := newTestJwtVerifier(t, path)
+
+	cases := map[string]func(c map[string]any){
+		"issuer":   func(c map[string]any) { c["iss"] = "https://evil.example/" },
+		"audience": func(c map[string]any) { c["aud"] = "https://other.example/" },
+		"expired":  func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"no exp":   func(c map[string]any) { delete(c, "exp") },
+		"nbf":      func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"no me":    func(c map[string]any) { delete(c, "me") },
+		"other me": func(c map[string]any) { c["me"] = "https://someone-else.example/" },
+	}
+
+	for name, mutate := range cases {
+		claims := validClaims()
+		mutate(claims)
+		if _, err := v.Verify(context.Background(), keys[0].sign(t, claims)); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: expected invalid token, got %v", name, err)
+		}
+	}
+}
+
+func TestJwtVerifier_RejectsForgedTokens(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks(t, path, keys[0])
+	v := newTestJwtVerifier(t, path)
+
+	parts := strings.Split(keys[0].sign(t, validClaims()), ".")
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+
+	otherRsa, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Signed under the right kid, but by another key, or with another algorithm.
+	impostor := testKey{"rsa", "RS256", otherRsa}
+	confused := testKey{"rsa", "ES256", keys[1].priv}
+
+	for name, forged := range map[string]string{
+		"alg none":  unsigned,
+		"impostor":  impostor.sign(t, validClaims()),
+		"confused":  confused.sign(t, validClaims()),
+		"truncated": parts[0] + "." + parts[1],
+		"garbage":   "garbage",
+	} {
+		if _, err := v.Verify(context.Background(), forged); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: expected forged token to be invalid, got %v", name, err)
+		}
+	}
+}
+
+func TestJwtVerifier_PicksUpRotatedKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJwks(t, path, keys[0])
+	v := newTestJwtVerifier(t, path)
+
+	now := time.Now()
+	v.keys.now = func() time.Time { return now }
+
+	if _, err := v.Verify(context.Background(), keys[0].sign(t, validClaims())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The issuer rotates to a new key. Until minJwksRefresh has passed the key set is not reloaded.
+	writeJwks(t, path, keys[0], keys[2])
+	if _, err := v.Verify(context.Background(), keys[2].sign(t, validClaims())); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected the new key to be unknown right after loading, got %v", err)
+	}
+
+	now = now.Add(minJwksRefresh)
+	if _, err := v.Verify(context.Background(), keys[2].sign(t, validClaims())); err != nil {
+		t.Fatalf("expected the rotated key to be picked up, got %v", err)
+	}
+}
+
+func TestJwtVerifier_UnavailableWithoutKeys(t *testing.T) {
+	keys := newTestKeys(t)
+	v := newTestJwtVerifier(t, filepath.Join(t.TempDir(), "missing.json"))
+
+	var unavailable *UnavailableError
+	if _, err := v.Verify(context.Background(), keys[0].sign(t, validClaims())); !errors.As(err, &unavailable) {
+		t.Fatalf("expected verification to be unavailable without keys, got %v", err)
+	}
+}
+
+func TestNewJwtVerifierRequiresSettings(t *testing.T) {
+	for _, jc := range []config.Jwt{{}, {Jwks: "jwks.json"}, {Issuer: "https://auth.example.org/"}} {
+		if _, err := NewJwtVerifier(&config.Config{Micropub: config.Micropub{Jwt: jc}}, nil); err == nil {
+			t.Fatalf("expected %+v to be refused", jc)
+		}
+	}
+}
@@ -2,26 +2,30 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"log"
-	"net/http"
 	"slices"
 	"strings"
-
-	"github.com/indieinfra/scribble/config"
+	"time"
 )
 
 type tokenKeyType struct{}
 
 var tokenKey = tokenKeyType{}
 
+type tokenHashKeyType struct{}
+
+var tokenHashKey = tokenHashKeyType{}
+
 type TokenDetails struct {
 	Me       string `json:"me"`
 	ClientId string `json:"client_id"`
 	Scope    string `json:"scope"`
 	IssuedAt uint   `json:"issued_at"`
 	Nonce    int    `json:"nonce"`
+	// ExpiresAt is when the token expires, in seconds since the epoch, or zero if it was not said.
+	ExpiresAt int64 `json:"exp,omitempty"`
 }
 
 func AddToken(ctx context.Context, details *TokenDetails) context.Context {
@@ -37,6 +41,24 @@ func GetToken(ctx context.Context) *TokenDetails {
 	return token
 }
 
+// AddTokenHash records a hash of the token a request was made with, so requests can be told apart by
+// token without keeping the token itself around.
+func AddTokenHash(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenHashKey, HashToken(token))
+}
+
+// HashToken returns the hash AddTokenHash records for token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetTokenHash returns the hash recorded by AddTokenHash, or an empty string.
+func GetTokenHash(ctx context.Context) string {
+	hash, _ := ctx.Value(tokenHashKey).(string)
+	return hash
+}
+
 func (details *TokenDetails) String() string {
 	return fmt.Sprintf("TokenDetails{me=%v, clientId=%v, scope=%v, issuedAt=%v, nonce=%v}", details.Me, details.ClientId, details.Scope, details.IssuedAt, details.Nonce)
 }
@@ -45,56 +67,22 @@ func (details *TokenDetails) HasScope(scope Scope) bool {
 	return slices.Contains(strings.Split(strings.ToLower(details.Scope), " "), strings.ToLower(scope.String()))
 }
 
-func (details *TokenDetails) HasMe(me string) bool {
-	return strings.Trim(strings.ToLower(me), " ") == me
+// Expired reports whether the token has an expiry time that is not after now.
+func (details *TokenDetails) Expired(now time.Time) bool {
+	return details.ExpiresAt != 0 && !now.Before(time.Unix(details.ExpiresAt, 0))
 }
 
-func VerifyAccessToken(cfg *config.Config, token string) *TokenDetails {
-	if token == "" {
-		log.Panicf("error: received empty token")
-	}
-
-	tokenEndpointUrl := cfg.Micropub.TokenEndpoint
-	req, err := http.NewRequest(http.MethodGet, tokenEndpointUrl, nil)
-	if err != nil {
-		log.Fatal(fmt.Errorf("error: could not create http request for token endpoint: %w", err))
-	}
-
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %v", token))
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		log.Fatal(fmt.Errorf("error: failed to make http request to token endpoint: %w", err))
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		if cfg.Debug {
-			log.Printf("debug: token failed validation at token endpoint (%q)", token)
-		}
-
-		return nil
-	}
-
-	details := &TokenDetails{}
-	err = json.NewDecoder(resp.Body).Decode(details)
-	if err != nil {
-		log.Println(fmt.Errorf("warning: token endpoint provided bad data, can not verify token: %w", err))
-		return nil
-	}
-
-	if details.Me == "" {
-		log.Println("warning: token endpoint did not include \"me\" information - cannot verify token")
-		return nil
-	}
-
-	if !details.HasMe(cfg.Micropub.MeUrl) {
-		if cfg.Debug {
-			log.Printf("debug: received a valid token that did not belong to this instance! (%q)\n", token)
-		}
+// HasMe reports whether the token was issued for the given profile URL, ignoring case and a
+// trailing slash.
+func (details *TokenDetails) HasMe(me string) bool {
+	return normalizeMe(details.Me) == normalizeMe(me)
+}
 
-		return nil
-	}
+// HasAnyMe reports whether the token was issued for any of the given profile URLs.
+func (details *TokenDetails) HasAnyMe(identities []string) bool {
+	return slices.ContainsFunc(identities, details.HasMe)
+}
 
-	return details
+func normalizeMe(me string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(me)), "/")
 }
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidToken is returned for tokens that are expired, revoked, unknown or issued for another
+// site. Requests carrying one should be refused.
+var ErrInvalidToken = errors.New("invalid access token")
+
+// UnavailableError is returned when a token could not be checked because the service that vouches
+// for it is unreachable or failing. The token may well be fine; the client should retry later.
+type UnavailableError struct {
+	// RetryAfter is how long the client should wait before trying again.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("token verification unavailable: %v", e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Verifier checks access tokens. Verify returns the details of a valid token, an error wrapping
+// ErrInvalidToken for a token that must be refused, or an *UnavailableError when the token could not
+// be checked.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*TokenDetails, error)
+}
+
+// Chain checks tokens with each verifier in turn, moving on to the next while they find the token
+// invalid. It stops at the first that accepts the token or can't check it.
+type Chain []Verifier
+
+func (c Chain) Verify(ctx context.Context, token string) (*TokenDetails, error) {
+	err := fmt.Errorf("%w: no verifier configured", ErrInvalidToken)
+	for _, v := range c {
+		var details *TokenDetails
+		details, err = v.Verify(ctx, token)
+		if err == nil || !errors.Is(err, ErrInvalidToken) {
+			return details, err
+		}
+	}
+
+	return nil, err
+}
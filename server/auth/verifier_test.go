@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type verifierFunc func(ctx context.Context, token string) (*TokenDetails, error)
+
+func (fn verifierFunc) Verify(ctx context.Context, token string) (*TokenDetails, error) {
+	return fn(ctx, token)
+}
+
+func TestChain(t *testing.T) {
+	personal := verifierFunc(func(_ context.Context, token string) (*TokenDetails, error) {
+		if token == "personal" {
+			return &TokenDetails{Me: "https://example.org/", ClientId: "ci"}, nil
+		}
+		return nil, fmt.Errorf("%w: unknown", ErrInvalidToken)
+	})
+	var remoteCalls int
+	remote := verifierFunc(func(_ context.Context, token string) (*TokenDetails, error) {
+		remoteCalls++
+		switch token {
+		case "remote":
+			return &TokenDetails{Me: "https://example.org/", ClientId: "https://app.example/"}, nil
+		case "down":
+			return nil, &UnavailableError{RetryAfter: time.Second, Err: errors.New("connection refused")}
+		}
+		return nil, fmt.Errorf("%w: rejected", ErrInvalidToken)
+	})
+
+	chain := Chain{personal, remote}
+
+	if details, err := chain.Verify(context.Background(), "personal"); err != nil || details.ClientId != "ci" || remoteCalls != 0 {
+		t.Fatalf("expected the personal token to be accepted without asking the remote, got %+v, %v (%d calls)", details, err, remoteCalls)
+	}
+	if details, err := chain.Verify(context.Background(), "remote"); err != nil || details.ClientId != "https://app.example/" {
+		t.Fatalf("expected the remote token to be accepted, got %+v, %v", details, err)
+	}
+
+	var unavailable *UnavailableError
+	if _, err := chain.Verify(context.Background(), "down"); !errors.As(err, &unavailable) {
+		t.Fatalf("expected the remote outage to be reported, got %v", err)
+	}
+	if _, err := chain.Verify(context.Background(), "nope"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected an unknown token to be invalid, got %v", err)
+	}
+	if _, err := (Chain{}).Verify(context.Background(), "nope"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected an empty chain to refuse tokens, got %v", err)
+	}
+}
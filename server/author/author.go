@@ -0,0 +1,171 @@
+package author
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// Enabled reports whether the site has authors configured. Without them, posts are not attributed:
+// everything comes from the site's owner.
+func Enabled(cfg *config.Micropub) bool {
+	return len(cfg.Authors) > 0
+}
+
+// For returns the settings of the author the token belongs to, or nil if there are none.
+func For(cfg *config.Micropub, details *auth.TokenDetails) *config.Author {
+	if details == nil {
+		return nil
+	}
+
+	for i, a := range cfg.Authors {
+		if details.HasMe(a.Me) {
+			return &cfg.Authors[i]
+		}
+	}
+
+	return nil
+}
+
+// Card is the h-card set as the author property of a new post by me.
+func Card(cfg *config.Micropub, me string) map[string]any {
+	props := map[string]any{"url": []any{me}}
+	if a := For(cfg, &auth.TokenDetails{Me: me}); a != nil {
+		if a.Name != "" {
+			props["name"] = []any{a.Name}
+		}
+		if a.Photo != "" {
+			props["photo"] = []any{a.Photo}
+		}
+	}
+
+	return map[string]any{"type": []any{"h-card"}, "properties": props}
+}
+
+// Of returns the configured author a document is attributed to, going by the url of its author
+// property, or nil.
+func Of(cfg *config.Micropub, doc util.Mf2Document) *config.Author {
+	for _, url := range urls(doc.Properties["author"]) {
+		if a := For(cfg, &auth.TokenDetails{Me: url}); a != nil {
+			return a
+		}
+	}
+
+	return nil
+}
+
+// Claims reports whether every value of an author property names the token's owner, so a client
+// can't attribute a post to someone else. Values without a url, such as a bare name, don't.
+func Claims(details *auth.TokenDetails, values []any) bool {
+	found := urls(values)
+	if details == nil || len(found) != len(values) {
+		return false
+	}
+
+	for _, url := range found {
+		if !details.HasMe(url) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// urls collects the url of each value of an author property, which may be a URL or an h-card.
+func urls(values []any) []string {
+	var out []string
+	for _, v := range values {
+		var url string
+		switch a := v.(type) {
+		case string:
+			url = a
+		case map[string]any:
+			if props, ok := a["properties"].(map[string]any); ok {
+				if urls, ok := props["url"].([]any); ok && len(urls) > 0 {
+					url, _ = urls[0].(string)
+				}
+			}
+		}
+
+		if url != "" {
+			out = append(out, url)
+		}
+	}
+
+	return out
+}
+
+type ownerKeyType struct{}
+
+var ownerKey = ownerKeyType{}
+
+// WithOwner records me as the author whose posts are written with the returned context, which
+// decides where they are stored. For new posts that is the token's owner.
+func WithOwner(ctx context.Context, me string) context.Context {
+	return context.WithValue(ctx, ownerKey, me)
+}
+
+// Owner returns the configured author whose post is being written, or nil. Without an owner in
+// ctx, as when a post is published or expired in the background, it is the author the post was
+// attributed to when it was created.
+func Owner(ctx context.Context, cfg *config.Micropub, doc util.Mf2Document) *config.Author {
+	if me, ok := ctx.Value(ownerKey).(string); ok {
+		return For(cfg, &auth.TokenDetails{Me: me})
+	}
+
+	return Of(cfg, doc)
+}
+
+// WithCommitter records the token's author as the one making changes with the returned context.
+func WithCommitter(ctx context.Context, cfg *config.Micropub, details *auth.TokenDetails) context.Context {
+	if details == nil || !Enabled(cfg) {
+		return ctx
+	}
+
+	c := content.Committer{Me: details.Me}
+	if a := For(cfg, details); a != nil {
+		c.Name, c.Email = a.Commit.Name, a.Commit.Email
+	}
+
+	return content.WithCommitter(ctx, c)
+}
+
+// scopedVerifier limits the scopes of each author's tokens to those the author is allowed.
+type scopedVerifier struct {
+	cfg  *config.Micropub
+	next auth.Verifier
+}
+
+// Restrict wraps verifier so the tokens of authors with limited scopes only carry those scopes.
+func Restrict(cfg *config.Micropub, verifier auth.Verifier) auth.Verifier {
+	return &scopedVerifier{cfg: cfg, next: verifier}
+}
+
+func (v *scopedVerifier) Verify(ctx context.Context, token string) (*auth.TokenDetails, error) {
+	details, err := v.next.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	a := For(v.cfg, details)
+	if a == nil || len(a.Scopes) == 0 {
+		return details, nil
+	}
+
+	var scopes []string
+	for _, s := range strings.Fields(details.Scope) {
+		if slices.Contains(a.Scopes, strings.ToLower(s)) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	// Verifiers may cache and share the details they return, so change a copy.
+	restricted := *details
+	restricted.Scope = strings.Join(scopes, " ")
+	return &restricted, nil
+}
@@ -0,0 +1,96 @@
+package author
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+var testCfg = &config.Micropub{
+	MeUrl: "https://example.org/",
+	Authors: []config.Author{
+		{Me: "https://alice.example/", Name: "Alice", Photo: "https://alice.example/me.jpg", Commit: config.CommitAuthor{Name: "Alice", Email: "alice@example.org"}},
+		{Me: "https://bob.example/", Scopes: []string{"create", "media"}},
+	},
+}
+
+func TestCard(t *testing.T) {
+	want := map[string]any{"type": []any{"h-card"}, "properties": map[string]any{
+		"url":   []any{"https://alice.example"},
+		"name":  []any{"Alice"},
+		"photo": []any{"https://alice.example/me.jpg"},
+	}}
+	if got := Card(testCfg, "https://alice.example"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected card %#v", got)
+	}
+
+	owner := Card(testCfg, "https://example.org/")
+	if props := owner["properties"].(map[string]any); len(props) != 1 {
+		t.Fatalf("expected an author without settings to get a bare card, got %#v", owner)
+	}
+}
+
+func TestWithCommitter(t *testing.T) {
+	ctx := WithCommitter(context.Background(), testCfg, &auth.TokenDetails{Me: "https://alice.example/"})
+	c, ok := content.CommitterFrom(ctx)
+	if !ok || c.Name != "Alice" || c.Email != "alice@example.org" || c.Me != "https://alice.example/" {
+		t.Fatalf("unexpected committer %+v", c)
+	}
+
+	ctx = WithCommitter(context.Background(), &config.Micropub{MeUrl: "https://example.org/"}, &auth.TokenDetails{Me: "https://example.org/"})
+	if _, ok := content.CommitterFrom(ctx); ok {
+		t.Fatalf("expected no committer on a single-author site")
+	}
+}
+
+type fixedVerifier auth.TokenDetails
+
+func (v *fixedVerifier) Verify(context.Context, string) (*auth.TokenDetails, error) {
+	details := auth.TokenDetails(*v)
+	return &details, nil
+}
+
+func TestRestrict(t *testing.T) {
+	bob := Restrict(testCfg, &fixedVerifier{Me: "https://bob.example/", Scope: "create update media"})
+	details, err := bob.Verify(context.Background(), "token")
+	if err != nil || details.Scope != "create media" {
+		t.Fatalf("expected bob's scopes to be limited, got %+v, %v", details, err)
+	}
+
+	alice := Restrict(testCfg, &fixedVerifier{Me: "https://alice.example/", Scope: "create update"})
+	details, err = alice.Verify(context.Background(), "token")
+	if err != nil || details.Scope != "create update" {
+		t.Fatalf("expected alice's scopes to be left alone, got %+v, %v", details, err)
+	}
+}
+
+func TestClaims(t *testing.T) {
+	alice := &auth.TokenDetails{Me: "https://alice.example/"}
+	card := func(props map[string]any) map[string]any {
+		return map[string]any{"type": []any{"h-card"}, "properties": props}
+	}
+
+	cases := []struct {
+		values []any
+		want   bool
+	}{
+		{[]any{"https://alice.example"}, true},
+		{[]any{card(map[string]any{"url": []any{"https://alice.example/"}, "name": []any{"Alice"}})}, true},
+		{[]any{"https://bob.example/"}, false},
+		{[]any{"https://alice.example/", "https://bob.example/"}, false},
+		{[]any{card(map[string]any{"name": []any{"Alice"}})}, false},
+	}
+
+	for i, c := range cases {
+		if got := Claims(alice, c.values); got != c.want {
+			t.Fatalf("case %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+	if Claims(nil, []any{"https://alice.example/"}) {
+		t.Fatalf("expected no claims without a token")
+	}
+}
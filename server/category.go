@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/storage/category"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+func initializeCategories(st *state.ScribbleState) error {
+	vocab := category.NewVocabulary()
+	if lister, ok := st.ContentStore.(content.Lister); ok {
+		if err := vocab.Load(context.Background(), lister); err != nil {
+			return err
+		}
+	}
+	st.Categories = vocab
+
+	st.Hooks.Register(hooks.Public(hooks.ListenerFunc(func(ctx context.Context, ev hooks.Event) {
+		switch {
+		case ev.Action == hooks.ActionDelete:
+			vocab.Remove(ev.Url)
+		case ev.Document != nil:
+			vocab.Set(ev.Url, *ev.Document)
+		default:
+			log.Printf("warning: category vocabulary may be stale for %q", ev.Url)
+		}
+	})))
+
+	return nil
+}
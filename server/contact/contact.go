@@ -0,0 +1,136 @@
+package contact
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// refreshInterval controls how often the contacts file in the content store is re-read.
+const refreshInterval = 5 * time.Minute
+
+// Contact is a person known to the site owner, in the shape used by the q=contact query.
+type Contact struct {
+	Name     string            `json:"name"`
+	Nickname string            `json:"nickname,omitempty"`
+	Url      string            `json:"url,omitempty"`
+	Photo    string            `json:"photo,omitempty"`
+	Silos    map[string]string `json:"silos,omitempty"`
+}
+
+// Directory serves the contacts declared in the configuration, merged with those kept in a JSON
+// file in the content store. The file is cached and re-read periodically.
+type Directory struct {
+	static []Contact
+	reader content.FileReader
+	path   string
+	now    func() time.Time
+
+	mu       sync.Mutex
+	cached   []Contact
+	loadedAt time.Time
+}
+
+// NewDirectory builds a directory from the contacts configuration. The reader may be nil, in which
+// case only the configured entries are served.
+func NewDirectory(cfg *config.Contacts, reader content.FileReader) *Directory {
+	d := &Directory{now: time.Now}
+
+	for _, c := range cfg.Entries {
+		d.static = append(d.static, Contact{Name: c.Name, Nickname: c.Nickname, Url: c.Url, Photo: c.Photo, Silos: c.Silos})
+	}
+
+	if cfg.Path != "" {
+		d.reader = reader
+		d.path = cfg.Path
+	}
+
+	return d
+}
+
+// All returns every known contact. Contacts from the configuration take precedence over contacts
+// from the file that share their nickname.
+func (d *Directory) All(ctx context.Context) ([]Contact, error) {
+	if d.reader == nil {
+		return d.static, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cached != nil && d.now().Sub(d.loadedAt) < refreshInterval {
+		return d.cached, nil
+	}
+
+	fromFile, err := d.readFile(ctx)
+	if err != nil {
+		if d.cached != nil {
+			// Keep serving the last good copy rather than dropping every contact.
+			return d.cached, err
+		}
+		return d.static, err
+	}
+
+	merged := append([]Contact{}, d.static...)
+	for _, c := range fromFile {
+		if c.Nickname != "" && findNickname(d.static, c.Nickname) != nil {
+			continue
+		}
+		merged = append(merged, c)
+	}
+
+	d.cached = merged
+	d.loadedAt = d.now()
+	return merged, nil
+}
+
+func (d *Directory) readFile(ctx context.Context) ([]Contact, error) {
+	data, err := d.reader.ReadFile(ctx, d.path)
+	if errors.Is(err, content.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read contacts file: %w", err)
+	}
+
+	var contacts []Contact
+	if err := json.Unmarshal(data, &contacts); err != nil {
+		return nil, fmt.Errorf("failed to parse contacts file %q: %w", d.path, err)
+	}
+
+	return contacts, nil
+}
+
+// Search returns the contacts whose name, nickname or url contain the query, case-insensitively.
+func Search(contacts []Contact, query string) []Contact {
+	query = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(query), "@"))
+
+	out := make([]Contact, 0, len(contacts))
+	for _, c := range contacts {
+		if query == "" ||
+			strings.Contains(strings.ToLower(c.Name), query) ||
+			strings.Contains(strings.ToLower(c.Nickname), query) ||
+			strings.Contains(strings.ToLower(c.Url), query) {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+func findNickname(contacts []Contact, nickname string) *Contact {
+	nickname = strings.TrimPrefix(nickname, "@")
+	for i := range contacts {
+		if contacts[i].Nickname != "" && strings.EqualFold(contacts[i].Nickname, nickname) {
+			return &contacts[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package contact
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+type stubReader struct {
+	data  string
+	err   error
+	reads int
+}
+
+func (s *stubReader) ReadFile(context.Context, string) ([]byte, error) {
+	s.reads++
+	return []byte(s.data), s.err
+}
+
+var testContacts = []Contact{
+	{Name: "Jane Doe", Nickname: "jane", Url: "https://jane.example"},
+	{Name: "Bob", Nickname: "bob", Url: "https://bob.example", Photo: "https://bob.example/me.jpg"},
+	{Name: "No Url", Nickname: "nourl"},
+}
+
+func TestDirectory_MergesFileAndCaches(t *testing.T) {
+	reader := &stubReader{data: `[{"name":"Jane From File","nickname":"jane"},{"name":"Carol","nickname":"carol","url":"https://carol.example"}]`}
+	d := NewDirectory(&config.Contacts{
+		Path:    "contacts.json",
+		Entries: []config.Contact{{Name: "Jane Doe", Nickname: "jane", Url: "https://jane.example"}},
+	}, reader)
+
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	all, err := d.All(context.Background())
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if len(all) != 2 || all[0].Name != "Jane Doe" || all[1].Name != "Carol" {
+		t.Fatalf("unexpected contacts: %+v", all)
+	}
+
+	_, _ = d.All(context.Background())
+	if reader.reads != 1 {
+		t.Fatalf("expected cached contacts to be reused, got %d reads", reader.reads)
+	}
+
+	now = now.Add(refreshInterval)
+	reader.err = errors.New("remote down")
+	all, err = d.All(context.Background())
+	if err == nil || len(all) != 2 {
+		t.Fatalf("expected stale contacts with error, got %+v %v", all, err)
+	}
+}
+
+func TestDirectory_MissingFile(t *testing.T) {
+	d := NewDirectory(&config.Contacts{Path: "contacts.json"}, &stubReader{err: content.ErrNotFound})
+
+	all, err := d.All(context.Background())
+	if err != nil || len(all) != 0 {
+		t.Fatalf("expected no contacts and no error, got %+v %v", all, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	if got := Search(testContacts, "@BO"); len(got) != 1 || got[0].Nickname != "bob" {
+		t.Fatalf("unexpected search result: %+v", got)
+	}
+	if got := Search(testContacts, "example"); len(got) != 2 {
+		t.Fatalf("expected url match, got %+v", got)
+	}
+	if got := Search(testContacts, ""); len(got) != len(testContacts) {
+		t.Fatalf("expected empty query to match everything")
+	}
+}
+
+func TestExpandPersonTags(t *testing.T) {
+	content := "Lunch with @jane and @Bob. Mail me at me@jane.example, ignore @nourl and @stranger"
+	doc := util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"content":  {content},
+		"category": {"food", "jane"},
+	}}
+
+	ExpandPersonTags(&doc, testContacts)
+
+	want := []any{
+		"food",
+		personTag(&testContacts[0]),
+		personTag(&testContacts[1]),
+	}
+	if !reflect.DeepEqual(doc.Properties["category"], want) {
+		t.Fatalf("unexpected categories: %#v", doc.Properties["category"])
+	}
+	if doc.Properties["content"][0] != content {
+		t.Fatalf("expected content to be left untouched")
+	}
+	if err := util.ValidateMf2(doc); err != nil {
+		t.Fatalf("expanded document is invalid: %v", err)
+	}
+}
+
+func TestExpandPersonTags_HtmlContentWithoutCategories(t *testing.T) {
+	doc := util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"content": {map[string]any{"html": "<p>Thanks <a href=\"https://bob.example\">@bob</a>!</p>"}},
+	}}
+
+	ExpandPersonTags(&doc, testContacts)
+
+	if cats := doc.Properties["category"]; len(cats) != 1 || !reflect.DeepEqual(cats[0], personTag(&testContacts[1])) {
+		t.Fatalf("unexpected categories: %#v", cats)
+	}
+}
@@ -0,0 +1,73 @@
+package contact
+
+import (
+	"regexp"
+
+	"github.com/indieinfra/scribble/server/util"
+)
+
+// mentionPattern matches @nickname mentions that are not part of an email address or URL.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@/.])@([A-Za-z0-9_](?:[A-Za-z0-9_.-]*[A-Za-z0-9_])?)`)
+
+// maxMentionWords caps how much content is scanned for mentions.
+const maxMentionWords = 10_000
+
+// ExpandPersonTags turns references to known contacts into h-card person tags in the category
+// property. Category values equal to a contact's nickname are replaced by the contact's h-card, and
+// every @nickname mention in the content adds one. The content itself is left untouched so the raw
+// text stays readable. Contacts without a url cannot be person-tagged and are ignored.
+func ExpandPersonTags(doc *util.Mf2Document, contacts []Contact) {
+	if len(contacts) == 0 {
+		return
+	}
+
+	seen := map[string]bool{}
+	for _, v := range doc.Properties["category"] {
+		if s, ok := v.(string); ok {
+			seen[s] = true
+		}
+	}
+
+	var categories []any
+	for _, v := range doc.Properties["category"] {
+		if s, ok := v.(string); ok {
+			if c := findNickname(contacts, s); c != nil && c.Url != "" {
+				if !seen[c.Url] {
+					seen[c.Url] = true
+					categories = append(categories, personTag(c))
+				}
+				continue
+			}
+		}
+		categories = append(categories, v)
+	}
+
+	text := util.PropertyText(doc.Properties["content"], maxMentionWords)
+	for _, m := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		c := findNickname(contacts, m[1])
+		if c == nil || c.Url == "" || seen[c.Url] {
+			continue
+		}
+		seen[c.Url] = true
+		categories = append(categories, personTag(c))
+	}
+
+	if len(categories) > 0 {
+		doc.Properties["category"] = categories
+	}
+}
+
+func personTag(c *Contact) map[string]any {
+	props := map[string]any{
+		"name": []any{c.Name},
+		"url":  []any{c.Url},
+	}
+	if c.Photo != "" {
+		props["photo"] = []any{c.Photo}
+	}
+
+	return map[string]any{
+		"type":       []any{"h-card"},
+		"properties": props,
+	}
+}
@@ -0,0 +1,263 @@
+// Package expiry takes posts down once their "expires" property has passed.
+package expiry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+	"github.com/indieinfra/scribble/storage/media"
+)
+
+// JobKind is the job kind used to take down a single post.
+const JobKind = "expire"
+
+// Property holds the time a post expires, formatted as RFC 3339.
+const Property = "expires"
+
+// mediaProperties are the properties whose values may point at uploaded media.
+var mediaProperties = []string{"photo", "video", "audio"}
+
+type jobPayload struct {
+	Url     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// Entry is a post waiting to expire.
+type Entry struct {
+	Url     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// Expirer schedules a job for every post with an expiry and soft-deletes the post when it runs.
+// Updating or removing the expires property supersedes the job that was scheduled before.
+type Expirer struct {
+	cfg      *config.Expiry
+	defaults map[string]time.Duration
+	store    content.ContentStore
+	media    media.MediaStore
+	hooks    *hooks.Hooks
+	jobs     *jobs.Runner
+	now      func() time.Time
+}
+
+// NewExpirer registers the expiry job with runner. Expired posts are deleted from store and
+// announced on h; their media is removed from mediaStore if configured.
+func NewExpirer(cfg *config.Config, store content.ContentStore, mediaStore media.MediaStore, h *hooks.Hooks, runner *jobs.Runner) *Expirer {
+	defaults := map[string]time.Duration{}
+	for _, pt := range cfg.Micropub.PostTypes {
+		if pt.Expires > 0 {
+			defaults[strings.ToLower(pt.Type)] = pt.Expires
+		}
+	}
+
+	e := &Expirer{cfg: &cfg.Expiry, defaults: defaults, store: store, media: mediaStore, hooks: h, jobs: runner, now: time.Now}
+	runner.Register(JobKind, e.runJob)
+
+	return e
+}
+
+// Stamp sets the expires property of a new post. command is the value of mp-expires, either a time
+// in RFC 3339 or a duration such as "24h"; without it, the post type's default applies. Durations
+// count from the published date when that is in the future. A nil expirer leaves doc alone.
+func (e *Expirer) Stamp(doc *util.Mf2Document, command string) error {
+	if e == nil {
+		return nil
+	}
+
+	start := e.now()
+	if published, ok := util.PropertyTime(*doc, "published"); ok && published.After(start) {
+		start = published
+	}
+
+	var expires time.Time
+	switch {
+	case command != "":
+		if d, err := time.ParseDuration(command); err == nil {
+			if d <= 0 {
+				return fmt.Errorf("mp-expires must be a positive duration")
+			}
+			expires = start.Add(d)
+		} else if t, err := time.Parse(time.RFC3339, command); err == nil {
+			expires = t
+		} else {
+			return fmt.Errorf("mp-expires must be a time in RFC 3339 format or a duration such as 24h")
+		}
+	case e.defaults[util.PostType(*doc)] > 0:
+		expires = start.Add(e.defaults[util.PostType(*doc)])
+	default:
+		return nil
+	}
+
+	doc.Properties[Property] = []any{expires.UTC().Format(time.RFC3339)}
+	return nil
+}
+
+// Validate checks the values a client sets the expires property to.
+func Validate(values []any) error {
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a time in RFC 3339 format", Property)
+		}
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return fmt.Errorf("%s must be a time in RFC 3339 format", Property)
+		}
+	}
+
+	return nil
+}
+
+// ContentChanged schedules the takedown of posts whose expiry was set or changed.
+func (e *Expirer) ContentChanged(_ context.Context, ev hooks.Event) {
+	if ev.Action == hooks.ActionDelete || ev.Document == nil || content.IsDeleted(*ev.Document) {
+		return
+	}
+
+	expires, ok := util.PropertyTime(*ev.Document, Property)
+	if !ok {
+		return
+	}
+	if ev.Action == hooks.ActionUndelete && !expires.After(e.now()) {
+		// Bringing back an expired post keeps it up until its expiry is set again.
+		return
+	}
+	if ev.Action == hooks.ActionUpdate && ev.Previous != nil {
+		if before, ok := util.PropertyTime(*ev.Previous, Property); ok && before.Equal(expires) {
+			return
+		}
+	}
+
+	if _, err := e.jobs.Schedule(JobKind, jobPayload{Url: ev.Url, Expires: expires}, expires); err != nil {
+		log.Printf("error: failed to schedule expiry of %q: %v", ev.Url, err)
+	}
+}
+
+// Pending returns the posts that have an expiry and are still up, soonest first. It needs a content
+// store that can list its documents; a nil expirer has none.
+func (e *Expirer) Pending(ctx context.Context) ([]Entry, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	lister, ok := e.store.(content.Lister)
+	if !ok {
+		return nil, fmt.Errorf("content store cannot list its documents")
+	}
+
+	result, err := lister.List(ctx, content.ListOptions{IncludeDrafts: true, IncludeScheduled: true, IncludePrivate: true})
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, doc := range result.Items {
+		expires, ok := util.PropertyTime(doc, Property)
+		if !ok {
+			continue
+		}
+
+		for _, v := range doc.Properties["url"] {
+			if url, ok := v.(string); ok {
+				entries = append(entries, Entry{Url: url, Expires: expires})
+				break
+			}
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if c := a.Expires.Compare(b.Expires); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Url, b.Url)
+	})
+
+	return entries, nil
+}
+
+// Expire soft-deletes the post at url if its expiry is still the one given, so that jobs for an
+// expiry which has since been changed or removed do nothing.
+func (e *Expirer) Expire(ctx context.Context, url string, expires time.Time) error {
+	doc, err := e.store.Get(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	if content.IsDeleted(*doc) {
+		return nil
+	}
+	if current, ok := util.PropertyTime(*doc, Property); !ok || !current.Equal(expires) {
+		return nil
+	}
+
+	if err := e.store.Delete(ctx, url); err != nil {
+		return err
+	}
+	e.hooks.Fire(ctx, hooks.Event{Action: hooks.ActionDelete, Url: url, Previous: doc})
+
+	if e.cfg.DeleteMedia {
+		e.deleteMedia(ctx, *doc)
+	}
+
+	return nil
+}
+
+// deleteMedia removes the post's media, as far as it is held by the media store. A failure only
+// leaves a file behind, so it is logged rather than retried.
+func (e *Expirer) deleteMedia(ctx context.Context, doc util.Mf2Document) {
+	owner, ok := e.media.(media.Owner)
+	if !ok {
+		return
+	}
+
+	for _, property := range mediaProperties {
+		for _, v := range doc.Properties[property] {
+			url := mediaUrl(v)
+			if url == "" || !owner.Owns(url) {
+				continue
+			}
+
+			if err := e.media.Delete(ctx, url); err != nil {
+				log.Printf("error: failed to delete media %q of expired post: %v", url, err)
+			}
+		}
+	}
+}
+
+// mediaUrl returns the URL of a media property value, which is either a plain URL or an object
+// with alt text such as {"value": "...", "alt": "..."}.
+func mediaUrl(v any) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case map[string]any:
+		if s, ok := x["value"].(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+func (e *Expirer) runJob(ctx context.Context, job *jobs.Job) error {
+	var payload jobPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	err := e.Expire(ctx, payload.Url, payload.Expires)
+	if errors.Is(err, content.ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
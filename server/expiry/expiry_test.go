@@ -0,0 +1,144 @@
+package expiry
+
+import (
+	"context"
+	"mime/multipart"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+type memoryStore struct {
+	content.ContentStore
+	docs    map[string]util.Mf2Document
+	deleted []string
+}
+
+func (m *memoryStore) Get(_ context.Context, url string) (*util.Mf2Document, error) {
+	doc, ok := m.docs[url]
+	if !ok {
+		return nil, content.ErrNotFound
+	}
+	return &doc, nil
+}
+
+func (m *memoryStore) Delete(_ context.Context, url string) error {
+	m.deleted = append(m.deleted, url)
+	return nil
+}
+
+type ownedMedia struct{ deleted []string }
+
+func (o *ownedMedia) Upload(context.Context, *multipart.File, *multipart.FileHeader) (string, error) {
+	return "", nil
+}
+func (o *ownedMedia) Delete(_ context.Context, url string) error {
+	o.deleted = append(o.deleted, url)
+	return nil
+}
+func (o *ownedMedia) Owns(url string) bool { return url == "https://media.example.org/a.jpg" }
+
+var now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newExpirer(t *testing.T, cfg *config.Config, store content.ContentStore, media *ownedMedia) (*Expirer, *jobs.Runner) {
+	runner, err := jobs.NewRunner(&config.Jobs{})
+	if err != nil {
+		t.Fatalf("failed to create job runner: %v", err)
+	}
+	e := NewExpirer(cfg, store, media, &hooks.Hooks{}, runner)
+	e.now = func() time.Time { return now }
+	return e, runner
+}
+
+func TestStamp(t *testing.T) {
+	cfg := &config.Config{Micropub: config.Micropub{PostTypes: []config.PostType{{Type: "note", Name: "Note", Expires: 24 * time.Hour}}}}
+	e, _ := newExpirer(t, cfg, nil, nil)
+
+	cases := []struct {
+		name    string
+		props   map[string][]any
+		command string
+		want    string
+	}{
+		{"default", map[string][]any{"content": {"hi"}}, "", "2025-01-02T12:00:00Z"},
+		{"duration", map[string][]any{"content": {"hi"}}, "1h", "2025-01-01T13:00:00Z"},
+		{"time", map[string][]any{"content": {"hi"}}, "2025-02-01T00:00:00Z", "2025-02-01T00:00:00Z"},
+		{"after scheduled publication", map[string][]any{"content": {"hi"}, "published": {"2025-01-05T00:00:00Z"}}, "2h", "2025-01-05T02:00:00Z"},
+		{"no default", map[string][]any{"name": {"Title"}, "content": {"body"}}, "", ""},
+	}
+
+	for _, tc := range cases {
+		doc := util.Mf2Document{Type: []string{"h-entry"}, Properties: tc.props}
+		if err := e.Stamp(&doc, tc.command); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		got := ""
+		if v := doc.Properties[Property]; len(v) == 1 {
+			got = v[0].(string)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+
+	doc := util.Mf2Document{Properties: map[string][]any{}}
+	if err := e.Stamp(&doc, "tomorrow"); err == nil {
+		t.Fatalf("expected error for unparseable mp-expires")
+	}
+}
+
+func TestContentChangedSchedulesOnlyChangedExpiries(t *testing.T) {
+	e, runner := newExpirer(t, &config.Config{}, nil, nil)
+	doc := func(expires string) *util.Mf2Document {
+		return &util.Mf2Document{Properties: map[string][]any{Property: {expires}}}
+	}
+
+	e.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionCreate, Url: "https://example.org/a", Document: doc("2025-01-02T00:00:00Z")})
+	e.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionUpdate, Url: "https://example.org/a", Document: doc("2025-01-02T00:00:00Z"), Previous: doc("2025-01-02T00:00:00Z")})
+	e.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionUpdate, Url: "https://example.org/a", Document: doc("2025-01-03T00:00:00Z"), Previous: doc("2025-01-02T00:00:00Z")})
+	e.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionUndelete, Url: "https://example.org/a", Document: doc("2024-12-31T00:00:00Z")})
+
+	pending := runner.Pending()
+	if len(pending) != 2 {
+		t.Fatalf("expected two expiry jobs, got %+v", pending)
+	}
+	for _, job := range pending {
+		if job.Kind != JobKind || job.NotBefore.Before(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected job %+v", job)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	url := "https://example.org/story"
+	store := &memoryStore{docs: map[string]util.Mf2Document{url: {Properties: map[string][]any{
+		Property: {"2025-01-01T00:00:00Z"},
+		"photo":  {"https://media.example.org/a.jpg", map[string]any{"value": "https://elsewhere.example/b.jpg", "alt": "b"}},
+	}}}}
+	media := &ownedMedia{}
+	e, _ := newExpirer(t, &config.Config{Expiry: config.Expiry{DeleteMedia: true}}, store, media)
+
+	// The expiry was changed since this job was scheduled.
+	if err := e.Expire(context.Background(), url, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.deleted) != 0 {
+		t.Fatalf("expected superseded expiry to do nothing")
+	}
+
+	if err := e.Expire(context.Background(), url, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.deleted) != 1 || store.deleted[0] != url {
+		t.Fatalf("expected post to be deleted, got %v", store.deleted)
+	}
+	if len(media.deleted) != 1 || media.deleted[0] != "https://media.example.org/a.jpg" {
+		t.Fatalf("expected only owned media to be deleted, got %v", media.deleted)
+	}
+}
@@ -0,0 +1,40 @@
+package get
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+)
+
+type Categories struct {
+	Categories []string `json:"categories"`
+}
+
+func HandleCategory(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	if !auth.RequestHasScope(r, auth.ScopeRead) {
+		resp.WriteInsufficientScope(w, "no read scope")
+		return
+	}
+
+	if st.Categories == nil {
+		resp.WriteOK(w, Categories{Categories: []string{}})
+		return
+	}
+
+	q := r.URL.Query()
+
+	limit := 0
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			resp.WriteInvalidRequest(w, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	resp.WriteOK(w, Categories{Categories: st.Categories.Categories(q.Get("search"), limit)})
+}
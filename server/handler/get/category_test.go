@@ -0,0 +1,44 @@
+package get
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/category"
+)
+
+func TestHandleCategory(t *testing.T) {
+	vocab := category.NewVocabulary()
+	vocab.Set("https://example.org/1", util.Mf2Document{Properties: map[string][]any{"category": {"indieweb", "go"}}})
+	vocab.Set("https://example.org/2", util.Mf2Document{Properties: map[string][]any{"category": {"indieweb", "ink"}}})
+	st := &state.ScribbleState{Categories: vocab}
+
+	rr := httptest.NewRecorder()
+	DispatchGet(st)(rr, newReadRequest("/?q=category&search=in"))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var got Categories
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(got.Categories, []string{"indieweb", "ink"}) {
+		t.Fatalf("unexpected categories: %v", got.Categories)
+	}
+}
+
+func TestHandleCategory_Empty(t *testing.T) {
+	rr := httptest.NewRecorder()
+	HandleCategory(&state.ScribbleState{}, rr, newReadRequest("/?q=category"))
+
+	if rr.Code != http.StatusOK || rr.Body.String() != "{\"categories\":[]}\n" {
+		t.Fatalf("unexpected response %d %q", rr.Code, rr.Body.String())
+	}
+}
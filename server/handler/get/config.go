@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/indieinfra/scribble/config"
 	"github.com/indieinfra/scribble/server/resp"
 	"github.com/indieinfra/scribble/server/state"
 )
@@ -20,16 +21,69 @@ type SyndicateTo struct {
 	Service Service `json:"service"`
 }
 
+type PostType struct {
+	Type               string   `json:"type"`
+	Name               string   `json:"name"`
+	Properties         []string `json:"properties,omitempty"`
+	RequiredProperties []string `json:"required-properties,omitempty"`
+}
+
+type Channel struct {
+	Uid  string `json:"uid"`
+	Name string `json:"name"`
+}
+
 type Config struct {
 	MediaEndpoint string        `json:"media-endpoint"`
 	SyndicateTo   []SyndicateTo `json:"syndicate-to"`
+	PostTypes     []PostType    `json:"post-types"`
+	Channels      []Channel     `json:"channels"`
 }
 
 func HandleConfig(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
 	cfgOut := Config{
 		MediaEndpoint: fmt.Sprintf("%v/media", st.Cfg.Server.PublicUrl),
-		SyndicateTo:   []SyndicateTo{},
+		SyndicateTo:   syndicateTo(st),
+		PostTypes:     postTypes(&st.Cfg.Micropub),
+		Channels:      channels(&st.Cfg.Micropub),
 	}
 
 	resp.WriteOK(w, cfgOut)
 }
+
+func HandlePostTypes(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	resp.WriteOK(w, map[string]any{
+		"post-types": postTypes(&st.Cfg.Micropub),
+	})
+}
+
+func HandleChannel(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	resp.WriteOK(w, map[string]any{
+		"channels": channels(&st.Cfg.Micropub),
+	})
+}
+
+func syndicateTo(st *state.ScribbleState) []SyndicateTo {
+	targets := st.Syndication.Targets()
+	out := make([]SyndicateTo, 0, len(targets))
+	for _, t := range targets {
+		out = append(out, SyndicateTo{Uid: t.Uid, Name: t.Name, Service: Service{Name: t.Service.Name, Url: t.Service.Url, Photo: t.Service.Photo}})
+	}
+	return out
+}
+
+func postTypes(cfg *config.Micropub) []PostType {
+	out := make([]PostType, 0, len(cfg.PostTypes))
+	for _, pt := range cfg.PostTypes {
+		out = append(out, PostType{Type: pt.Type, Name: pt.Name, Properties: pt.Properties, RequiredProperties: pt.RequiredProperties})
+	}
+	return out
+}
+
+func channels(cfg *config.Micropub) []Channel {
+	out := make([]Channel, 0, len(cfg.Channels))
+	for _, ch := range cfg.Channels {
+		out = append(out, Channel{Uid: ch.Uid, Name: ch.Name})
+	}
+	return out
+}
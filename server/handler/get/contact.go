@@ -0,0 +1,35 @@
+package get
+
+import (
+	"net/http"
+
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/contact"
+	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+)
+
+type Contacts struct {
+	Contacts []contact.Contact `json:"contacts"`
+}
+
+func HandleContact(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	if !auth.RequestHasScope(r, auth.ScopeRead) {
+		resp.WriteInsufficientScope(w, "no read scope")
+		return
+	}
+
+	if st.Contacts == nil {
+		resp.WriteOK(w, Contacts{Contacts: []contact.Contact{}})
+		return
+	}
+
+	all, err := st.Contacts.All(r.Context())
+	if err != nil && all == nil {
+		common.LogAndWriteError(w, r, "load contacts", err)
+		return
+	}
+
+	resp.WriteOK(w, Contacts{Contacts: contact.Search(all, r.URL.Query().Get("search"))})
+}
@@ -0,0 +1,34 @@
+package get
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/contact"
+	"github.com/indieinfra/scribble/server/state"
+)
+
+func TestHandleContact(t *testing.T) {
+	st := &state.ScribbleState{Contacts: contact.NewDirectory(&config.Contacts{Entries: []config.Contact{
+		{Name: "Jane Doe", Nickname: "jane", Url: "https://jane.example", Silos: map[string]string{"github": "janedoe"}},
+		{Name: "Bob", Nickname: "bob", Url: "https://bob.example"},
+	}}, nil)}
+
+	rr := httptest.NewRecorder()
+	DispatchGet(st)(rr, newReadRequest("/?q=contact&search=jan"))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var got Contacts
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got.Contacts) != 1 || got.Contacts[0].Silos["github"] != "janedoe" {
+		t.Fatalf("unexpected contacts: %+v", got.Contacts)
+	}
+}
@@ -0,0 +1,35 @@
+package get
+
+import (
+	"net/http"
+
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/expiry"
+	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+)
+
+type ExpiringList struct {
+	Items []expiry.Entry `json:"items"`
+}
+
+// HandleExpiring lists the posts that are due to be taken down, soonest first.
+func HandleExpiring(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	if !auth.RequestHasScope(r, auth.ScopeRead) {
+		resp.WriteInsufficientScope(w, "no read scope")
+		return
+	}
+
+	items, err := st.Expiry.Pending(r.Context())
+	if err != nil {
+		common.LogAndWriteError(w, r, "list expiring posts", err)
+		return
+	}
+
+	if items == nil {
+		items = []expiry.Entry{}
+	}
+
+	resp.WriteOK(w, ExpiringList{Items: items})
+}
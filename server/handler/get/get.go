@@ -10,7 +10,16 @@ import (
 
 func DispatchGet(st *state.ScribbleState) http.HandlerFunc {
 	handlers := map[string]func(*state.ScribbleState, http.ResponseWriter, *http.Request){
+		"category":     HandleCategory,
+		"channel":      HandleChannel,
 		"config":       HandleConfig,
+		"contact":      HandleContact,
+		"expiring":     HandleExpiring,
+		"mentions":     HandleMentions,
+		"post-types":   HandlePostTypes,
+		"rate-limits":  HandleRateLimits,
+		"scheduled":    HandleScheduled,
+		"search":       HandleSearch,
 		"source":       HandleSource,
 		"syndicate-to": HandleSyndicateTo,
 	}
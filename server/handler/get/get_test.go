@@ -7,7 +7,10 @@ import (
 	"testing"
 
 	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/jobs"
 	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/syndication"
 )
 
 func newGetState() *state.ScribbleState {
@@ -56,4 +59,88 @@ func TestHandleSyndicateTo(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected 200, got %d", rr.Code)
 	}
+	if body := rr.Body.String(); body != "{\"syndicate-to\":[]}\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	st.Syndication, _ = syndication.NewDispatcher(&config.Syndication{}, nil, newRunner(t))
+	st.Syndication.AddTarget(&syndication.Target{Uid: "masto", Name: "@me", Service: config.SyndicationService{Name: "Mastodon"}})
+
+	rr = httptest.NewRecorder()
+	HandleSyndicateTo(st, rr, req)
+
+	var out struct {
+		SyndicateTo []SyndicateTo `json:"syndicate-to"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &out); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(out.SyndicateTo) != 1 || out.SyndicateTo[0].Uid != "masto" || out.SyndicateTo[0].Service.Name != "Mastodon" {
+		t.Fatalf("unexpected targets: %+v", out.SyndicateTo)
+	}
+}
+
+func TestPostTypesAndChannels(t *testing.T) {
+	st := newGetState()
+	st.Cfg.Micropub = config.Micropub{
+		PostTypes: []config.PostType{{Type: "note", Name: "Note"}},
+		Channels:  []config.Channel{{Uid: "notes", Name: "Notes", Path: "notes"}},
+	}
+
+	rr := httptest.NewRecorder()
+	DispatchGet(st)(rr, httptest.NewRequest(http.MethodGet, "/?q=config", nil))
+	var cfg Config
+	if err := json.Unmarshal(rr.Body.Bytes(), &cfg); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if len(cfg.PostTypes) != 1 || cfg.PostTypes[0].Type != "note" || len(cfg.Channels) != 1 || cfg.Channels[0].Uid != "notes" {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	rr = httptest.NewRecorder()
+	DispatchGet(st)(rr, httptest.NewRequest(http.MethodGet, "/?q=post-types", nil))
+	if body := rr.Body.String(); body != "{\"post-types\":[{\"type\":\"note\",\"name\":\"Note\"}]}\n" {
+		t.Fatalf("unexpected post-types response %q", body)
+	}
+
+	rr = httptest.NewRecorder()
+	DispatchGet(st)(rr, httptest.NewRequest(http.MethodGet, "/?q=channel", nil))
+	if body := rr.Body.String(); body != "{\"channels\":[{\"uid\":\"notes\",\"name\":\"Notes\"}]}\n" {
+		t.Fatalf("unexpected channel response %q", body)
+	}
+}
+
+func newRunner(t *testing.T) *jobs.Runner {
+	runner, err := jobs.NewRunner(&config.Jobs{})
+	if err != nil {
+		t.Fatalf("failed to create job runner: %v", err)
+	}
+	return runner
+}
+
+func TestHandleScheduled(t *testing.T) {
+	st := newGetState()
+	rr := httptest.NewRecorder()
+	DispatchGet(st)(rr, newReadRequest("/?q=scheduled"))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if body := rr.Body.String(); body != "{\"items\":[]}\n" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestDispatchGet_ContentQueriesRequireReadScope(t *testing.T) {
+	st := newGetState()
+	for _, q := range []string{"search&query=x", "scheduled", "expiring", "mentions", "contact", "category"} {
+		r := httptest.NewRequest(http.MethodGet, "/?q="+q, nil)
+		r = r.WithContext(auth.AddToken(r.Context(), &auth.TokenDetails{Scope: "create"}))
+		rr := httptest.NewRecorder()
+		DispatchGet(st)(rr, r)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("q=%s: expected 401 without read scope, got %d", q, rr.Code)
+		}
+	}
 }
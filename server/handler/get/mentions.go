@@ -0,0 +1,42 @@
+package get
+
+import (
+	"net/http"
+
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+type MentionList struct {
+	Items []content.Mention `json:"items"`
+}
+
+// HandleMentions lists received webmentions. With a url, the mentions of that post are returned;
+// status=pending without a url gives the moderation queue.
+func HandleMentions(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	if !auth.RequestHasScope(r, auth.ScopeRead) {
+		resp.WriteInsufficientScope(w, "no read scope")
+		return
+	}
+
+	if st.Mentions == nil {
+		resp.WriteInvalidRequest(w, "receiving webmentions is not enabled")
+		return
+	}
+
+	q := r.URL.Query()
+	mentions, err := st.Mentions.Mentions(r.Context(), content.MentionFilter{Target: q.Get("url"), Status: q.Get("status")})
+	if err != nil {
+		common.LogAndWriteError(w, r, "list mentions", err)
+		return
+	}
+
+	if mentions == nil {
+		mentions = []content.Mention{}
+	}
+
+	resp.WriteOK(w, MentionList{Items: mentions})
+}
@@ -0,0 +1,14 @@
+package get
+
+import (
+	"net/http"
+
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+)
+
+// HandleRateLimits describes the rate limits in force, how much they have been used, and what is
+// left of them for the caller. Other clients' tokens, client IDs and addresses are not revealed.
+func HandleRateLimits(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	resp.WriteOK(w, st.Limits.Stats(r))
+}
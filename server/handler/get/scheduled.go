@@ -0,0 +1,29 @@
+package get
+
+import (
+	"net/http"
+
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/schedule"
+	"github.com/indieinfra/scribble/server/state"
+)
+
+type ScheduledList struct {
+	Items []schedule.Entry `json:"items"`
+}
+
+// HandleScheduled lists the posts waiting for their published date, soonest first.
+func HandleScheduled(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	if !auth.RequestHasScope(r, auth.ScopeRead) {
+		resp.WriteInsufficientScope(w, "no read scope")
+		return
+	}
+
+	items := st.Scheduler.Pending()
+	if items == nil {
+		items = []schedule.Entry{}
+	}
+
+	resp.WriteOK(w, ScheduledList{Items: items})
+}
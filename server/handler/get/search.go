@@ -0,0 +1,53 @@
+package get
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/storage/search"
+)
+
+type SearchResults struct {
+	Items []search.Result `json:"items"`
+}
+
+func HandleSearch(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	if !auth.RequestHasScope(r, auth.ScopeRead) {
+		resp.WriteInsufficientScope(w, "no read scope")
+		return
+	}
+
+	if st.SearchIndex == nil {
+		resp.WriteInvalidRequest(w, "search is not enabled on this server")
+		return
+	}
+
+	q := r.URL.Query()
+	query := q.Get("query")
+	if query == "" {
+		resp.WriteInvalidRequest(w, "search requires a query")
+		return
+	}
+
+	limit := defaultListLimit
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			resp.WriteInvalidRequest(w, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxListLimit)
+	}
+
+	results, err := st.SearchIndex.Search(r.Context(), query, limit)
+	if err != nil {
+		common.LogAndWriteError(w, r, "search", err)
+		return
+	}
+
+	resp.WriteOK(w, SearchResults{Items: results})
+}
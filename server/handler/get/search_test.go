@@ -0,0 +1,53 @@
+package get
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/search"
+)
+
+func TestHandleSearch(t *testing.T) {
+	idx, _ := search.NewEmbeddedIndex("")
+	_ = idx.Index(context.Background(), "https://example.org/a", util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"walking in the rain"}}})
+	_ = idx.Index(context.Background(), "https://example.org/b", util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"sunny day"}}})
+	st := &state.ScribbleState{SearchIndex: idx}
+
+	rr := httptest.NewRecorder()
+	HandleSearch(st, rr, newReadRequest("/?q=search&query=Rain"))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var got SearchResults
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got.Items) != 1 || got.Items[0].Url != "https://example.org/a" {
+		t.Fatalf("unexpected results: %+v", got.Items)
+	}
+}
+
+func TestHandleSearch_Errors(t *testing.T) {
+	idx, _ := search.NewEmbeddedIndex("")
+
+	cases := map[string]*state.ScribbleState{
+		"/?q=search&query=x":         {},
+		"/?q=search":                 {SearchIndex: idx},
+		"/?q=search&query=x&limit=0": {SearchIndex: idx},
+	}
+
+	for target, st := range cases {
+		rr := httptest.NewRecorder()
+		HandleSearch(st, rr, newReadRequest(target))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", target, rr.Code)
+		}
+	}
+}
@@ -1,34 +1,143 @@
 package get
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 
+	"github.com/indieinfra/scribble/server/auth"
 	"github.com/indieinfra/scribble/server/handler/common"
 	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/schedule"
 	"github.com/indieinfra/scribble/server/state"
 	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
 )
 
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+type Paging struct {
+	After string `json:"after,omitempty"`
+}
+
+type SourceList struct {
+	Items  []util.Mf2Document `json:"items"`
+	Paging Paging             `json:"paging"`
+}
+
 func HandleSource(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
+	if !auth.RequestHasScope(r, auth.ScopeRead) {
+		resp.WriteInsufficientScope(w, "no read scope")
+		return
+	}
+
 	q := r.URL.Query()
 
 	url := q.Get("url")
 	if url == "" {
-		resp.WriteInvalidRequest(w, "source requires a url")
+		handleSourceList(st, w, r, q)
 		return
 	}
 
 	doc, err := st.ContentStore.Get(r.Context(), url)
+	if err == nil && content.Visibility(*doc) == content.VisibilityPrivate && !canReadPrivate(st, r) {
+		// Don't reveal that the post exists to clients that may not read it.
+		err = content.ErrNotFound
+	}
 	if err != nil {
 		common.LogAndWriteError(w, r, "get content", err)
 		return
 	}
 
-	props := q["properties"]
-	if len(props) == 0 {
-		resp.WriteOK(w, doc)
+	resp.WriteOK(w, filterProperties(doc, q["properties"]))
+}
+
+// handleSourceList implements the source query without a url, which lists recent posts.
+func handleSourceList(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, q url.Values) {
+	lister, ok := st.ContentStore.(content.Lister)
+	if !ok {
+		resp.WriteInvalidRequest(w, "source requires a url; this content store does not support listing")
+		return
+	}
+
+	opts, err := parseListOptions(q)
+	if err != nil {
+		resp.WriteInvalidRequest(w, err.Error())
 		return
 	}
+	opts.IncludePrivate = canReadPrivate(st, r)
+
+	result, err := lister.List(r.Context(), opts)
+	if err != nil {
+		common.LogAndWriteError(w, r, "list content", err)
+		return
+	}
+
+	out := SourceList{Items: make([]util.Mf2Document, 0, len(result.Items)), Paging: Paging{After: result.After}}
+	for _, doc := range result.Items {
+		out.Items = append(out.Items, *filterProperties(&doc, q["properties"]))
+	}
+
+	resp.WriteOK(w, out)
+}
+
+// canReadPrivate reports whether the request's client may read private posts: one listed in
+// private_readers or, when none are listed, any client holding a token of the site's owner.
+func canReadPrivate(st *state.ScribbleState, r *http.Request) bool {
+	token := auth.GetToken(r.Context())
+	if token == nil {
+		return false
+	}
+
+	readers := st.Cfg.Micropub.PrivateReaders
+	if len(readers) == 0 {
+		return token.HasMe(st.Cfg.Micropub.MeUrl)
+	}
+
+	return slices.Contains(readers, token.ClientId)
+}
+
+func parseListOptions(q url.Values) (content.ListOptions, error) {
+	opts := content.ListOptions{
+		Limit:            defaultListLimit,
+		After:            q.Get("after"),
+		PostType:         q.Get("post-type"),
+		IncludeDeleted:   q.Get("include-deleted") == "true",
+		IncludeDrafts:    q.Get("include-drafts") == "true",
+		IncludeScheduled: q.Get("include-scheduled") == "true",
+	}
+
+	if raw := q.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return opts, fmt.Errorf("limit must be a positive integer")
+		}
+		opts.Limit = min(limit, maxListLimit)
+	}
+
+	if raw := q.Get("offset"); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return opts, fmt.Errorf("offset must be a non-negative integer")
+		}
+		opts.Offset = offset
+	}
+
+	return opts, nil
+}
+
+// filterProperties returns the document restricted to the requested properties, or the whole
+// document when no properties were requested. The server's own bookkeeping is never included.
+func filterProperties(doc *util.Mf2Document, props []string) *util.Mf2Document {
+	doc = schedule.WithoutHeldTargets(doc)
+	if len(props) == 0 {
+		return doc
+	}
 
 	filtered := &util.Mf2Document{Type: doc.Type, Properties: map[string][]any{}}
 	for _, p := range props {
@@ -37,5 +146,5 @@ func HandleSource(st *state.ScribbleState, w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	resp.WriteOK(w, filtered)
+	return filtered
 }
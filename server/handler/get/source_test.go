@@ -9,6 +9,9 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/schedule"
 	"github.com/indieinfra/scribble/server/state"
 	"github.com/indieinfra/scribble/server/util"
 	"github.com/indieinfra/scribble/storage/content"
@@ -41,13 +44,19 @@ func (f *fakeContentStore) Get(ctx context.Context, url string) (*util.Mf2Docume
 
 func (f *fakeContentStore) ExistsBySlug(context.Context, string) (bool, error) { return false, nil }
 
+// newReadRequest returns a request carrying a token with the read scope.
+func newReadRequest(target string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	return r.WithContext(auth.AddToken(r.Context(), &auth.TokenDetails{ClientId: "https://app.example/", Scope: "read"}))
+}
+
 func TestHandleSource_Success(t *testing.T) {
 	doc := &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"name": []any{"hello"}}}
 	st := &state.ScribbleState{ContentStore: &fakeContentStore{getFn: func(ctx context.Context, url string) (*util.Mf2Document, error) {
 		return doc, nil
 	}}}
 
-	r := httptest.NewRequest(http.MethodGet, "/?q=source&url=https://example.org/post", nil)
+	r := newReadRequest("/?q=source&url=https://example.org/post")
 	w := httptest.NewRecorder()
 
 	HandleSource(st, w, r)
@@ -69,12 +78,39 @@ func TestHandleSource_Success(t *testing.T) {
 	}
 }
 
+func TestHandleSource_HidesHeldTargets(t *testing.T) {
+	doc := &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"name":                       {"later"},
+		"post-status":                {"scheduled"},
+		schedule.HeldTargetsProperty: {"masto"},
+	}}
+	st := &state.ScribbleState{ContentStore: &fakeContentStore{getFn: func(ctx context.Context, url string) (*util.Mf2Document, error) {
+		return doc, nil
+	}}}
+
+	for _, target := range []string{"/?q=source&url=https://example.org/post", "/?q=source&url=https://example.org/post&properties[]=" + schedule.HeldTargetsProperty} {
+		w := httptest.NewRecorder()
+		HandleSource(st, w, newReadRequest(target))
+
+		var got util.Mf2Document
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if _, ok := got.Properties[schedule.HeldTargetsProperty]; ok {
+			t.Fatalf("%s: expected held targets to be hidden, got %+v", target, got.Properties)
+		}
+	}
+	if len(doc.Properties[schedule.HeldTargetsProperty]) != 1 {
+		t.Fatalf("expected the stored document to be left alone, got %+v", doc.Properties)
+	}
+}
+
 func TestHandleSource_NotFound(t *testing.T) {
 	st := &state.ScribbleState{ContentStore: &fakeContentStore{getFn: func(ctx context.Context, url string) (*util.Mf2Document, error) {
 		return nil, content.ErrNotFound
 	}}}
 
-	r := httptest.NewRequest(http.MethodGet, "/?q=source&url=https://example.org/missing", nil)
+	r := newReadRequest("/?q=source&url=https://example.org/missing")
 	w := httptest.NewRecorder()
 
 	HandleSource(st, w, r)
@@ -89,7 +125,7 @@ func TestHandleSource_InternalError(t *testing.T) {
 		return nil, fmt.Errorf("boom")
 	}}}
 
-	r := httptest.NewRequest(http.MethodGet, "/?q=source&url=https://example.org/error", nil)
+	r := newReadRequest("/?q=source&url=https://example.org/error")
 	w := httptest.NewRecorder()
 
 	HandleSource(st, w, r)
@@ -102,7 +138,7 @@ func TestHandleSource_InternalError(t *testing.T) {
 func TestHandleSource_MissingURL(t *testing.T) {
 	st := &state.ScribbleState{ContentStore: &fakeContentStore{}}
 
-	r := httptest.NewRequest(http.MethodGet, "/?q=source", nil)
+	r := newReadRequest("/?q=source")
 	w := httptest.NewRecorder()
 
 	HandleSource(st, w, r)
@@ -111,3 +147,119 @@ func TestHandleSource_MissingURL(t *testing.T) {
 		t.Fatalf("expected 400, got %d", w.Result().StatusCode)
 	}
 }
+
+type fakeListerStore struct {
+	fakeContentStore
+	lastOpts content.ListOptions
+	result   *content.ListResult
+}
+
+func (f *fakeListerStore) List(_ context.Context, opts content.ListOptions) (*content.ListResult, error) {
+	f.lastOpts = opts
+	return f.result, nil
+}
+
+func TestHandleSource_List(t *testing.T) {
+	store := &fakeListerStore{result: &content.ListResult{
+		Items: []util.Mf2Document{{Type: []string{"h-entry"}, Properties: map[string][]any{"url": {"https://example.org/a"}, "name": {"A"}}}},
+		After: "https://example.org/a",
+	}}
+	st := &state.ScribbleState{Cfg: &config.Config{}, ContentStore: store}
+
+	r := newReadRequest("/?q=source&limit=1&offset=2&post-type=note&properties=url")
+	w := httptest.NewRecorder()
+
+	HandleSource(st, w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if store.lastOpts.Limit != 1 || store.lastOpts.Offset != 2 || store.lastOpts.PostType != "note" || store.lastOpts.IncludeDeleted {
+		t.Fatalf("unexpected list options: %+v", store.lastOpts)
+	}
+
+	var got SourceList
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(got.Items) != 1 || got.Paging.After != "https://example.org/a" {
+		t.Fatalf("unexpected listing: %+v", got)
+	}
+	if _, ok := got.Items[0].Properties["name"]; ok {
+		t.Fatalf("expected properties filter to apply to items")
+	}
+}
+
+func TestHandleSource_ListInvalidLimit(t *testing.T) {
+	st := &state.ScribbleState{ContentStore: &fakeListerStore{}}
+
+	r := newReadRequest("/?q=source&limit=zero")
+	w := httptest.NewRecorder()
+
+	HandleSource(st, w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestHandleSource_RequiresReadScope(t *testing.T) {
+	st := &state.ScribbleState{ContentStore: &fakeContentStore{}}
+
+	r := httptest.NewRequest(http.MethodGet, "/?q=source&url=https://example.org/post", nil)
+	r = r.WithContext(auth.AddToken(r.Context(), &auth.TokenDetails{Scope: "create"}))
+	w := httptest.NewRecorder()
+
+	HandleSource(st, w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without read scope, got %d", w.Code)
+	}
+}
+
+func TestHandleSource_Private(t *testing.T) {
+	store := &fakeListerStore{
+		fakeContentStore: fakeContentStore{getFn: func(ctx context.Context, url string) (*util.Mf2Document, error) {
+			return &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"visibility": {"private"}}}, nil
+		}},
+		result: &content.ListResult{},
+	}
+	st := &state.ScribbleState{Cfg: &config.Config{}, ContentStore: store}
+	st.Cfg.Micropub.MeUrl = "https://example.org"
+
+	w := httptest.NewRecorder()
+	HandleSource(st, w, newReadRequest("/?q=source&url=https://example.org/secret"))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected private post to be hidden from other identities without a policy, got %d", w.Code)
+	}
+
+	owner := httptest.NewRequest(http.MethodGet, "/?q=source&url=https://example.org/secret", nil)
+	owner = owner.WithContext(auth.AddToken(owner.Context(), &auth.TokenDetails{Me: "https://example.org/", ClientId: "https://app.example/", Scope: "read"}))
+	w = httptest.NewRecorder()
+	HandleSource(st, w, owner)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected private post to be readable by the owner without a policy, got %d", w.Code)
+	}
+
+	st.Cfg.Micropub.PrivateReaders = []string{"https://other.example/"}
+
+	w = httptest.NewRecorder()
+	HandleSource(st, w, newReadRequest("/?q=source&url=https://example.org/secret"))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a client not allowed to read private posts, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	HandleSource(st, w, newReadRequest("/?q=source"))
+	if w.Code != http.StatusOK || store.lastOpts.IncludePrivate {
+		t.Fatalf("expected listing without private posts, got %d %+v", w.Code, store.lastOpts)
+	}
+
+	st.Cfg.Micropub.PrivateReaders = append(st.Cfg.Micropub.PrivateReaders, "https://app.example/")
+
+	w = httptest.NewRecorder()
+	HandleSource(st, w, newReadRequest("/?q=source"))
+	if !store.lastOpts.IncludePrivate {
+		t.Fatalf("expected listing to include private posts for an allowed client")
+	}
+}
@@ -8,8 +8,7 @@ import (
 )
 
 func HandleSyndicateTo(st *state.ScribbleState, w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement syndicate-to retrieval
 	resp.WriteOK(w, map[string]any{
-		"syndicate-to": []any{},
+		"syndicate-to": syndicateTo(st),
 	})
 }
@@ -0,0 +1,25 @@
+package post
+
+import (
+	"net/http"
+
+	"github.com/indieinfra/scribble/server/audit"
+	"github.com/indieinfra/scribble/server/state"
+)
+
+// beginAudit starts the audit entry for an action, including which properties an update touches.
+// Requests refused by a policy or rate limit are recorded too, with the status they got.
+func beginAudit(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, action string, body *ParsedBody) *audit.Operation {
+	url, _ := body.Data["url"].(string)
+	op := st.Audit.Begin(w, r, action, url)
+
+	if action == "update" {
+		op.SetChanges(&audit.Changes{
+			Replace: updatedProperties(body, "replace"),
+			Add:     updatedProperties(body, "add"),
+			Delete:  updatedProperties(body, "delete"),
+		})
+	}
+
+	return op
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 
 	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/policy"
 	"github.com/indieinfra/scribble/server/resp"
 	"github.com/indieinfra/scribble/server/util"
 )
@@ -43,10 +45,16 @@ func ReadBody(cfg *config.Config, w http.ResponseWriter, r *http.Request) (*Pars
 	return nil, false
 }
 
+// maxPayloadSize is the body limit for the request, which a policy may override for the client of
+// a token sent in the Authorization header.
+func maxPayloadSize(cfg *config.Config, r *http.Request) uint {
+	return policy.MaxPayloadSize(cfg.Micropub.Policies, auth.GetToken(r.Context()), cfg.Server.Limits.MaxPayloadSize)
+}
+
 func readJsonBody(cfg *config.Config, w http.ResponseWriter, r *http.Request) map[string]any {
 	out := make(map[string]any)
 
-	r.Body = http.MaxBytesReader(w, r.Body, int64(cfg.Server.Limits.MaxPayloadSize))
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxPayloadSize(cfg, r)))
 	if err := json.NewDecoder(r.Body).Decode(&out); err != nil {
 		resp.WriteInvalidRequest(w, "Invalid JSON body")
 		return nil
@@ -58,7 +66,7 @@ func readJsonBody(cfg *config.Config, w http.ResponseWriter, r *http.Request) ma
 func readFormUrlEncodedBody(cfg *config.Config, w http.ResponseWriter, r *http.Request) map[string]any {
 	out := make(map[string]any)
 
-	r.Body = http.MaxBytesReader(w, r.Body, int64(cfg.Server.Limits.MaxPayloadSize))
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxPayloadSize(cfg, r)))
 	if err := r.ParseForm(); err != nil {
 		resp.WriteInvalidRequest(w, fmt.Sprintf("Invalid form body: %v", err))
 		return nil
@@ -3,23 +3,33 @@ package post
 import (
 	"context"
 	"fmt"
+	"maps"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/indieinfra/scribble/config"
 	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/author"
+	"github.com/indieinfra/scribble/server/contact"
+	"github.com/indieinfra/scribble/server/expiry"
 	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/hooks"
 	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/schedule"
 	"github.com/indieinfra/scribble/server/state"
 	"github.com/indieinfra/scribble/server/util"
 	"github.com/indieinfra/scribble/storage/content"
 )
 
 func Create(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, body *ParsedBody) {
-	if !requireScope(w, r, auth.ScopeCreate) {
+	// A token with only the draft scope may create posts, but they are kept as drafts.
+	draftOnly := !auth.RequestHasScope(r, auth.ScopeCreate) && auth.RequestHasScope(r, auth.ScopeDraft)
+	if !draftOnly && !requireScope(w, r, auth.ScopeCreate) {
 		return
 	}
 
@@ -31,16 +41,66 @@ func Create(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, bod
 		return
 	}
 
+	commands := processMpProperties(&document)
+	// Only the scheduler holds syndication targets; mp-syndicate-to is how clients ask for them.
+	delete(document.Properties, schedule.HeldTargetsProperty)
+
+	if err := validatePostStatus(document.Properties["post-status"]); err != nil {
+		resp.WriteInvalidRequest(w, err.Error())
+		return
+	}
+	if err := validateVisibility(document.Properties["visibility"]); err != nil {
+		resp.WriteInvalidRequest(w, err.Error())
+		return
+	}
+	// Expiry is settled before anything is uploaded or fetched, so a bad value leaves nothing behind.
+	// It is stamped on a copy that names the uploads, as they decide the post type's default.
+	if err := expiry.Validate(document.Properties[expiry.Property]); err != nil {
+		resp.WriteInvalidRequest(w, err.Error())
+		return
+	}
+	typed := withUploads(document, body.Files)
+	if err := st.Expiry.Stamp(&typed, commands.Expires); err != nil {
+		resp.WriteInvalidRequest(w, err.Error())
+		return
+	}
+	if expires, ok := typed.Properties[expiry.Property]; ok {
+		document.Properties[expiry.Property] = expires
+	}
+	if draftOnly {
+		document.Properties["post-status"] = []any{"draft"}
+	}
+	if token := auth.GetToken(r.Context()); token != nil && author.Enabled(&st.Cfg.Micropub) {
+		// Posts are attributed to whoever the token belongs to, never to someone else.
+		if values := document.Properties["author"]; len(values) == 0 {
+			document.Properties["author"] = []any{author.Card(&st.Cfg.Micropub, token.Me)}
+		} else if !author.Claims(token, values) {
+			resp.WriteForbidden(w, "the author of a post must be the token's owner")
+			return
+		}
+	}
+
+	if commands.Channel != "" {
+		if !slices.ContainsFunc(st.Cfg.Micropub.Channels, func(ch config.Channel) bool { return ch.Uid == commands.Channel }) {
+			resp.WriteInvalidRequest(w, fmt.Sprintf("Unknown channel: %q", commands.Channel))
+			return
+		}
+		document.Properties["channel"] = []any{commands.Channel}
+	}
+
+	for _, uid := range commands.SyndicateTo {
+		if st.Syndication.Target(uid) == nil {
+			resp.WriteInvalidRequest(w, fmt.Sprintf("Unknown syndication target: %q", uid))
+			return
+		}
+	}
+
 	for _, pf := range body.Files {
 		if pf.Header == nil || pf.File == nil {
 			continue
 		}
 
-		mediaProperty := pf.Field
-		if mediaProperty == "" || mediaProperty == "file" {
-			mediaProperty = mediaPropertyForUpload(pf.Header)
-		}
-
+		mediaProperty := uploadProperty(pf)
 		url, err := st.MediaStore.Upload(r.Context(), &pf.File, pf.Header)
 		if err != nil {
 			common.LogAndWriteError(w, r, "upload media", err)
@@ -50,7 +110,34 @@ func Create(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, bod
 		document.Properties[mediaProperty] = append(document.Properties[mediaProperty], url)
 	}
 
-	suggestedSlug := deriveSuggestedSlug(&document)
+	if st.ReplyContext != nil {
+		// Missing context only makes the post less rich; publish it regardless.
+		if err := st.ReplyContext.Enrich(r.Context(), &document); err != nil {
+			if rl := util.FromContext(r.Context()); rl != nil {
+				rl.Errorf("failed to fetch reply context: %v", err)
+			}
+		}
+	}
+
+	if st.Contacts != nil {
+		contacts, err := st.Contacts.All(r.Context())
+		if err != nil {
+			// Person tags are a nicety; a broken contacts file must not block publishing.
+			if rl := util.FromContext(r.Context()); rl != nil {
+				rl.Errorf("failed to load contacts: %v", err)
+			}
+		}
+		contact.ExpandPersonTags(&document, contacts)
+	}
+
+	// Future-dated posts are held back, along with their syndication, until they are due.
+	scheduled := st.Scheduler.Hold(&document, commands.SyndicateTo)
+	if content.IsDraft(document) {
+		// Drafts hold on to their syndication until an update publishes them.
+		schedule.HoldTargets(&document, commands.SyndicateTo)
+	}
+
+	suggestedSlug := deriveSuggestedSlug(&document, commands)
 
 	finalSlug, err := ensureUniqueSlug(r.Context(), st.ContentStore, suggestedSlug)
 	if err != nil {
@@ -66,17 +153,35 @@ func Create(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, bod
 		return
 	}
 
-	if now {
+	notifyChange(st, r, hooks.Event{Action: hooks.ActionCreate, Url: url, Document: &document})
+	switch {
+	case scheduled:
+		// The scheduler syndicates the post once it is published.
+	case content.IsDraft(document):
+		// Drafts are syndicated when an update publishes them.
+	case content.Visibility(document) != content.VisibilityPublic:
+		if rl := util.FromContext(r.Context()); rl != nil && len(commands.SyndicateTo) > 0 {
+			rl.Infof("not syndicating %s post %q", content.Visibility(document), url)
+		}
+	default:
+		if err := st.Syndication.Dispatch(url, commands.SyndicateTo); err != nil {
+			// The post is stored; failing to queue syndication must not turn that into an error.
+			if rl := util.FromContext(r.Context()); rl != nil {
+				rl.Errorf("%v", err)
+			}
+		}
+	}
+
+	if now && !scheduled {
 		resp.WriteCreated(w, url)
 	} else {
 		resp.WriteAccepted(w, url)
 	}
 }
 
-func deriveSuggestedSlug(doc *util.Mf2Document) string {
-	suggestedSlug := processMpProperties(doc)
-	if suggestedSlug != "" {
-		return suggestedSlug
+func deriveSuggestedSlug(doc *util.Mf2Document, commands mpCommands) string {
+	if commands.Slug != "" {
+		return commands.Slug
 	}
 
 	if generated := util.GenerateSlug(*doc); generated != "" {
@@ -255,14 +360,35 @@ func extractStringFromProperty(values []any) string {
 	return ""
 }
 
+// mpCommands holds the server commands (mp-* properties) sent with a create request.
+type mpCommands struct {
+	Slug        string
+	Channel     string
+	SyndicateTo []string
+	Expires     string
+}
+
 // processMpProperties handles server command properties (mp-*) and removes them from the document.
-// Returns the suggested slug from mp-slug if present, otherwise returns empty string.
-func processMpProperties(doc *util.Mf2Document) string {
-	var suggestedSlug string
+// Returns the commands understood by this server; unknown commands are dropped.
+func processMpProperties(doc *util.Mf2Document) mpCommands {
+	var commands mpCommands
 
-	// Extract mp-slug if present
 	if mpSlugProp, ok := doc.Properties["mp-slug"]; ok {
-		suggestedSlug = extractStringFromProperty(mpSlugProp)
+		commands.Slug = extractStringFromProperty(mpSlugProp)
+	}
+
+	if mpChannelProp, ok := doc.Properties["mp-channel"]; ok {
+		commands.Channel = extractStringFromProperty(mpChannelProp)
+	}
+
+	if mpExpiresProp, ok := doc.Properties["mp-expires"]; ok {
+		commands.Expires = extractStringFromProperty(mpExpiresProp)
+	}
+
+	for _, val := range doc.Properties["mp-syndicate-to"] {
+		if s, ok := val.(string); ok && s != "" && !slices.Contains(commands.SyndicateTo, s) {
+			commands.SyndicateTo = append(commands.SyndicateTo, s)
+		}
 	}
 
 	// Collect mp-* keys first to avoid modifying map during iteration
@@ -278,7 +404,7 @@ func processMpProperties(doc *util.Mf2Document) string {
 		delete(doc.Properties, key)
 	}
 
-	return suggestedSlug
+	return commands
 }
 
 func coerceSlice(v any) []any {
@@ -302,6 +428,31 @@ func coerceSlice(v any) []any {
 	return out
 }
 
+// uploadProperty names the property a file uploaded with a post is added to.
+func uploadProperty(pf ParsedFile) string {
+	if pf.Field == "" || pf.Field == "file" {
+		return mediaPropertyForUpload(pf.Header)
+	}
+
+	return pf.Field
+}
+
+// withUploads returns a copy of doc with an empty value for each file uploaded with it, so its post
+// type is known before the files are stored. doc itself is left untouched.
+func withUploads(doc util.Mf2Document, files []ParsedFile) util.Mf2Document {
+	out := util.Mf2Document{Type: doc.Type, Properties: maps.Clone(doc.Properties)}
+	for _, pf := range files {
+		if pf.Header == nil || pf.File == nil {
+			continue
+		}
+
+		property := uploadProperty(pf)
+		out.Properties[property] = append(slices.Clip(out.Properties[property]), "")
+	}
+
+	return out
+}
+
 func mediaPropertyForUpload(header *multipart.FileHeader) string {
 	if header == nil {
 		return "photo"
@@ -10,9 +10,18 @@ import (
 	"net/http/httptest"
 	"net/textproto"
 	"testing"
+	"time"
 
+	"github.com/indieinfra/scribble/config"
 	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/contact"
+	"github.com/indieinfra/scribble/server/expiry"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/replycontext"
+	"github.com/indieinfra/scribble/server/schedule"
+	"github.com/indieinfra/scribble/server/syndication"
 	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
 )
 
 type stubStore struct{ exists bool }
@@ -39,22 +48,22 @@ func (s *stubMediaStoreErr) Delete(context.Context, string) error { return nil }
 
 func TestDeriveSuggestedSlug(t *testing.T) {
 	t.Run("mp-slug wins", func(t *testing.T) {
-		doc := util.Mf2Document{Properties: map[string][]any{"mp-slug": []any{"custom"}}}
-		if got := deriveSuggestedSlug(&doc); got != "custom" {
+		doc := util.Mf2Document{Properties: map[string][]any{"mp-slug": []any{"custom"}, "name": []any{"Hello"}}}
+		if got := deriveSuggestedSlug(&doc, processMpProperties(&doc)); got != "custom" {
 			t.Fatalf("expected mp-slug, got %q", got)
 		}
 	})
 
 	t.Run("generated slug", func(t *testing.T) {
 		doc := util.Mf2Document{Properties: map[string][]any{"name": []any{"Hello"}}}
-		if got := deriveSuggestedSlug(&doc); got != "hello" {
+		if got := deriveSuggestedSlug(&doc, mpCommands{}); got != "hello" {
 			t.Fatalf("expected generated slug, got %q", got)
 		}
 	})
 
 	t.Run("uuid fallback", func(t *testing.T) {
 		doc := util.Mf2Document{Properties: map[string][]any{"photo": []any{"noop"}}}
-		got := deriveSuggestedSlug(&doc)
+		got := deriveSuggestedSlug(&doc, mpCommands{})
 		if got == "" {
 			t.Fatalf("expected uuid fallback slug")
 		}
@@ -131,6 +140,7 @@ func TestNormalizeFormBodyAndProcessMpProperties(t *testing.T) {
 		"h":          "entry",
 		"category[]": []any{"go", "micropub"},
 		"mp-slug":    "custom-slug",
+		"mp-channel": "notes",
 		"skip":       []any{},
 	})
 
@@ -141,9 +151,12 @@ func TestNormalizeFormBodyAndProcessMpProperties(t *testing.T) {
 		t.Fatalf("expected two category values, got %v", vals)
 	}
 
-	slug := processMpProperties(&doc)
-	if slug != "custom-slug" {
-		t.Fatalf("expected mp-slug to be returned, got %q", slug)
+	commands := processMpProperties(&doc)
+	if commands.Slug != "custom-slug" {
+		t.Fatalf("expected mp-slug to be returned, got %q", commands.Slug)
+	}
+	if commands.Channel != "notes" {
+		t.Fatalf("expected mp-channel to be returned, got %q", commands.Channel)
 	}
 	if _, exists := doc.Properties["mp-slug"]; exists {
 		t.Fatalf("expected mp-* properties to be removed")
@@ -331,3 +344,382 @@ func TestCreateContentStoreError(t *testing.T) {
 		t.Fatalf("expected 500 when content store fails, got %d", rr.Code)
 	}
 }
+
+func TestCreateExpandsPersonTags(t *testing.T) {
+	st := newState()
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+	st.Contacts = contact.NewDirectory(&config.Contacts{Entries: []config.Contact{{Name: "Jane", Nickname: "jane", Url: "https://jane.example"}}}, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: st.Cfg.Micropub.MeUrl, Scope: "create"}))
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, req, &ParsedBody{Data: map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{"content": []any{"hi @jane"}}}})
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	cats := cs.lastDoc.Properties["category"]
+	if len(cats) != 1 {
+		t.Fatalf("expected one person tag, got %#v", cats)
+	}
+	if tag, ok := cats[0].(map[string]any); !ok || tag["properties"].(map[string]any)["url"].([]any)[0] != "https://jane.example" {
+		t.Fatalf("unexpected person tag %#v", cats[0])
+	}
+}
+
+func TestCreateChannel(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		return req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "create"}))
+	}
+	body := func(channel string) *ParsedBody {
+		return &ParsedBody{Data: map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{
+			"content":    []any{"hi"},
+			"mp-channel": []any{channel},
+		}}}
+	}
+
+	st := newState()
+	st.Cfg.Micropub.Channels = []config.Channel{{Uid: "notes", Name: "Notes", Path: "notes"}}
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, newRequest(), body("notes"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	if ch := cs.lastDoc.Properties["channel"]; len(ch) != 1 || ch[0] != "notes" {
+		t.Fatalf("expected channel property, got %#v", ch)
+	}
+	if _, ok := cs.lastDoc.Properties["mp-channel"]; ok {
+		t.Fatalf("expected mp-channel to be removed")
+	}
+
+	rr = httptest.NewRecorder()
+	Create(st, rr, newRequest(), body("nope"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown channel, got %d", rr.Code)
+	}
+}
+
+func TestCreateSyndicateTo(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		return req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "create"}))
+	}
+	body := func(targets ...any) *ParsedBody {
+		return &ParsedBody{Data: map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{
+			"content":         []any{"hi"},
+			"mp-syndicate-to": targets,
+		}}}
+	}
+
+	st := newState()
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+
+	st.Jobs, _ = jobs.NewRunner(&config.Jobs{})
+	st.Syndication, _ = syndication.NewDispatcher(&config.Syndication{}, cs, st.Jobs)
+	st.Syndication.AddTarget(&syndication.Target{Uid: "masto", Name: "Mastodon"})
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, newRequest(), body("nope"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown target, got %d", rr.Code)
+	}
+	if cs.createCalled {
+		t.Fatalf("expected no post to be created")
+	}
+
+	rr = httptest.NewRecorder()
+	Create(st, rr, newRequest(), body("masto", "masto"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+
+	pending := st.Jobs.Pending()
+	if len(pending) != 1 || pending[0].Kind != syndication.JobKind {
+		t.Fatalf("expected one queued syndication job, got %+v", pending)
+	}
+	if _, ok := cs.lastDoc.Properties["mp-syndicate-to"]; ok {
+		t.Fatalf("expected mp-syndicate-to to be removed")
+	}
+}
+
+func TestCreateDraftScope(t *testing.T) {
+	newRequest := func(scope string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		return req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: scope}))
+	}
+	body := func(status string) *ParsedBody {
+		return &ParsedBody{Data: map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{
+			"content":         []any{"hi"},
+			"post-status":     []any{status},
+			"mp-syndicate-to": []any{"masto"},
+		}}}
+	}
+
+	st := newState()
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+	st.Jobs, _ = jobs.NewRunner(&config.Jobs{})
+	st.Syndication, _ = syndication.NewDispatcher(&config.Syndication{}, cs, st.Jobs)
+	st.Syndication.AddTarget(&syndication.Target{Uid: "masto", Name: "Mastodon"})
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, newRequest("draft"), body("published"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	if ps := cs.lastDoc.Properties["post-status"]; len(ps) != 1 || ps[0] != "draft" {
+		t.Fatalf("expected post to be forced to draft, got %#v", ps)
+	}
+	if pending := st.Jobs.Pending(); len(pending) != 0 {
+		t.Fatalf("expected drafts not to be syndicated, got %+v", pending)
+	}
+	if targets := cs.lastDoc.Properties[schedule.HeldTargetsProperty]; len(targets) != 1 || targets[0] != "masto" {
+		t.Fatalf("expected the draft's syndication targets to be held, got %#v", targets)
+	}
+
+	rr = httptest.NewRecorder()
+	Create(st, rr, newRequest("create"), body("published"))
+	if ps := cs.lastDoc.Properties["post-status"]; len(ps) != 1 || ps[0] != "published" {
+		t.Fatalf("expected post-status to be kept, got %#v", ps)
+	}
+
+	rr = httptest.NewRecorder()
+	Create(st, rr, newRequest("create"), body("later"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown post-status, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	Create(st, rr, newRequest("media"), body("draft"))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without create or draft scope, got %d", rr.Code)
+	}
+}
+
+func TestCreateHoldsFuturePosts(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "create"}))
+
+	st := newState()
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+	st.Jobs, _ = jobs.NewRunner(&config.Jobs{})
+	st.Syndication, _ = syndication.NewDispatcher(&config.Syndication{}, cs, st.Jobs)
+	st.Syndication.AddTarget(&syndication.Target{Uid: "masto", Name: "Mastodon"})
+	st.Scheduler = schedule.NewScheduler(cs, st.Hooks, st.Syndication, nil)
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, req, &ParsedBody{Data: map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{
+		"content":         []any{"later"},
+		"published":       []any{time.Now().Add(time.Hour).Format(time.RFC3339)},
+		"mp-syndicate-to": []any{"masto"},
+	}}})
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 for a scheduled post, got %d", rr.Code)
+	}
+	if !content.IsScheduled(cs.lastDoc) {
+		t.Fatalf("expected post to be scheduled, got %+v", cs.lastDoc.Properties)
+	}
+	if targets := cs.lastDoc.Properties[schedule.HeldTargetsProperty]; len(targets) != 1 || targets[0] != "masto" {
+		t.Fatalf("expected syndication targets to be held, got %#v", targets)
+	}
+	if _, ok := cs.lastDoc.Properties["mp-syndicate-to"]; ok {
+		t.Fatalf("expected no mp-* command to be stored, got %+v", cs.lastDoc.Properties)
+	}
+	if pending := st.Jobs.Pending(); len(pending) != 0 {
+		t.Fatalf("expected no syndication before publication, got %+v", pending)
+	}
+}
+
+func TestCreateExpires(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		return req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "create"}))
+	}
+	body := func(expires string) *ParsedBody {
+		return &ParsedBody{Data: map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{
+			"content":    []any{"gone tomorrow"},
+			"mp-expires": []any{expires},
+		}}}
+	}
+
+	st := newState()
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+	st.Jobs, _ = jobs.NewRunner(&config.Jobs{})
+	st.Expiry = expiry.NewExpirer(st.Cfg, cs, st.MediaStore, st.Hooks, st.Jobs)
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, newRequest(), body("2030-01-01T00:00:00Z"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	if v := cs.lastDoc.Properties[expiry.Property]; len(v) != 1 || v[0] != "2030-01-01T00:00:00Z" {
+		t.Fatalf("expected expires property, got %#v", v)
+	}
+	if _, ok := cs.lastDoc.Properties["mp-expires"]; ok {
+		t.Fatalf("expected mp-expires to be removed")
+	}
+
+	rr = httptest.NewRecorder()
+	Create(st, rr, newRequest(), body("someday"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid mp-expires, got %d", rr.Code)
+	}
+}
+
+type countingMediaStore struct{ uploads int }
+
+func (s *countingMediaStore) Upload(context.Context, *multipart.File, *multipart.FileHeader) (string, error) {
+	s.uploads++
+	return "https://media.example.org/file", nil
+}
+func (s *countingMediaStore) Delete(context.Context, string) error { return nil }
+
+type bytesFile struct{ *bytes.Reader }
+
+func (bytesFile) Close() error { return nil }
+
+func TestCreateChecksExpiryBeforeUploading(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		return req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "create"}))
+	}
+	body := func(properties map[string]any) *ParsedBody {
+		return &ParsedBody{
+			Data: map[string]any{"type": []any{"h-entry"}, "properties": properties},
+			Files: []ParsedFile{{
+				File:   bytesFile{bytes.NewReader([]byte("jpeg"))},
+				Header: &multipart.FileHeader{Filename: "image.jpg", Header: textproto.MIMEHeader{"Content-Type": []string{"image/jpeg"}}},
+				Field:  "photo",
+			}},
+		}
+	}
+
+	st := newState()
+	st.Cfg.Micropub.PostTypes = []config.PostType{{Type: "photo", Name: "Photo", Expires: time.Hour}}
+	cs := &stubContentStore{createNow: true}
+	ms := &countingMediaStore{}
+	st.ContentStore = cs
+	st.MediaStore = ms
+	st.Jobs, _ = jobs.NewRunner(&config.Jobs{})
+	st.Expiry = expiry.NewExpirer(st.Cfg, cs, st.MediaStore, st.Hooks, st.Jobs)
+
+	for _, properties := range []map[string]any{
+		{"content": []any{"hi"}, "expires": []any{"soon"}},
+		{"content": []any{"hi"}, "mp-expires": []any{"someday"}},
+	} {
+		rr := httptest.NewRecorder()
+		Create(st, rr, newRequest(), body(properties))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %v, got %d", properties, rr.Code)
+		}
+	}
+	if ms.uploads != 0 {
+		t.Fatalf("expected nothing to be uploaded for a refused post, got %d uploads", ms.uploads)
+	}
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, newRequest(), body(map[string]any{}))
+	if rr.Code != http.StatusCreated || ms.uploads != 1 {
+		t.Fatalf("expected the photo to be uploaded, got %d with %d uploads", rr.Code, ms.uploads)
+	}
+	if v := cs.lastDoc.Properties[expiry.Property]; len(v) != 1 {
+		t.Fatalf("expected the photo post type's expiry to apply, got %#v", cs.lastDoc.Properties)
+	}
+	if v := cs.lastDoc.Properties["photo"]; len(v) != 1 || v[0] != "https://media.example.org/file" {
+		t.Fatalf("expected only the uploaded photo, got %#v", v)
+	}
+}
+
+func TestCreateVisibility(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		return req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "create"}))
+	}
+	body := func(visibility string) *ParsedBody {
+		return &ParsedBody{Data: map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{
+			"content":         []any{"hi"},
+			"visibility":      []any{visibility},
+			"mp-syndicate-to": []any{"masto"},
+		}}}
+	}
+
+	st := newState()
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+	st.Jobs, _ = jobs.NewRunner(&config.Jobs{})
+	st.Syndication, _ = syndication.NewDispatcher(&config.Syndication{}, cs, st.Jobs)
+	st.Syndication.AddTarget(&syndication.Target{Uid: "masto", Name: "Mastodon"})
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, newRequest(), body("secret"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown visibility, got %d", rr.Code)
+	}
+
+	for _, visibility := range []string{"unlisted", "private"} {
+		rr = httptest.NewRecorder()
+		Create(st, rr, newRequest(), body(visibility))
+		if rr.Code != http.StatusCreated {
+			t.Fatalf("expected 201 for %s post, got %d", visibility, rr.Code)
+		}
+	}
+	if pending := st.Jobs.Pending(); len(pending) != 0 {
+		t.Fatalf("expected unlisted and private posts not to be syndicated, got %+v", pending)
+	}
+}
+
+func TestCreateEnrichesReplyContext(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<div class="h-entry"><h1 class="p-name">Original</h1><p class="e-content">Body</p></div>`))
+	}))
+	defer page.Close()
+
+	st := newState()
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+	st.ReplyContext = replycontext.NewFetcher(&config.ReplyContext{}, page.Client())
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "create"}))
+
+	rr := httptest.NewRecorder()
+	Create(st, rr, req, &ParsedBody{Data: map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{
+		"content":     []any{"agreed"},
+		"in-reply-to": []any{page.URL},
+	}}})
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+
+	cite, ok := cs.lastDoc.Properties["in-reply-to"][0].(util.Mf2Document)
+	if !ok || cite.Properties["name"][0] != "Original" {
+		t.Fatalf("expected an embedded h-cite, got %#v", cs.lastDoc.Properties["in-reply-to"])
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/indieinfra/scribble/server/auth"
 	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/hooks"
 	"github.com/indieinfra/scribble/server/resp"
 	"github.com/indieinfra/scribble/server/state"
 )
@@ -27,10 +28,16 @@ func Delete(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, dat
 			return
 		}
 
+		previous := previousDocument(st, r, url)
+
 		url, isNewUrl, err := st.ContentStore.Undelete(r.Context(), url)
 		if err != nil {
 			common.LogAndWriteError(w, r, "undelete content", err)
-		} else if isNewUrl {
+			return
+		}
+
+		notifyChange(st, r, hooks.Event{Action: hooks.ActionUndelete, Url: url, Previous: previous})
+		if isNewUrl {
 			resp.WriteCreated(w, url)
 		} else {
 			resp.WriteNoContent(w)
@@ -40,10 +47,14 @@ func Delete(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, dat
 			return
 		}
 
+		previous := previousDocument(st, r, url)
+
 		if err := st.ContentStore.Delete(r.Context(), url); err != nil {
 			common.LogAndWriteError(w, r, "delete content", err)
-		} else {
-			resp.WriteNoContent(w)
+			return
 		}
+
+		notifyChange(st, r, hooks.Event{Action: hooks.ActionDelete, Url: url, Previous: previous})
+		resp.WriteNoContent(w)
 	}
 }
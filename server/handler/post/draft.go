@@ -0,0 +1,51 @@
+package post
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// postStatuses are the values of post-status understood by this server.
+var postStatuses = []string{"published", "draft"}
+
+// validatePostStatus checks that every post-status value is one this server understands.
+func validatePostStatus(values []any) error {
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok || !slices.Contains(postStatuses, strings.ToLower(s)) {
+			return fmt.Errorf("post-status must be one of %s", strings.Join(postStatuses, ", "))
+		}
+	}
+
+	return nil
+}
+
+// keepsDraft reports whether an update leaves post-status at draft, given a document that is a draft
+// before the update. Removing post-status or setting any other value publishes the post.
+func keepsDraft(replacements map[string][]any, additions map[string][]any, deletions any) bool {
+	isDraft := func(values []any) bool {
+		return !slices.ContainsFunc(values, func(v any) bool {
+			s, ok := v.(string)
+			return !ok || !strings.EqualFold(s, "draft")
+		})
+	}
+
+	if values, ok := replacements["post-status"]; ok && (len(values) == 0 || !isDraft(values)) {
+		return false
+	}
+
+	if !isDraft(additions["post-status"]) {
+		return false
+	}
+
+	switch d := deletions.(type) {
+	case []string:
+		return !slices.Contains(d, "post-status")
+	case map[string][]any:
+		_, ok := d["post-status"]
+		return !ok
+	}
+
+	return true
+}
@@ -0,0 +1,45 @@
+package post
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/webmention"
+)
+
+// Moderate sets the moderation status of a received webmention. The request names the post in "url",
+// the mentioning page in "source" and the new status (pending, approved or rejected) in "status".
+func Moderate(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, data map[string]any) {
+	if !requireScope(w, r, auth.ScopeUpdate) {
+		return
+	}
+
+	if st.Mentions == nil {
+		resp.WriteInvalidRequest(w, "receiving webmentions is not enabled")
+		return
+	}
+
+	fields := map[string]string{}
+	for _, key := range []string{"url", "source", "status"} {
+		value, err := getStringField(data, key)
+		if err != nil {
+			resp.WriteInvalidRequest(w, err.Error())
+			return
+		}
+		fields[key] = value
+	}
+
+	err := st.Mentions.Moderate(r.Context(), fields["url"], fields["source"], fields["status"])
+	switch {
+	case err == nil:
+		resp.WriteNoContent(w)
+	case errors.Is(err, webmention.ErrInvalidMention):
+		resp.WriteInvalidRequest(w, err.Error())
+	default:
+		common.LogAndWriteError(w, r, "moderate mention", err)
+	}
+}
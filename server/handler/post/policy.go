@@ -0,0 +1,60 @@
+package post
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/indieinfra/scribble/server/policy"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+// policyRequest describes an action for the policy check: the type and properties of a new post,
+// or the properties an update touches. Bodies that don't parse are left to the handler to refuse.
+func policyRequest(w http.ResponseWriter, r *http.Request, action string, body *ParsedBody) policy.Request {
+	req := policy.Request{Write: true}
+
+	switch action {
+	case "create":
+		ct, _ := util.ExtractMediaType(w, r)
+		doc, err := buildDocument(ct, body.Data)
+		if err != nil {
+			return req
+		}
+		doc = withUploads(doc, body.Files)
+
+		req.PostType = util.PostType(doc)
+		for key := range doc.Properties {
+			if !strings.HasPrefix(key, "mp-") {
+				req.Properties = append(req.Properties, key)
+			}
+		}
+	case "update":
+		for _, key := range []string{"replace", "add", "delete"} {
+			req.Properties = append(req.Properties, updatedProperties(body, key)...)
+		}
+	}
+
+	slices.Sort(req.Properties)
+	req.Properties = slices.Compact(req.Properties)
+	return req
+}
+
+// updatedProperties names the properties an update lists under key: "replace", "add" or "delete".
+func updatedProperties(body *ParsedBody, key string) []string {
+	var props []string
+	switch v := body.Data[key].(type) {
+	case map[string]any:
+		for prop := range v {
+			props = append(props, prop)
+		}
+	case []any:
+		for _, prop := range v {
+			if s, ok := prop.(string); ok {
+				props = append(props, s)
+			}
+		}
+	}
+
+	return props
+}
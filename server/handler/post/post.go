@@ -6,9 +6,14 @@ import (
 	"strings"
 
 	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/author"
+	"github.com/indieinfra/scribble/server/hooks"
 	"github.com/indieinfra/scribble/server/middleware"
+	"github.com/indieinfra/scribble/server/policy"
+	"github.com/indieinfra/scribble/server/ratelimit"
 	"github.com/indieinfra/scribble/server/resp"
 	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/util"
 )
 
 func DispatchPost(st *state.ScribbleState) http.HandlerFunc {
@@ -23,6 +28,9 @@ func DispatchPost(st *state.ScribbleState) http.HandlerFunc {
 		"undelete": func(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, body *ParsedBody) {
 			Delete(st, w, r, body.Data, true)
 		},
+		"moderate": func(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, body *ParsedBody) {
+			Moderate(st, w, r, body.Data)
+		},
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -39,10 +47,14 @@ func DispatchPost(st *state.ScribbleState) http.HandlerFunc {
 			resp.WriteBadRequest(w, "access token must appear in header or body, not both")
 			return
 		}
-		r, ok = middleware.EnsureTokenForRequest(st.Cfg, w, r, parsed.AccessToken)
+		r, ok = middleware.EnsureTokenForRequest(st.Tokens, w, r, parsed.AccessToken)
 		if !ok {
 			return
 		}
+		r = r.WithContext(author.WithCommitter(r.Context(), &st.Cfg.Micropub, auth.GetToken(r.Context())))
+		if token := auth.GetToken(r.Context()); token != nil && author.Enabled(&st.Cfg.Micropub) {
+			r = r.WithContext(author.WithOwner(r.Context(), token.Me))
+		}
 		for _, pf := range parsed.Files {
 			if pf.File != nil {
 				defer pf.File.Close()
@@ -63,6 +75,22 @@ func DispatchPost(st *state.ScribbleState) http.HandlerFunc {
 		delete(parsed.Data, "action")
 
 		if handler, ok := handlers[strings.ToLower(action)]; ok {
+			op := beginAudit(st, w, r, strings.ToLower(action), parsed)
+			defer op.Done()
+			w = op
+
+			if !policy.Enforce(st.Cfg.Micropub.Policies, w, r, policyRequest(w, r, strings.ToLower(action), parsed)) {
+				return
+			}
+			if !st.Limits.Enforce(ratelimit.Write, w, r) {
+				return
+			}
+			release, ok := st.Limits.EnforceWrite(w)
+			if !ok {
+				return
+			}
+			defer release()
+
 			handler(st, w, r, parsed)
 			return
 		}
@@ -78,3 +106,39 @@ func requireScope(w http.ResponseWriter, r *http.Request, scope auth.Scope) bool
 	}
 	return true
 }
+
+// previousDocument reads the document at url before it is changed, so listeners can compare it
+// with the result. It returns nil when no listeners are registered or the document can't be read.
+func previousDocument(st *state.ScribbleState, r *http.Request, url string) *util.Mf2Document {
+	if st.Hooks.Len() == 0 {
+		return nil
+	}
+
+	doc, err := st.ContentStore.Get(r.Context(), url)
+	if err != nil {
+		return nil
+	}
+
+	return doc
+}
+
+// notifyChange fires the content hooks after a successful change. When the event has no document
+// and listeners are registered, the changed document is read back from the content store first.
+func notifyChange(st *state.ScribbleState, r *http.Request, ev hooks.Event) {
+	if st.Hooks.Len() == 0 {
+		return
+	}
+
+	if ev.Document == nil {
+		got, err := st.ContentStore.Get(r.Context(), ev.Url)
+		if err != nil {
+			if rl := util.FromContext(r.Context()); rl != nil {
+				rl.Errorf("failed to read back %q for hooks: %v", ev.Url, err)
+			}
+		} else {
+			ev.Document = got
+		}
+	}
+
+	st.Hooks.Fire(r.Context(), ev)
+}
@@ -7,11 +7,16 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/audit"
 	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/ratelimit"
 	"github.com/indieinfra/scribble/server/state"
 	"github.com/indieinfra/scribble/server/util"
 )
@@ -54,7 +59,9 @@ func (s *stubContentStore) Delete(context.Context, string) error { return nil }
 func (s *stubContentStore) Undelete(context.Context, string) (string, bool, error) {
 	return "", false, nil
 }
-func (s *stubContentStore) Get(context.Context, string) (*util.Mf2Document, error) { return nil, nil }
+func (s *stubContentStore) Get(context.Context, string) (*util.Mf2Document, error) {
+	return &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{}}, nil
+}
 
 type stubMediaStore struct{}
 
@@ -128,6 +135,34 @@ func TestDispatchPost_CreateJSONSuccess(t *testing.T) {
 	}
 }
 
+func TestDispatchPost_CreateFiresHooks(t *testing.T) {
+	st := newState()
+	st.ContentStore = &stubContentStore{createNow: true}
+	st.MediaStore = &stubMediaStore{}
+	st.Hooks = &hooks.Hooks{}
+
+	var events []hooks.Event
+	st.Hooks.Register(hooks.ListenerFunc(func(_ context.Context, ev hooks.Event) { events = append(events, ev) }))
+
+	b, _ := json.Marshal(map[string]any{"type": []any{"h-entry"}, "properties": map[string]any{"content": []any{"hi"}}})
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: st.Cfg.Micropub.MeUrl, Scope: "create"}))
+
+	rr := httptest.NewRecorder()
+	DispatchPost(st).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	if len(events) != 1 || events[0].Action != hooks.ActionCreate || events[0].Url != "https://example.org/post" {
+		t.Fatalf("unexpected hook events: %+v", events)
+	}
+	if events[0].Document == nil || events[0].Document.Properties["content"][0] != "hi" {
+		t.Fatalf("expected created document to be passed to hooks")
+	}
+}
+
 func TestDispatchPost_UnknownAction(t *testing.T) {
 	st := newState()
 	st.ContentStore = &stubContentStore{}
@@ -165,3 +200,177 @@ func TestDispatchPost_ActionMustBeString(t *testing.T) {
 		t.Fatalf("expected 400 for non-string action, got %d", rr.Code)
 	}
 }
+
+func TestDispatchPost_EnforcesPolicies(t *testing.T) {
+	st := newState()
+	st.Cfg.Micropub.Policies = []config.Policy{
+		{ClientId: "https://notes.example/", PostTypes: []string{"note"}, Properties: []string{"content", "category"}},
+		{ClientId: "https://spam.example/", Deny: true},
+	}
+	st.MediaStore = &stubMediaStore{}
+
+	cases := []struct {
+		name     string
+		clientId string
+		body     string
+		status   int
+	}{
+		{"allowed note", "https://notes.example/", "h=entry&content=Hello&category=test&mp-slug=hello", http.StatusCreated},
+		{"disallowed post type", "https://notes.example/", "h=entry&content=Hi&like-of=https://example.com/", http.StatusUnauthorized},
+		{"disallowed property", "https://notes.example/", "h=entry&content=Hi&location=geo:1,2", http.StatusUnauthorized},
+		{"denied client", "https://spam.example/", "h=entry&content=Hi", http.StatusForbidden},
+	}
+
+	for _, c := range cases {
+		cs := &stubContentStore{createNow: true, forbidCreate: c.status != http.StatusCreated}
+		st.ContentStore = cs
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: st.Cfg.Micropub.MeUrl, ClientId: c.clientId, Scope: "create"}))
+
+		rr := httptest.NewRecorder()
+		DispatchPost(st).ServeHTTP(rr, req)
+
+		if rr.Code != c.status {
+			t.Fatalf("%s: expected %d, got %d: %s", c.name, c.status, rr.Code, rr.Body.String())
+		}
+		if c.name == "denied client" && !strings.Contains(rr.Body.String(), `"forbidden"`) {
+			t.Fatalf("%s: expected a forbidden error, got %s", c.name, rr.Body.String())
+		}
+		if c.name == "disallowed property" && !strings.Contains(rr.Body.String(), "insufficient_scope") {
+			t.Fatalf("%s: expected insufficient_scope, got %s", c.name, rr.Body.String())
+		}
+	}
+}
+
+func TestDispatchPost_RateLimitsWrites(t *testing.T) {
+	st := newState()
+	st.Limits = ratelimit.NewLimiter(&config.RateLimit{Write: config.RateBudget{PerMinute: 1}})
+
+	for i, want := range []int{http.StatusCreated, http.StatusTooManyRequests} {
+		st.ContentStore = &stubContentStore{createNow: true, forbidCreate: want != http.StatusCreated}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("h=entry&content=Hello"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: st.Cfg.Micropub.MeUrl, ClientId: "https://quill.example/", Scope: "create"}))
+
+		rr := httptest.NewRecorder()
+		DispatchPost(st).ServeHTTP(rr, req)
+
+		if rr.Code != want {
+			t.Fatalf("request %d: expected %d, got %d: %s", i, want, rr.Code, rr.Body.String())
+		}
+		if want == http.StatusTooManyRequests && rr.Header().Get("Retry-After") == "" {
+			t.Fatalf("expected a Retry-After header")
+		}
+	}
+}
+
+func TestDispatchPost_AuditsChanges(t *testing.T) {
+	st := newState()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	st.Cfg.Audit.Sinks = []config.AuditSink{{Type: "file", File: &config.AuditFileSink{Path: path}}}
+	auditLog, err := audit.NewLog(&st.Cfg.Audit)
+	if err != nil {
+		t.Fatalf("failed to create audit log: %v", err)
+	}
+	st.Audit = auditLog
+	st.ContentStore = &stubContentStore{createNow: true}
+	st.MediaStore = &stubMediaStore{}
+
+	bodies := []string{
+		`{"type":["h-entry"],"properties":{"content":["Hello"]}}`,
+		`{"action":"update","url":"https://example.org/post","replace":{"content":["Hi"]},"add":{"category":["greeting"]},"delete":["syndication"]}`,
+	}
+	for _, body := range bodies {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: st.Cfg.Micropub.MeUrl, ClientId: "https://quill.example/", Scope: "create update"}))
+
+		DispatchPost(st).ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if err := st.Audit.Close(); err != nil {
+		t.Fatalf("failed to close audit log: %v", err)
+	}
+
+	entries, err := audit.ReadFile(st.Cfg.Audit.Sinks[0].File, audit.Filter{ClientId: "https://quill.example/"})
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.Action != "create" || e.Url != "https://example.org/post" || e.Status != http.StatusCreated || e.Changes != nil {
+		t.Fatalf("unexpected create entry %+v", e)
+	}
+	e := entries[1]
+	if e.Action != "update" || e.Url != "https://example.org/post" || e.Me != st.Cfg.Micropub.MeUrl || e.Changes == nil {
+		t.Fatalf("unexpected update entry %+v", e)
+	}
+	if !slices.Equal(e.Changes.Replace, []string{"content"}) || !slices.Equal(e.Changes.Add, []string{"category"}) || !slices.Equal(e.Changes.Delete, []string{"syndication"}) {
+		t.Fatalf("unexpected update changes %+v", e.Changes)
+	}
+}
+
+func TestDispatchPost_AttributesPostsToAuthors(t *testing.T) {
+	st := newState()
+	st.Cfg.Micropub.Authors = []config.Author{{Me: "https://alice.example/", Name: "Alice"}}
+	cs := &stubContentStore{createNow: true}
+	st.ContentStore = cs
+	st.MediaStore = &stubMediaStore{}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("h=entry&content=Hello"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: "https://alice.example/", Scope: "create"}))
+
+	rr := httptest.NewRecorder()
+	DispatchPost(st).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	card, ok := cs.lastDoc.Properties["author"][0].(map[string]any)
+	if !ok {
+		t.Fatalf("expected an author h-card, got %#v", cs.lastDoc.Properties["author"])
+	}
+	props := card["properties"].(map[string]any)
+	if props["name"].([]any)[0] != "Alice" || props["url"].([]any)[0] != "https://alice.example/" {
+		t.Fatalf("unexpected author card %#v", card)
+	}
+}
+
+func TestDispatchPost_RefusesImpersonation(t *testing.T) {
+	st := newState()
+	st.Cfg.Micropub.Authors = []config.Author{{Me: "https://alice.example/", Path: "alice"}, {Me: "https://bob.example/", Path: "bob"}}
+	st.MediaStore = &stubMediaStore{}
+
+	cases := []struct {
+		name    string
+		body    string
+		refused bool
+	}{
+		{"own author", `{"type":["h-entry"],"properties":{"content":["Hi"],"author":["https://alice.example"]}}`, false},
+		{"other author", `{"type":["h-entry"],"properties":{"content":["Hi"],"author":["https://bob.example/"]}}`, true},
+		{"name only", `{"type":["h-entry"],"properties":{"content":["Hi"],"author":[{"type":["h-card"],"properties":{"name":["Bob"]}}]}}`, true},
+		{"update content", `{"action":"update","url":"https://example.org/post","replace":{"content":["Hello"]}}`, false},
+		{"replace author", `{"action":"update","url":"https://example.org/post","replace":{"author":["https://bob.example/"]}}`, true},
+		{"add author", `{"action":"update","url":"https://example.org/post","add":{"author":["https://bob.example/"]}}`, true},
+		{"delete author", `{"action":"update","url":"https://example.org/post","delete":["author"]}`, true},
+	}
+
+	for _, c := range cases {
+		st.ContentStore = &stubContentStore{createNow: true, forbidCreate: c.refused}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(c.body))
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: "https://alice.example/", Scope: "create update"}))
+
+		rr := httptest.NewRecorder()
+		DispatchPost(st).ServeHTTP(rr, req)
+
+		if refused := rr.Code == http.StatusForbidden; refused != c.refused {
+			t.Fatalf("%s: expected refused=%v, got %d: %s", c.name, c.refused, rr.Code, rr.Body.String())
+		}
+	}
+}
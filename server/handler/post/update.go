@@ -3,16 +3,24 @@ package post
 import (
 	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/author"
+	"github.com/indieinfra/scribble/server/expiry"
 	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/hooks"
 	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/schedule"
 	"github.com/indieinfra/scribble/server/state"
 	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
 )
 
 func Update(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, data map[string]any) {
-	if !requireScope(w, r, auth.ScopeUpdate) {
+	// A token with only the draft scope may edit drafts, but not publish them.
+	draftOnly := !auth.RequestHasScope(r, auth.ScopeUpdate) && auth.RequestHasScope(r, auth.ScopeDraft)
+	if !draftOnly && !requireScope(w, r, auth.ScopeUpdate) {
 		return
 	}
 
@@ -46,12 +54,86 @@ func Update(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, dat
 		return
 	}
 
-	newUrl, err := st.ContentStore.Update(r.Context(), url, replacements, additions, deletions)
+	if err := validatePostStatus(append(slices.Clone(replacements["post-status"]), additions["post-status"]...)); err != nil {
+		resp.WriteInvalidRequest(w, err.Error())
+		return
+	}
+
+	if err := validateVisibility(replacements["visibility"]); err != nil {
+		resp.WriteInvalidRequest(w, err.Error())
+		return
+	}
+	if _, ok := additions["visibility"]; ok {
+		resp.WriteInvalidRequest(w, "visibility has a single value; use replace to change it")
+		return
+	}
+
+	if err := expiry.Validate(append(slices.Clone(replacements[expiry.Property]), additions[expiry.Property]...)); err != nil {
+		resp.WriteInvalidRequest(w, err.Error())
+		return
+	}
+
+	if touches(schedule.HeldTargetsProperty, replacements, additions, deletions) {
+		resp.WriteInvalidRequest(w, fmt.Sprintf("%s is kept by the server and can't be changed", schedule.HeldTargetsProperty))
+		return
+	}
+
+	enforceAuthor := author.Enabled(&st.Cfg.Micropub)
+	token := auth.GetToken(r.Context())
+	if enforceAuthor && !keepsAuthor(token, replacements, additions, deletions) {
+		resp.WriteForbidden(w, "the author of a post can't be removed or changed to someone else")
+		return
+	}
+
+	// The document is read once; the checks below and the change hook all work from this copy.
+	doc, err := st.ContentStore.Get(r.Context(), url)
+	if err == nil && doc == nil {
+		err = content.ErrNotFound
+	}
+	if err != nil {
+		common.LogAndWriteError(w, r, "get content", err)
+		return
+	}
+
+	if draftOnly && (!content.IsDraft(*doc) || !keepsDraft(replacements, additions, deletions)) {
+		resp.WriteInsufficientScope(w, "no update scope; the draft scope only allows editing drafts")
+		return
+	}
+
+	if enforceAuthor {
+		// The post stays with the author it was created by, whoever edits it.
+		owner := ""
+		if a := author.Of(&st.Cfg.Micropub, *doc); a != nil {
+			owner = a.Me
+		}
+		r = r.WithContext(author.WithOwner(r.Context(), owner))
+
+		if token != nil {
+			replacements["updated-by"] = []any{token.Me}
+		}
+	}
+
+	// A draft being published takes the syndication it asked for along with it.
+	var held []string
+	if !keepsDraft(replacements, additions, deletions) && content.IsDraft(*doc) {
+		held = schedule.HeldTargets(*doc)
+	}
+
+	newUrl, updated, err := content.UpdateDocument(r.Context(), st.ContentStore, url, replacements, additions, deletions)
 	if err != nil {
 		common.LogAndWriteError(w, r, "update content", err)
 		return
 	}
 
+	ev := hooks.Event{Action: hooks.ActionUpdate, Url: newUrl, Document: updated, Previous: doc}
+	if newUrl != url {
+		ev.PreviousUrl = url
+	}
+	notifyChange(st, r, ev)
+	if len(held) > 0 && updated != nil {
+		newUrl = syndicatePublishedDraft(st, r, newUrl, *updated, held)
+	}
+
 	if newUrl != url {
 		resp.WriteCreated(w, newUrl)
 	} else {
@@ -59,6 +141,78 @@ func Update(st *state.ScribbleState, w http.ResponseWriter, r *http.Request, dat
 	}
 }
 
+// syndicatePublishedDraft queues the syndication held on a draft once an update has published it,
+// and returns the post's URL. Targets stay held while the post is still a draft or is scheduled, in
+// which case the scheduler syndicates it. doc is the post as the update left it. Failures are logged;
+// the update itself has succeeded.
+func syndicatePublishedDraft(st *state.ScribbleState, r *http.Request, url string, doc util.Mf2Document, held []string) string {
+	rl := util.FromContext(r.Context())
+
+	if content.IsDraft(doc) || content.IsScheduled(doc) || content.IsDeleted(doc) {
+		return url
+	}
+
+	// The targets are dropped before syndicating, so the post can't be syndicated twice.
+	newUrl, err := st.ContentStore.Update(r.Context(), url, nil, nil, []string{schedule.HeldTargetsProperty})
+	if err != nil {
+		if rl != nil {
+			rl.Errorf("failed to clear held syndication of %q: %v", url, err)
+		}
+		return url
+	}
+
+	if visibility := content.Visibility(doc); visibility != content.VisibilityPublic {
+		if rl != nil {
+			rl.Infof("not syndicating %s post %q", visibility, newUrl)
+		}
+		return newUrl
+	}
+	if err := st.Syndication.Dispatch(newUrl, held); err != nil && rl != nil {
+		rl.Errorf("%v", err)
+	}
+
+	return newUrl
+}
+
+// keepsAuthor reports whether an update leaves the author property alone, or only sets it to the
+// token's owner.
+func keepsAuthor(token *auth.TokenDetails, replacements map[string][]any, additions map[string][]any, deletions any) bool {
+	for _, values := range [][]any{replacements["author"], additions["author"]} {
+		if len(values) > 0 && !author.Claims(token, values) {
+			return false
+		}
+	}
+
+	switch d := deletions.(type) {
+	case []string:
+		return !slices.Contains(d, "author")
+	case map[string][]any:
+		_, ok := d["author"]
+		return !ok
+	}
+
+	return true
+}
+
+// touches reports whether an update replaces, adds to or deletes from the property.
+func touches(property string, replacements map[string][]any, additions map[string][]any, deletions any) bool {
+	_, replaced := replacements[property]
+	_, added := additions[property]
+	if replaced || added {
+		return true
+	}
+
+	switch d := deletions.(type) {
+	case []string:
+		return slices.Contains(d, property)
+	case map[string][]any:
+		_, ok := d[property]
+		return ok
+	}
+
+	return false
+}
+
 func getStringField(data map[string]any, key string) (string, error) {
 	raw, ok := data[key]
 	if !ok {
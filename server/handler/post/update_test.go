@@ -8,7 +8,11 @@ import (
 
 	"github.com/indieinfra/scribble/config"
 	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/schedule"
 	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/syndication"
 	"github.com/indieinfra/scribble/server/util"
 )
 
@@ -18,6 +22,7 @@ type stubUpdateStore struct {
 	additions    map[string][]any
 	deletions    any
 	newURL       string
+	doc          *util.Mf2Document
 }
 
 func (s *stubUpdateStore) ExistsBySlug(context.Context, string) (bool, error) { return false, nil }
@@ -38,7 +43,12 @@ func (s *stubUpdateStore) Delete(context.Context, string) error { return nil }
 func (s *stubUpdateStore) Undelete(context.Context, string) (string, bool, error) {
 	return "", false, nil
 }
-func (s *stubUpdateStore) Get(context.Context, string) (*util.Mf2Document, error) { return nil, nil }
+func (s *stubUpdateStore) Get(context.Context, string) (*util.Mf2Document, error) {
+	if s.doc == nil {
+		return &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{}}, nil
+	}
+	return s.doc, nil
+}
 
 func TestGetDeletionsArray(t *testing.T) {
 	input := map[string]any{"delete": []any{"category", "photo"}}
@@ -133,6 +143,9 @@ func TestUpdateWritesCreatedWhenURLChanges(t *testing.T) {
 	store := &stubUpdateStore{newURL: "https://example.org/new"}
 	st.ContentStore = store
 	st.MediaStore = &stubMediaStore{}
+	st.Hooks = &hooks.Hooks{}
+	var events []hooks.Event
+	st.Hooks.Register(hooks.ListenerFunc(func(_ context.Context, ev hooks.Event) { events = append(events, ev) }))
 
 	req := httptest.NewRequest(http.MethodPost, "/", nil)
 	req.Header.Set("Content-Type", "application/json")
@@ -156,6 +169,9 @@ func TestUpdateWritesCreatedWhenURLChanges(t *testing.T) {
 	if store.lastURL != "https://example.org/post" {
 		t.Fatalf("unexpected url sent to store: %q", store.lastURL)
 	}
+	if len(events) != 1 || events[0].Url != "https://example.org/new" || events[0].PreviousUrl != "https://example.org/post" {
+		t.Fatalf("expected the update hook to name the old and new urls, got %+v", events)
+	}
 }
 
 func TestUpdateWritesNoContentWhenURLSame(t *testing.T) {
@@ -175,3 +191,175 @@ func TestUpdateWritesNoContentWhenURLSame(t *testing.T) {
 		t.Fatalf("expected 204 when url unchanged, got %d", rr.Code)
 	}
 }
+
+func TestUpdateDraftScope(t *testing.T) {
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		return req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "draft"}))
+	}
+
+	st := &state.ScribbleState{Cfg: &config.Config{}}
+	store := &stubUpdateStore{doc: &util.Mf2Document{Properties: map[string][]any{"post-status": {"draft"}}}}
+	st.ContentStore = store
+
+	cases := []struct {
+		name string
+		data map[string]any
+		want int
+	}{
+		{"edit draft", map[string]any{"replace": map[string]any{"content": []any{"hi"}}}, http.StatusNoContent},
+		{"keep draft", map[string]any{"replace": map[string]any{"post-status": []any{"draft"}}}, http.StatusNoContent},
+		{"publish", map[string]any{"replace": map[string]any{"post-status": []any{"published"}}}, http.StatusUnauthorized},
+		{"delete status", map[string]any{"delete": []any{"post-status"}}, http.StatusUnauthorized},
+		{"invalid status", map[string]any{"replace": map[string]any{"post-status": []any{"later"}}}, http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		tc.data["url"] = "https://example.org/post"
+		rr := httptest.NewRecorder()
+		Update(st, rr, newRequest(), tc.data)
+		if rr.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, rr.Code)
+		}
+	}
+
+	store.doc = &util.Mf2Document{Properties: map[string][]any{"post-status": {"published"}}}
+	rr := httptest.NewRecorder()
+	Update(st, rr, newRequest(), map[string]any{"url": "https://example.org/post", "replace": map[string]any{"content": []any{"hi"}}})
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for editing a published post with the draft scope, got %d", rr.Code)
+	}
+}
+
+// applyingUpdateStore applies replacements and deleted properties to its document, and returns it
+// from UpdateDocument.
+type applyingUpdateStore struct {
+	stubUpdateStore
+	updates int
+	gets    int
+}
+
+func (s *applyingUpdateStore) Get(ctx context.Context, url string) (*util.Mf2Document, error) {
+	s.gets++
+	return s.stubUpdateStore.Get(ctx, url)
+}
+
+func (s *applyingUpdateStore) UpdateDocument(ctx context.Context, url string, repl map[string][]any, add map[string][]any, del any) (string, *util.Mf2Document, error) {
+	newUrl, err := s.Update(ctx, url, repl, add, del)
+	return newUrl, s.doc, err
+}
+
+func (s *applyingUpdateStore) Update(ctx context.Context, url string, repl map[string][]any, add map[string][]any, del any) (string, error) {
+	s.updates++
+	for k, v := range repl {
+		s.doc.Properties[k] = v
+	}
+	if names, ok := del.([]string); ok {
+		for _, k := range names {
+			delete(s.doc.Properties, k)
+		}
+	}
+	return s.stubUpdateStore.Update(ctx, url, repl, add, del)
+}
+
+func TestUpdateSyndicatesPublishedDraft(t *testing.T) {
+	newState := func(visibility string) (*state.ScribbleState, *applyingUpdateStore) {
+		store := &applyingUpdateStore{stubUpdateStore: stubUpdateStore{doc: &util.Mf2Document{Properties: map[string][]any{
+			"post-status":                {"draft"},
+			"visibility":                 {visibility},
+			schedule.HeldTargetsProperty: {"masto"},
+		}}}}
+		st := &state.ScribbleState{Cfg: &config.Config{}, ContentStore: store}
+		st.Jobs, _ = jobs.NewRunner(&config.Jobs{})
+		st.Syndication, _ = syndication.NewDispatcher(&config.Syndication{}, store, st.Jobs)
+		st.Syndication.AddTarget(&syndication.Target{Uid: "masto", Name: "Mastodon"})
+		return st, store
+	}
+	update := func(st *state.ScribbleState, status string) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "update"}))
+		Update(st, httptest.NewRecorder(), req, map[string]any{"url": "https://example.org/post", "replace": map[string]any{"post-status": []any{status}}})
+	}
+
+	st, store := newState("public")
+	update(st, "draft")
+	if pending := st.Jobs.Pending(); len(pending) != 0 {
+		t.Fatalf("expected no syndication while the post is a draft, got %+v", pending)
+	}
+
+	store.gets = 0
+	update(st, "published")
+	if pending := st.Jobs.Pending(); len(pending) != 1 || pending[0].Kind != syndication.JobKind {
+		t.Fatalf("expected the held target to be syndicated, got %+v", pending)
+	}
+	if store.gets != 1 {
+		t.Fatalf("expected the post to be read once, got %d reads", store.gets)
+	}
+	if _, ok := store.doc.Properties[schedule.HeldTargetsProperty]; ok {
+		t.Fatalf("expected held targets to be cleared, got %+v", store.doc.Properties)
+	}
+
+	updates := store.updates
+	update(st, "published")
+	if pending := st.Jobs.Pending(); len(pending) != 1 || store.updates != updates+1 {
+		t.Fatalf("expected a published post not to be syndicated again, got %+v", pending)
+	}
+
+	st, store = newState("unlisted")
+	update(st, "published")
+	if pending := st.Jobs.Pending(); len(pending) != 0 {
+		t.Fatalf("expected an unlisted post not to be syndicated, got %+v", pending)
+	}
+	if _, ok := store.doc.Properties[schedule.HeldTargetsProperty]; ok {
+		t.Fatalf("expected held targets to be dropped, got %+v", store.doc.Properties)
+	}
+}
+
+func TestUpdateRefusesHeldTargets(t *testing.T) {
+	st := &state.ScribbleState{Cfg: &config.Config{}, ContentStore: &stubUpdateStore{}}
+
+	for i, data := range []map[string]any{
+		{"replace": map[string]any{schedule.HeldTargetsProperty: []any{"masto"}}},
+		{"add": map[string]any{schedule.HeldTargetsProperty: []any{"masto"}}},
+		{"delete": []any{schedule.HeldTargetsProperty}},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "update"}))
+		data["url"] = "https://example.org/post"
+
+		rr := httptest.NewRecorder()
+		Update(st, rr, req, data)
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("case %d: expected 400, got %d", i, rr.Code)
+		}
+	}
+}
+
+func TestUpdateValidatesVisibility(t *testing.T) {
+	st := &state.ScribbleState{Cfg: &config.Config{}, ContentStore: &stubUpdateStore{}}
+
+	cases := []struct {
+		data map[string]any
+		want int
+	}{
+		{map[string]any{"replace": map[string]any{"visibility": []any{"unlisted"}}}, http.StatusNoContent},
+		{map[string]any{"replace": map[string]any{"visibility": []any{"hidden"}}}, http.StatusBadRequest},
+		{map[string]any{"add": map[string]any{"visibility": []any{"private"}}}, http.StatusBadRequest},
+	}
+
+	for i, tc := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Content-Type", "application/json")
+		req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Scope: "update"}))
+		tc.data["url"] = "https://example.org/post"
+
+		rr := httptest.NewRecorder()
+		Update(st, rr, req, tc.data)
+		if rr.Code != tc.want {
+			t.Fatalf("case %d: expected %d, got %d", i, tc.want, rr.Code)
+		}
+	}
+}
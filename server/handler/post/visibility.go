@@ -0,0 +1,29 @@
+package post
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// visibilities are the values of visibility understood by this server.
+var visibilities = []string{content.VisibilityPublic, content.VisibilityUnlisted, content.VisibilityPrivate}
+
+// validateVisibility checks that a post has at most one visibility, and that it is one this server
+// understands.
+func validateVisibility(values []any) error {
+	if len(values) > 1 {
+		return fmt.Errorf("visibility must have a single value")
+	}
+
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok || !slices.Contains(visibilities, strings.ToLower(s)) {
+			return fmt.Errorf("visibility must be one of %s", strings.Join(visibilities, ", "))
+		}
+	}
+
+	return nil
+}
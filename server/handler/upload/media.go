@@ -6,6 +6,8 @@ import (
 	"github.com/indieinfra/scribble/server/auth"
 	"github.com/indieinfra/scribble/server/handler/common"
 	"github.com/indieinfra/scribble/server/middleware"
+	"github.com/indieinfra/scribble/server/policy"
+	"github.com/indieinfra/scribble/server/ratelimit"
 	"github.com/indieinfra/scribble/server/resp"
 	"github.com/indieinfra/scribble/server/state"
 	"github.com/indieinfra/scribble/server/util"
@@ -33,7 +35,7 @@ func HandleMediaUpload(st *state.ScribbleState) http.HandlerFunc {
 			resp.WriteInvalidRequest(w, "access token must appear in header or body, not both")
 			return
 		}
-		r, ok = middleware.EnsureTokenForRequest(st.Cfg, w, r, token)
+		r, ok = middleware.EnsureTokenForRequest(st.Tokens, w, r, token)
 		if !ok {
 			if file != nil {
 				file.Close()
@@ -42,6 +44,17 @@ func HandleMediaUpload(st *state.ScribbleState) http.HandlerFunc {
 		}
 		defer file.Close()
 
+		op := st.Audit.Begin(w, r, "upload", "")
+		defer op.Done()
+		w = op
+
+		if !policy.Enforce(st.Cfg.Micropub.Policies, w, r, policy.Request{Write: true}) {
+			return
+		}
+		if !st.Limits.Enforce(ratelimit.Media, w, r) {
+			return
+		}
+
 		url, err := st.MediaStore.Upload(r.Context(), &file, header)
 		if err != nil {
 			common.LogAndWriteError(w, r, "upload media", err)
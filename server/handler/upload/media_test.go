@@ -77,3 +77,30 @@ func TestHandleMediaUpload_Success(t *testing.T) {
 		t.Fatalf("expected media store upload to be called")
 	}
 }
+
+func TestHandleMediaUpload_ReadOnlyClient(t *testing.T) {
+	st := newUploadState()
+	st.Cfg.Micropub.Policies = []config.Policy{{ClientId: "https://reader.example/", ReadOnly: true}}
+	ms := &fakeMediaStore{}
+	st.MediaStore = ms
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	fw, _ := w.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("hello"))
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/media", &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: st.Cfg.Micropub.MeUrl, ClientId: "https://reader.example/", Scope: "media"}))
+
+	rr := httptest.NewRecorder()
+	HandleMediaUpload(st)(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected insufficient_scope for a read-only client, got %d", rr.Code)
+	}
+	if ms.called {
+		t.Fatalf("media store should not be called for a read-only client")
+	}
+}
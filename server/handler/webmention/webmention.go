@@ -0,0 +1,42 @@
+package webmention
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/indieinfra/scribble/server/handler/common"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/webmention"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// HandleWebmention implements the public webmention receiver. Requests are answered with 202 Accepted
+// once they pass the basic checks; the source is verified in the background.
+func HandleWebmention(st *state.ScribbleState) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, int64(st.Cfg.Server.Limits.MaxPayloadSize))
+		if err := r.ParseForm(); err != nil {
+			resp.WriteInvalidRequest(w, "request body must be form encoded")
+			return
+		}
+
+		source, target := r.PostForm.Get("source"), r.PostForm.Get("target")
+		if source == "" || target == "" {
+			resp.WriteInvalidRequest(w, "source and target are required")
+			return
+		}
+
+		err := st.Mentions.Accept(r.Context(), source, target)
+		switch {
+		case err == nil:
+			resp.WriteAccepted(w, "")
+		case errors.Is(err, webmention.ErrInvalidMention):
+			resp.WriteInvalidRequest(w, err.Error())
+		case errors.Is(err, content.ErrNotFound):
+			resp.WriteInvalidRequest(w, "target is not a post on this site")
+		default:
+			common.LogAndWriteError(w, r, "accept webmention", err)
+		}
+	}
+}
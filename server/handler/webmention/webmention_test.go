@@ -0,0 +1,68 @@
+package webmention
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/server/webmention"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+type singlePostStore struct {
+	content.ContentStore
+	content.MentionStore
+}
+
+func (singlePostStore) Get(_ context.Context, url string) (*util.Mf2Document, error) {
+	if url != "https://example.org/posts/hello" {
+		return nil, content.ErrNotFound
+	}
+	return &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{}}, nil
+}
+
+func TestHandleWebmention(t *testing.T) {
+	runner, _ := jobs.NewRunner(&config.Jobs{})
+	store := singlePostStore{}
+	st := &state.ScribbleState{
+		Cfg:      &config.Config{Server: config.Server{Limits: config.ServerLimits{MaxPayloadSize: 1024}}},
+		Jobs:     runner,
+		Mentions: webmention.NewReceiver(store, store, runner, nil, false),
+	}
+
+	cases := []struct {
+		name   string
+		form   url.Values
+		status int
+	}{
+		{"accepted", url.Values{"source": {"https://a.example/reply"}, "target": {"https://example.org/posts/hello"}}, http.StatusAccepted},
+		{"missing target", url.Values{"source": {"https://a.example/reply"}}, http.StatusBadRequest},
+		{"unknown target", url.Values{"source": {"https://a.example/reply"}, "target": {"https://example.org/posts/nope"}}, http.StatusBadRequest},
+		{"bad source", url.Values{"source": {"javascript:alert(1)"}, "target": {"https://example.org/posts/hello"}}, http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webmention", strings.NewReader(tc.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+
+			HandleWebmention(st)(rr, req)
+
+			if rr.Code != tc.status {
+				t.Fatalf("expected %d, got %d: %s", tc.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if n := len(runner.Pending()); n != 1 {
+		t.Fatalf("expected one queued verification, got %d", n)
+	}
+}
@@ -0,0 +1,86 @@
+package hooks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/indieinfra/scribble/server/util"
+)
+
+// Action names the kind of content change that triggered an event.
+type Action string
+
+const (
+	ActionCreate   Action = "create"
+	ActionUpdate   Action = "update"
+	ActionDelete   Action = "delete"
+	ActionUndelete Action = "undelete"
+)
+
+// Event describes a content change that has been successfully persisted by the content store.
+type Event struct {
+	Action Action
+	Url    string
+	// Document is the stored document after the change. It may be nil when the document
+	// could not be read back from the content store.
+	Document *util.Mf2Document
+	// Previous is the document as it was before an update, delete or undelete. It is nil for
+	// creations and when the old document could not be read.
+	Previous *util.Mf2Document
+	// PreviousUrl is where the post was before an update that moved it, such as one changing its
+	// slug. It is empty when the URL stayed the same.
+	PreviousUrl string
+}
+
+// Listener receives content change events. Listeners run synchronously on the request
+// goroutine, so anything slow should be handed off to the background.
+type Listener interface {
+	ContentChanged(ctx context.Context, ev Event)
+}
+
+// ListenerFunc adapts a plain function to the Listener interface.
+type ListenerFunc func(ctx context.Context, ev Event)
+
+func (fn ListenerFunc) ContentChanged(ctx context.Context, ev Event) {
+	fn(ctx, ev)
+}
+
+// Hooks holds the listeners notified about content changes. The zero value is ready to use,
+// and a nil *Hooks has no listeners.
+type Hooks struct {
+	mu        sync.RWMutex
+	listeners []Listener
+}
+
+// Register adds a listener that will be notified of every subsequent event.
+func (h *Hooks) Register(l Listener) {
+	h.mu.Lock()
+	h.listeners = append(h.listeners, l)
+	h.mu.Unlock()
+}
+
+// Len returns the number of registered listeners.
+func (h *Hooks) Len() int {
+	if h == nil {
+		return 0
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.listeners)
+}
+
+// Fire notifies every registered listener of the event, in registration order.
+func (h *Hooks) Fire(ctx context.Context, ev Event) {
+	if h == nil {
+		return
+	}
+
+	h.mu.RLock()
+	listeners := append([]Listener(nil), h.listeners...)
+	h.mu.RUnlock()
+
+	for _, l := range listeners {
+		l.ContentChanged(ctx, ev)
+	}
+}
@@ -0,0 +1,32 @@
+package hooks
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHooksFireInOrder(t *testing.T) {
+	var h Hooks
+	var got []string
+
+	h.Register(ListenerFunc(func(_ context.Context, ev Event) { got = append(got, "a:"+string(ev.Action)) }))
+	h.Register(ListenerFunc(func(_ context.Context, ev Event) { got = append(got, "b:"+ev.Url) }))
+
+	if h.Len() != 2 {
+		t.Fatalf("expected two listeners, got %d", h.Len())
+	}
+
+	h.Fire(context.Background(), Event{Action: ActionCreate, Url: "https://example.org/a"})
+
+	if len(got) != 2 || got[0] != "a:create" || got[1] != "b:https://example.org/a" {
+		t.Fatalf("unexpected listener calls: %v", got)
+	}
+}
+
+func TestNilHooks(t *testing.T) {
+	var h *Hooks
+	if h.Len() != 0 {
+		t.Fatalf("expected nil hooks to have no listeners")
+	}
+	h.Fire(context.Background(), Event{Action: ActionDelete})
+}
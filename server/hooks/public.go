@@ -0,0 +1,43 @@
+package hooks
+
+import (
+	"context"
+
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// Public wraps a listener that only cares about publicly visible posts, such as a search index or
+// an outgoing notification. Drafts, scheduled and private posts are hidden from it: changes that never touch
+// a public post are dropped, turning a post back into a draft is delivered as a delete, and
+// publishing one is delivered as a create.
+func Public(l Listener) Listener {
+	return ListenerFunc(func(ctx context.Context, ev Event) {
+		hidden := ev.Document != nil && !isPublic(*ev.Document)
+		wasHidden := ev.Previous != nil && !isPublic(*ev.Previous)
+
+		if hidden {
+			ev.Document = nil
+		}
+		if wasHidden {
+			ev.Previous = nil
+		}
+
+		switch {
+		case hidden && (wasHidden || ev.Action == ActionCreate):
+			return
+		case hidden:
+			ev.Action = ActionDelete
+		case wasHidden && ev.Action == ActionDelete:
+			return
+		case wasHidden && ev.Action == ActionUpdate:
+			ev.Action = ActionCreate
+		}
+
+		l.ContentChanged(ctx, ev)
+	})
+}
+
+func isPublic(doc util.Mf2Document) bool {
+	return !content.IsDraft(doc) && !content.IsScheduled(doc) && content.Visibility(doc) != content.VisibilityPrivate
+}
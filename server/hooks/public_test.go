@@ -0,0 +1,42 @@
+package hooks
+
+import (
+	"context"
+	"testing"
+
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func TestPublicHidesDrafts(t *testing.T) {
+	draft := &util.Mf2Document{Properties: map[string][]any{"post-status": {"draft"}}}
+	published := &util.Mf2Document{Properties: map[string][]any{"post-status": {"published"}}}
+	private := &util.Mf2Document{Properties: map[string][]any{"visibility": {"private"}}}
+
+	cases := []struct {
+		name string
+		ev   Event
+		want Action
+	}{
+		{"create draft", Event{Action: ActionCreate, Document: draft}, ""},
+		{"edit draft", Event{Action: ActionUpdate, Document: draft, Previous: draft}, ""},
+		{"delete draft", Event{Action: ActionDelete, Previous: draft}, ""},
+		{"publish draft", Event{Action: ActionUpdate, Document: published, Previous: draft}, ActionCreate},
+		{"unpublish", Event{Action: ActionUpdate, Document: draft, Previous: published}, ActionDelete},
+		{"edit post", Event{Action: ActionUpdate, Document: published, Previous: published}, ActionUpdate},
+		{"make private", Event{Action: ActionUpdate, Document: private, Previous: published}, ActionDelete},
+	}
+
+	for _, tc := range cases {
+		var got *Event
+		Public(ListenerFunc(func(_ context.Context, ev Event) { got = &ev })).ContentChanged(context.Background(), tc.ev)
+
+		switch {
+		case tc.want == "" && got != nil:
+			t.Fatalf("%s: expected no event, got %+v", tc.name, got)
+		case tc.want != "" && (got == nil || got.Action != tc.want):
+			t.Fatalf("%s: expected %s, got %+v", tc.name, tc.want, got)
+		case got != nil && ((got.Document != nil && got.Document == draft) || (got.Previous != nil && got.Previous == draft)):
+			t.Fatalf("%s: draft leaked to listener: %+v", tc.name, got)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package indieauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/indieinfra/scribble/server/util"
+	"golang.org/x/net/html"
+)
+
+// maxClientBody caps how much of a client's metadata document or page is read.
+const maxClientBody = 1 << 20
+
+// Client describes the application asking for authorization, as far as its client_id URL tells.
+type Client struct {
+	Id   string
+	Name string
+	Url  string
+	Logo string
+	// RedirectUris are the redirect URIs the client published, beyond those on its own host.
+	RedirectUris []string
+}
+
+// DisplayName is the client's name, or its client_id when it didn't give one.
+func (c Client) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+
+	return c.Id
+}
+
+// AllowsRedirect reports whether redirectUri may receive codes for the client: it must be on the
+// client_id's scheme, host and port, or be published by the client.
+func (c Client) AllowsRedirect(redirectUri string) bool {
+	id, err := url.Parse(c.Id)
+	if err != nil {
+		return false
+	}
+	redirect, err := url.Parse(redirectUri)
+	if err != nil || redirect.Fragment != "" {
+		return false
+	}
+
+	if redirect.Scheme == id.Scheme && redirect.Host == id.Host {
+		return true
+	}
+
+	return slices.Contains(c.RedirectUris, redirectUri)
+}
+
+// clientMetadata is the JSON client metadata document a client_id may resolve to.
+type clientMetadata struct {
+	ClientId     string   `json:"client_id"`
+	ClientName   string   `json:"client_name"`
+	ClientUri    string   `json:"client_uri"`
+	LogoUri      string   `json:"logo_uri"`
+	RedirectUris []string `json:"redirect_uris"`
+}
+
+// validateClientId checks a client_id against the IndieAuth rules: an http(s) URL with a host name
+// and no fragment, credentials or dot segments. A missing path is taken as "/".
+func validateClientId(clientId string) error {
+	u, err := url.Parse(clientId)
+	if err != nil {
+		return errors.New("client_id is not a URL")
+	}
+
+	switch {
+	case u.Scheme != "https" && u.Scheme != "http":
+		return errors.New("client_id must be an http or https URL")
+	case u.Host == "":
+		return errors.New("client_id must have a host")
+	case u.Fragment != "" || u.User != nil:
+		return errors.New("client_id must not contain a fragment or credentials")
+	case slices.ContainsFunc(strings.Split(u.Path, "/"), func(s string) bool { return s == "." || s == ".." }):
+		return errors.New("client_id must not contain dot path segments")
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !ip.IsLoopback() {
+		return errors.New("client_id must not be an IP address")
+	}
+
+	return nil
+}
+
+// fetchClient retrieves what the client publishes at its client_id: a JSON metadata document, or an
+// HTML page with an h-app and rel=redirect_uri links. Clients that publish nothing are still
+// usable; they are just shown by client_id and limited to redirects on their own host.
+func fetchClient(ctx context.Context, httpClient *http.Client, clientId string) (Client, error) {
+	c := Client{Id: clientId}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, clientId, nil)
+	if err != nil {
+		return c, err
+	}
+	req.Header.Set("Accept", "application/json, text/html;q=0.9")
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return c, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return c, fmt.Errorf("client_id returned status %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxClientBody))
+	if err != nil {
+		return c, err
+	}
+
+	base := res.Request.URL
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var md clientMetadata
+		if err := json.Unmarshal(body, &md); err != nil {
+			return c, fmt.Errorf("client metadata is not valid JSON: %w", err)
+		}
+		if md.ClientId != "" && md.ClientId != clientId {
+			return c, fmt.Errorf("client metadata is for %q", md.ClientId)
+		}
+		c.Name, c.Url, c.Logo, c.RedirectUris = md.ClientName, md.ClientUri, md.LogoUri, md.RedirectUris
+	case "text/html", "application/xhtml+xml":
+		clientFromHtml(&c, body, base)
+	}
+
+	for _, header := range res.Header.Values("Link") {
+		c.RedirectUris = append(c.RedirectUris, redirectUrisFromLinkHeader(header, base)...)
+	}
+
+	return c, nil
+}
+
+func clientFromHtml(c *Client, body []byte, base *url.URL) {
+	if items, err := util.ParseMicroformats(bytes.NewReader(body), base); err == nil {
+		app := util.Find(items, "h-app")
+		if app == nil {
+			app = util.Find(items, "h-x-app")
+		}
+		if app != nil {
+			c.Name = firstString(app.Properties["name"])
+			c.Url = firstString(app.Properties["url"])
+			c.Logo = firstString(app.Properties["logo"])
+		}
+	}
+
+	if doc, err := html.Parse(bytes.NewReader(body)); err == nil {
+		c.RedirectUris = append(c.RedirectUris, redirectUrisFromHtml(doc, base)...)
+	}
+}
+
+func redirectUrisFromHtml(n *html.Node, base *url.URL) []string {
+	var out []string
+	if n.Type == html.ElementNode && (n.Data == "link" || n.Data == "a") {
+		var href, rel string
+		for _, attr := range n.Attr {
+			switch attr.Key {
+			case "href":
+				href = attr.Val
+			case "rel":
+				rel = attr.Val
+			}
+		}
+
+		if href != "" && slices.Contains(strings.Fields(strings.ToLower(rel)), "redirect_uri") {
+			if u, ok := resolve(base, href); ok {
+				out = append(out, u)
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		out = append(out, redirectUrisFromHtml(c, base)...)
+	}
+
+	return out
+}
+
+func redirectUrisFromLinkHeader(header string, base *url.URL) []string {
+	var out []string
+	for link := range strings.SplitSeq(header, ",") {
+		target, params, ok := strings.Cut(link, ";")
+		target = strings.TrimSpace(target)
+		if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+
+		for param := range strings.SplitSeq(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if !strings.EqualFold(key, "rel") {
+				continue
+			}
+			if slices.Contains(strings.Fields(strings.ToLower(strings.Trim(value, `"`))), "redirect_uri") {
+				if u, ok := resolve(base, target[1:len(target)-1]); ok {
+					out = append(out, u)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+func resolve(base *url.URL, href string) (string, bool) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+
+	return base.ResolveReference(ref).String(), true
+}
+
+func firstString(values []any) string {
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,55 @@
+package indieauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchClientFromHtml(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Link", `<https://other.example/header-cb>; rel="redirect_uri"`)
+		_, _ = w.Write([]byte(`<html><head><link rel="redirect_uri" href="https://other.example/cb"></head>
+<body><div class="h-app"><a class="u-url p-name" href="/">Quill</a></div></body></html>`))
+	}))
+	defer srv.Close()
+
+	c, err := fetchClient(context.Background(), srv.Client(), srv.URL+"/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.DisplayName() != "Quill" {
+		t.Fatalf("expected the h-app name, got %q", c.DisplayName())
+	}
+
+	for _, redirect := range []string{srv.URL + "/callback", "https://other.example/cb", "https://other.example/header-cb"} {
+		if !c.AllowsRedirect(redirect) {
+			t.Fatalf("expected %s to be allowed", redirect)
+		}
+	}
+	if c.AllowsRedirect("https://attacker.example/cb") {
+		t.Fatalf("expected an unpublished redirect on another host to be refused")
+	}
+}
+
+func TestValidateClientId(t *testing.T) {
+	cases := map[string]bool{
+		"https://app.example/":       true,
+		"http://127.0.0.1:8080/":     true,
+		"https://app.example":        true,
+		"ftp://app.example/":         false,
+		"https://app.example/#frag":  false,
+		"https://user@app.example/":  false,
+		"https://app.example/a/../b": false,
+		"https://203.0.113.5/":       false,
+		"not a url at all":           false,
+	}
+
+	for clientId, valid := range cases {
+		if err := validateClientId(clientId); (err == nil) != valid {
+			t.Fatalf("client_id %q: expected valid=%v, got %v", clientId, valid, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package indieauth
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// scopeDescriptions explain the scopes on the consent page. Unknown scopes are shown by name.
+var scopeDescriptions = map[string]string{
+	"profile":  "See your profile information",
+	"email":    "See your email address",
+	"read":     "Read your posts, including drafts and private posts it is allowed to see",
+	"create":   "Publish new posts",
+	"draft":    "Create drafts, without publishing them",
+	"update":   "Edit your posts",
+	"delete":   "Delete your posts",
+	"undelete": "Restore deleted posts",
+	"media":    "Upload photos, videos and other files",
+}
+
+type consentScope struct {
+	Name        string
+	Description string
+}
+
+type consentData struct {
+	Action       string
+	RequestId    string
+	Client       Client
+	RedirectHost string
+	Me           string
+	Scopes       []consentScope
+	Error        string
+}
+
+func (s *Server) consentPage(id string, req *authRequest, errorMessage string) consentData {
+	data := consentData{Action: s.base + "/auth", RequestId: id, Client: req.client, Me: s.me, Error: errorMessage}
+	if u, err := url.Parse(req.redirectUri); err == nil {
+		data.RedirectHost = u.Host
+	}
+	for _, scope := range req.scopes {
+		data.Scopes = append(data.Scopes, consentScope{Name: scope, Description: scopeDescriptions[scope]})
+	}
+
+	return data
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="referrer" content="no-referrer">
+<title>Sign in to {{.Client.DisplayName}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 3rem auto; padding: 0 1rem; line-height: 1.5; }
+.client { display: flex; gap: 1rem; align-items: center; }
+.client img { width: 48px; height: 48px; }
+.error { color: #b00020; }
+ul { list-style: none; padding: 0; }
+small { color: #555; }
+</style>
+</head>
+<body>
+<div class="client">
+{{with .Client.Logo}}<img src="{{.}}" alt="">{{end}}
+<div>
+<h1>{{.Client.DisplayName}}</h1>
+<small>{{if .Client.Url}}<a href="{{.Client.Url}}">{{.Client.Url}}</a>{{else}}{{.Client.Id}}{{end}}</small>
+</div>
+</div>
+<p>wants to sign in as <strong>{{.Me}}</strong> and will be sent back to <strong>{{.RedirectHost}}</strong>.</p>
+{{with .Error}}<p class="error">{{.}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="request" value="{{.RequestId}}">
+{{if .Scopes}}
+<p>It asks for permission to:</p>
+<ul>
+{{range .Scopes}}<li><label><input type="checkbox" name="scope" value="{{.Name}}" checked> {{if .Description}}{{.Description}} <small>({{.Name}})</small>{{else}}{{.Name}}{{end}}</label></li>
+{{end}}</ul>
+{{else}}
+<p>It only wants to know who you are; it will not be able to post.</p>
+{{end}}
+<p><label>Password <input type="password" name="password" autocomplete="current-password" autofocus></label></p>
+<p><button type="submit" name="action" value="approve">Allow</button> <button type="submit" name="action" value="deny">Deny</button></p>
+</form>
+</body>
+</html>
+`))
+
+var errorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Authorization failed</title>
+</head>
+<body>
+<h1>Authorization failed</h1>
+<p>{{.}}</p>
+</body>
+</html>
+`))
+
+func writeConsentPage(w http.ResponseWriter, status int, data consentData) {
+	writeHtml(w, status, consentTemplate, data)
+}
+
+func writeErrorPage(w http.ResponseWriter, status int, message string) {
+	writeHtml(w, status, errorTemplate, message)
+}
+
+func writeHtml(w http.ResponseWriter, status int, tmpl *template.Template, data any) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("error: could not render %s page: %v", tmpl.Name(), err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	// The consent page must not be framed, or a site could trick the owner into approving.
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Content-Security-Policy", "default-src 'none'; img-src https: data:; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
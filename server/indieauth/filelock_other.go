@@ -0,0 +1,9 @@
+//go:build !unix
+
+package indieauth
+
+// lockFile is a no-op where flock isn't available; writes are still made against the file as it is
+// on disk, which keeps the window for losing another process's change small.
+func lockFile(string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,88 @@
+package indieauth
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// maxPasswordFailures is how many wrong passwords an address may send before it is locked out.
+	maxPasswordFailures = 5
+	// passwordLockout is how long an address is first locked out for. It doubles with every further
+	// lockout, up to maxPasswordLockout.
+	passwordLockout    = time.Minute
+	maxPasswordLockout = time.Hour
+	// failureMemory is how long an address's wrong passwords are remembered once it isn't locked out.
+	failureMemory = 24 * time.Hour
+	// maxTrackedAddresses caps how many addresses wrong passwords are remembered for. While it is
+	// reached, passwords from new addresses aren't checked either, which stops guessing spread
+	// over many addresses.
+	maxTrackedAddresses = 1000
+)
+
+// passwordFailures counts the wrong passwords sent from one address.
+type passwordFailures struct {
+	count    int
+	lockouts int
+	until    time.Time
+	last     time.Time
+}
+
+// lockedOut returns how long addr must wait before a password it sends is checked again.
+func (s *Server) lockedOut(addr string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	f, ok := s.failures[addr]
+	if !ok {
+		if len(s.failures) >= maxTrackedAddresses {
+			s.pruneFailures(now)
+		}
+		if len(s.failures) >= maxTrackedAddresses {
+			return passwordLockout
+		}
+		return 0
+	}
+
+	return max(f.until.Sub(now), 0)
+}
+
+// recordPassword counts a wrong password from addr, locking it out after too many. The right password
+// clears the address's record.
+func (s *Server) recordPassword(addr string, match bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if match {
+		delete(s.failures, addr)
+		return
+	}
+
+	now := s.now()
+	f, ok := s.failures[addr]
+	if !ok {
+		f = &passwordFailures{}
+		s.failures[addr] = f
+	}
+
+	f.count++
+	f.last = now
+	if f.count >= maxPasswordFailures {
+		lockout := min(passwordLockout<<min(f.lockouts, 10), maxPasswordLockout)
+		f.count = 0
+		f.lockouts++
+		f.until = now.Add(lockout)
+		log.Printf("warning: locking out %s for %v after %d wrong passwords", addr, lockout, maxPasswordFailures)
+	}
+}
+
+// pruneFailures forgets addresses that are no longer locked out and haven't sent a wrong password
+// for a while. The caller must hold s.mu.
+func (s *Server) pruneFailures(now time.Time) {
+	for addr, f := range s.failures {
+		if !now.Before(f.until) && now.Sub(f.last) >= failureMemory {
+			delete(s.failures, addr)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package indieauth
+
+import "testing"
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := CheckPassword(hash, "s3cret"); !ok || err != nil {
+		t.Fatalf("expected the password to match its hash, got %v, %v", ok, err)
+	}
+	if ok, _ := CheckPassword(hash, "S3cret"); ok {
+		t.Fatalf("expected another password not to match")
+	}
+
+	if other, _ := HashPassword("s3cret"); other == hash {
+		t.Fatalf("expected hashes to be salted")
+	}
+}
+
+func TestCheckPasswordRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{"", "s3cret", "bcrypt$10$abc$def", "pbkdf2-sha256$x$abc$def", "pbkdf2-sha256$1000$!!$def"} {
+		if _, err := CheckPassword(hash, "s3cret"); err == nil {
+			t.Fatalf("expected %q to be refused", hash)
+		}
+	}
+}
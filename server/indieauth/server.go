@@ -0,0 +1,522 @@
+// Package indieauth is a small IndieAuth server for a single site owner: it authorizes clients
+// after the owner approves them with a password, and issues and revokes the access tokens scribble
+// then accepts.
+package indieauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/middleware"
+	"github.com/indieinfra/scribble/server/publicnet"
+)
+
+const (
+	// codeLifetime bounds how long pending consent requests and authorization codes stay valid.
+	codeLifetime = 10 * time.Minute
+	// clientTimeout bounds fetching a client's metadata.
+	clientTimeout = 5 * time.Second
+	// maxPasswordAttempts is how many wrong passwords a consent request survives.
+	maxPasswordAttempts = 5
+	// maxPendingRequests caps the consent requests waiting for the owner; the oldest are dropped to
+	// make room for new ones.
+	maxPendingRequests = 100
+)
+
+// scopesSupported are the scopes advertised in the server metadata.
+var scopesSupported = []string{"profile", "read", "create", "draft", "update", "delete", "undelete", "media"}
+
+// Server is the built-in IndieAuth server.
+type Server struct {
+	base          string
+	me            string
+	passwordHash  string
+	tokenLifetime time.Duration
+	tokens        *TokenStore
+	client        *http.Client
+	forwardedFor  bool
+	now           func() time.Time
+
+	mu       sync.Mutex
+	requests map[string]*authRequest
+	codes    map[string]*authCode
+	failures map[string]*passwordFailures
+}
+
+// authRequest is an authorization request waiting for the owner's consent.
+type authRequest struct {
+	client      Client
+	redirectUri string
+	state       string
+	challenge   string
+	scopes      []string
+	attempts    int
+	expires     time.Time
+}
+
+// authCode is an authorization code waiting to be redeemed by the client.
+type authCode struct {
+	clientId    string
+	redirectUri string
+	challenge   string
+	scope       string
+	expires     time.Time
+}
+
+// NewServer creates the IndieAuth server for cfg, issuing tokens into tokens. A nil client gets one
+// with a short timeout that only fetches client metadata from public addresses.
+func NewServer(cfg *config.Config, tokens *TokenStore, client *http.Client) (*Server, error) {
+	if cfg.IndieAuth.PasswordHash == "" {
+		return nil, errors.New("indieauth.password_hash is required for the built-in IndieAuth server; create one with \"scribble password\"")
+	}
+	if _, err := CheckPassword(cfg.IndieAuth.PasswordHash, ""); err != nil {
+		return nil, errors.New("indieauth.password_hash is not a hash created by \"scribble password\"")
+	}
+
+	if client == nil {
+		client = publicnet.NewClient(clientTimeout)
+	}
+
+	return &Server{
+		base:          strings.TrimSuffix(cfg.Server.PublicUrl, "/"),
+		me:            cfg.Micropub.MeUrl,
+		passwordHash:  cfg.IndieAuth.PasswordHash,
+		tokenLifetime: cfg.IndieAuth.TokenLifetime,
+		tokens:        tokens,
+		client:        client,
+		forwardedFor:  cfg.Server.RateLimit.ForwardedFor,
+		now:           time.Now,
+		requests:      map[string]*authRequest{},
+		codes:         map[string]*authCode{},
+		failures:      map[string]*passwordFailures{},
+	}, nil
+}
+
+// Issuer is the server's issuer identifier, returned to clients as iss.
+func (s *Server) Issuer() string {
+	return s.base + "/"
+}
+
+// MetadataUrl is where the server metadata is served. The site at me_url should point at it with
+// <link rel="indieauth-metadata">.
+func (s *Server) MetadataUrl() string {
+	return s.base + "/.well-known/oauth-authorization-server"
+}
+
+// HandleMetadata serves the IndieAuth server metadata.
+func (s *Server) HandleMetadata(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, http.StatusOK, map[string]any{
+		"issuer":                 s.Issuer(),
+		"authorization_endpoint": s.base + "/auth",
+		"token_endpoint":         s.base + "/token",
+		"revocation_endpoint":    s.base + "/revoke",
+		"revocation_endpoint_auth_methods_supported":     []string{"none"},
+		"scopes_supported":                               scopesSupported,
+		"response_types_supported":                       []string{"code"},
+		"grant_types_supported":                          []string{"authorization_code"},
+		"code_challenge_methods_supported":               []string{"S256"},
+		"authorization_response_iss_parameter_supported": true,
+	})
+}
+
+// HandleAuthorize validates an authorization request and asks the owner for consent.
+func (s *Server) HandleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	clientId := q.Get("client_id")
+	redirectUri := q.Get("redirect_uri")
+
+	// Until the redirect URI is known to belong to the client, errors are shown here rather than
+	// sent back to it.
+	if err := validateClientId(clientId); err != nil {
+		writeErrorPage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// A client on the owner's own machine publishes nothing the server could reach, and fetching it
+	// would let anyone make the server request its own loopback services.
+	client := Client{Id: clientId}
+	if u, _ := url.Parse(clientId); !publicnet.IsLoopback(u.Hostname()) {
+		var err error
+		client, err = fetchClient(r.Context(), s.client, clientId)
+		if err != nil {
+			log.Printf("warning: could not fetch client information for %s: %v", clientId, err)
+		}
+	}
+	if !client.AllowsRedirect(redirectUri) {
+		writeErrorPage(w, http.StatusBadRequest, "The redirect_uri is not registered for this application.")
+		return
+	}
+
+	state := q.Get("state")
+	switch {
+	case q.Get("response_type") != "code" && q.Get("response_type") != "id":
+		redirectError(w, r, redirectUri, state, "unsupported_response_type", "response_type must be code")
+		return
+	case state == "":
+		redirectError(w, r, redirectUri, state, "invalid_request", "state is required")
+		return
+	case q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256":
+		redirectError(w, r, redirectUri, state, "invalid_request", "a code_challenge with code_challenge_method S256 is required")
+		return
+	case q.Get("me") != "" && normalizeUrl(q.Get("me")) != normalizeUrl(s.me):
+		redirectError(w, r, redirectUri, state, "access_denied", "this server only signs in "+s.me)
+		return
+	}
+
+	id, err := randomString(24)
+	if err != nil {
+		writeErrorPage(w, http.StatusInternalServerError, "Could not start the authorization.")
+		return
+	}
+
+	req := &authRequest{
+		client:      client,
+		redirectUri: redirectUri,
+		state:       state,
+		challenge:   q.Get("code_challenge"),
+		scopes:      strings.Fields(q.Get("scope")),
+		expires:     s.now().Add(codeLifetime),
+	}
+
+	s.mu.Lock()
+	s.prune()
+	s.makeRoom()
+	s.requests[id] = req
+	s.mu.Unlock()
+
+	writeConsentPage(w, http.StatusOK, s.consentPage(id, req, ""))
+}
+
+// HandleAuthorizePost takes the owner's decision from the consent page. Clients that only wanted to
+// know who signed in also redeem their code here.
+func (s *Server) HandleAuthorizePost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	if r.PostForm.Has("code") {
+		if _, ok := s.redeem(w, r); ok {
+			writeJson(w, http.StatusOK, map[string]string{"me": s.me})
+		}
+		return
+	}
+
+	id := r.PostForm.Get("request")
+
+	s.mu.Lock()
+	req, ok := s.requests[id]
+	if ok && !s.now().Before(req.expires) {
+		delete(s.requests, id)
+		ok = false
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeErrorPage(w, http.StatusBadRequest, "This authorization request has expired. Start again from the application.")
+		return
+	}
+
+	if r.PostForm.Get("action") != "approve" {
+		s.forget(id)
+		redirectError(w, r, req.redirectUri, req.state, "access_denied", "the owner denied the request")
+		return
+	}
+
+	// Addresses that keep sending wrong passwords are turned away before the costly check.
+	addr := middleware.RemoteIp(r, s.forwardedFor)
+	if wait := s.lockedOut(addr); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		writeErrorPage(w, http.StatusTooManyRequests, "Too many wrong passwords. Try again later.")
+		return
+	}
+
+	match, err := CheckPassword(s.passwordHash, r.PostForm.Get("password"))
+	if err != nil {
+		log.Printf("error: could not check password: %v", err)
+		writeErrorPage(w, http.StatusInternalServerError, "Could not check the password.")
+		return
+	}
+	s.recordPassword(addr, match)
+	if !match {
+		s.mu.Lock()
+		req.attempts++
+		tooMany := req.attempts >= maxPasswordAttempts
+		if tooMany {
+			delete(s.requests, id)
+		}
+		s.mu.Unlock()
+
+		log.Printf("warning: wrong password for authorization request from %s", req.client.Id)
+		if tooMany {
+			writeErrorPage(w, http.StatusUnauthorized, "Too many wrong passwords. Start again from the application.")
+			return
+		}
+		writeConsentPage(w, http.StatusUnauthorized, s.consentPage(id, req, "Wrong password."))
+		return
+	}
+
+	// Only scopes that were both requested and left checked are granted.
+	var granted []string
+	for _, scope := range r.PostForm["scope"] {
+		if slices.Contains(req.scopes, scope) && !slices.Contains(granted, scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	code, err := randomString(32)
+	if err != nil {
+		writeErrorPage(w, http.StatusInternalServerError, "Could not issue an authorization code.")
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.requests, id)
+	s.codes[code] = &authCode{
+		clientId:    req.client.Id,
+		redirectUri: req.redirectUri,
+		challenge:   req.challenge,
+		scope:       strings.Join(granted, " "),
+		expires:     s.now().Add(codeLifetime),
+	}
+	s.mu.Unlock()
+
+	redirect(w, r, req.redirectUri, url.Values{"code": {code}, "state": {req.state}, "iss": {s.Issuer()}})
+}
+
+// HandleToken exchanges an authorization code for an access token. The legacy action=revoke
+// request is accepted too.
+func (s *Server) HandleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	if r.PostForm.Get("action") == "revoke" {
+		s.revoke(w, r.PostForm.Get("token"))
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be authorization_code")
+		return
+	}
+
+	code, ok := s.redeem(w, r)
+	if !ok {
+		return
+	}
+
+	// A code granted without scopes only proves who signed in; no access token is issued for it.
+	if code.scope == "" {
+		writeJson(w, http.StatusOK, map[string]string{"me": s.me})
+		return
+	}
+
+	token, issued, err := s.tokens.Issue(s.me, code.clientId, code.scope, s.tokenLifetime)
+	if err != nil {
+		log.Printf("error: could not issue token: %v", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "could not issue a token")
+		return
+	}
+
+	res := map[string]any{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"scope":        issued.Scope,
+		"me":           issued.Me,
+	}
+	if !issued.ExpiresAt.IsZero() {
+		res["expires_in"] = int(issued.ExpiresAt.Sub(issued.IssuedAt).Seconds())
+	}
+
+	writeJson(w, http.StatusOK, res)
+}
+
+// HandleTokenInfo answers the legacy token verification request: a GET with the token as bearer.
+func (s *Server) HandleTokenInfo(w http.ResponseWriter, r *http.Request) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "a bearer token is required")
+		return
+	}
+
+	details, err := s.tokens.Verify(r.Context(), strings.TrimSpace(token))
+	if err != nil {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_token", "the token is not valid")
+		return
+	}
+
+	writeJson(w, http.StatusOK, details)
+}
+
+// HandleRevoke revokes a token (RFC 7009). Unknown tokens are not an error.
+func (s *Server) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	s.revoke(w, r.PostForm.Get("token"))
+}
+
+func (s *Server) revoke(w http.ResponseWriter, token string) {
+	if token == "" {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	if err := s.tokens.Revoke(token); err != nil {
+		log.Printf("error: could not revoke token: %v", err)
+		writeOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "could not revoke the token")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// redeem checks and consumes the authorization code in the request. On failure it writes the error
+// response itself.
+func (s *Server) redeem(w http.ResponseWriter, r *http.Request) (*authCode, bool) {
+	s.mu.Lock()
+	code, ok := s.codes[r.PostForm.Get("code")]
+	// Codes are single use, even if redeeming fails.
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	switch {
+	case !ok || !s.now().Before(code.expires):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "the authorization code is invalid or expired")
+	case code.clientId != r.PostForm.Get("client_id") || code.redirectUri != r.PostForm.Get("redirect_uri"):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "the authorization code was issued to another client")
+	case !verifyChallenge(code.challenge, r.PostForm.Get("code_verifier")):
+		writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code_challenge")
+	default:
+		return code, true
+	}
+
+	return nil, false
+}
+
+// forget drops a pending consent request.
+func (s *Server) forget(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.requests, id)
+}
+
+// prune drops expired consent requests and codes, and forgotten password failures. The caller must hold s.mu.
+func (s *Server) prune() {
+	now := s.now()
+	for id, req := range s.requests {
+		if !now.Before(req.expires) {
+			delete(s.requests, id)
+		}
+	}
+	for code, c := range s.codes {
+		if !now.Before(c.expires) {
+			delete(s.codes, code)
+		}
+	}
+	s.pruneFailures(now)
+}
+
+// makeRoom drops the oldest consent requests while there are too many waiting. The caller must hold
+// s.mu.
+func (s *Server) makeRoom() {
+	for len(s.requests) >= maxPendingRequests {
+		var oldest string
+		for id, req := range s.requests {
+			if oldest == "" || req.expires.Before(s.requests[oldest].expires) {
+				oldest = id
+			}
+		}
+		delete(s.requests, oldest)
+	}
+}
+
+// verifyChallenge checks a PKCE code_verifier against its S256 code_challenge.
+func verifyChallenge(challenge string, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	want := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(want), []byte(challenge)) == 1
+}
+
+func redirect(w http.ResponseWriter, r *http.Request, redirectUri string, params url.Values) {
+	u, err := url.Parse(redirectUri)
+	if err != nil {
+		writeErrorPage(w, http.StatusBadRequest, "The redirect_uri is not a URL.")
+		return
+	}
+
+	q := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			q.Set(key, values[0])
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+func redirectError(w http.ResponseWriter, r *http.Request, redirectUri string, state string, code string, description string) {
+	redirect(w, r, redirectUri, url.Values{"error": {code}, "error_description": {description}, "state": {state}})
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code string, description string) {
+	writeJson(w, status, map[string]string{"error": code, "error_description": description})
+}
+
+func writeJson(w http.ResponseWriter, status int, object any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(object); err != nil {
+		log.Printf("error: could not write response: %v", err)
+	}
+}
+
+func normalizeUrl(u string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(u)), "/")
+}
+
+// randomString returns n random bytes, base64url encoded.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
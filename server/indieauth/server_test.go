@@ -0,0 +1,376 @@
+package indieauth
+
+import (
+	"context"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+)
+
+const (
+	testPassword = "correct horse"
+	testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk-verifier"
+)
+
+// cheapHash hashes password with few iterations, to keep the tests fast.
+func cheapHash(t *testing.T, password string) string {
+	t.Helper()
+
+	salt := []byte("0123456789abcdef")
+	key, err := pbkdf2.Key(sha256.New, password, salt, 1000, passwordKeySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("%s$1000$%s$%s", passwordScheme, enc.EncodeToString(salt), enc.EncodeToString(key))
+}
+
+func challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// testClientId is the client_id of the test app. Its requests are sent to the test server, as
+// loopback client_ids are not fetched.
+const testClientId = "http://app.example/"
+
+// newTestServer returns the IndieAuth server and the client_id of a client whose JSON metadata
+// registers https://callback.example/cb as a redirect URI.
+func newTestServer(t *testing.T) (*Server, string) {
+	t.Helper()
+
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"client_id":%q,"client_name":"Test App","redirect_uris":["https://callback.example/cb"]}`, testClientId)
+	}))
+	t.Cleanup(app.Close)
+
+	transport := app.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network string, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, app.Listener.Addr().String())
+	}
+
+	tokens, err := NewTokenStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Server:    config.Server{PublicUrl: "https://scribble.example.org"},
+		Micropub:  config.Micropub{MeUrl: "https://example.org/"},
+		IndieAuth: config.IndieAuth{PasswordHash: cheapHash(t, testPassword)},
+	}
+	s, err := NewServer(cfg, tokens, &http.Client{Transport: transport})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, testClientId
+}
+
+func authorizeQuery(clientId string, scope string) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientId},
+		"redirect_uri":          {"https://callback.example/cb"},
+		"state":                 {"xyz"},
+		"code_challenge":        {challenge(testVerifier)},
+		"code_challenge_method": {"S256"},
+		"scope":                 {scope},
+	}
+}
+
+var requestIdPattern = regexp.MustCompile(`name="request" value="([^"]+)"`)
+
+// startAuthorization requests authorization and returns the consent page's request id.
+func startAuthorization(t *testing.T, s *Server, query url.Values) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	s.HandleAuthorize(rr, httptest.NewRequest(http.MethodGet, "/auth?"+query.Encode(), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the consent page, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-Frame-Options") != "DENY" {
+		t.Fatalf("expected the consent page to refuse framing")
+	}
+
+	m := requestIdPattern.FindStringSubmatch(rr.Body.String())
+	if m == nil {
+		t.Fatalf("consent page has no request id: %s", rr.Body.String())
+	}
+
+	return m[1]
+}
+
+func postForm(handler http.HandlerFunc, target string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	s, clientId := newTestServer(t)
+
+	id := startAuthorization(t, s, authorizeQuery(clientId, "create update delete"))
+
+	// The owner unchecks delete.
+	rr := postForm(s.HandleAuthorizePost, "/auth", url.Values{
+		"request":  {id},
+		"action":   {"approve"},
+		"password": {testPassword},
+		"scope":    {"create", "update", "media"},
+	})
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected a redirect to the client, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	location, _ := url.Parse(rr.Header().Get("Location"))
+	if location.Host != "callback.example" || location.Query().Get("state") != "xyz" || location.Query().Get("iss") != s.Issuer() {
+		t.Fatalf("unexpected redirect %s", location)
+	}
+	code := location.Query().Get("code")
+
+	exchange := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"client_id":     {clientId},
+		"redirect_uri":  {"https://callback.example/cb"},
+		"code_verifier": {testVerifier},
+	}
+	rr = postForm(s.HandleToken, "/token", exchange)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected a token, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var res struct {
+		AccessToken string `json:"access_token"`
+		Scope       string `json:"scope"`
+		Me          string `json:"me"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.AccessToken == "" || res.Scope != "create update" || res.Me != "https://example.org/" {
+		t.Fatalf("unexpected token response %s", rr.Body.String())
+	}
+
+	details, err := s.tokens.Verify(context.Background(), res.AccessToken)
+	if err != nil {
+		t.Fatalf("expected the issued token to verify, got %v", err)
+	}
+	if details.ClientId != clientId || details.HasScope(auth.ScopeDelete) {
+		t.Fatalf("unexpected token details %+v", details)
+	}
+
+	// Codes are single use.
+	if rr := postForm(s.HandleToken, "/token", exchange); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected a redeemed code to be refused, got %d", rr.Code)
+	}
+
+	if rr := postForm(s.HandleRevoke, "/revoke", url.Values{"token": {res.AccessToken}}); rr.Code != http.StatusOK {
+		t.Fatalf("expected revocation to succeed, got %d", rr.Code)
+	}
+	if _, err := s.tokens.Verify(context.Background(), res.AccessToken); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("expected a revoked token to be invalid, got %v", err)
+	}
+}
+
+func TestTokenRequiresCodeVerifier(t *testing.T) {
+	s, clientId := newTestServer(t)
+
+	id := startAuthorization(t, s, authorizeQuery(clientId, "create"))
+	rr := postForm(s.HandleAuthorizePost, "/auth", url.Values{"request": {id}, "action": {"approve"}, "password": {testPassword}, "scope": {"create"}})
+	location, _ := url.Parse(rr.Header().Get("Location"))
+
+	rr = postForm(s.HandleToken, "/token", url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"client_id":     {clientId},
+		"redirect_uri":  {"https://callback.example/cb"},
+		"code_verifier": {strings.Repeat("x", 43)},
+	})
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "invalid_grant") {
+		t.Fatalf("expected a wrong code_verifier to be refused, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestAuthorizeWrongPassword(t *testing.T) {
+	s, clientId := newTestServer(t)
+
+	id := startAuthorization(t, s, authorizeQuery(clientId, "create"))
+	form := url.Values{"request": {id}, "action": {"approve"}, "password": {"wrong"}}
+
+	for range maxPasswordAttempts - 1 {
+		if rr := postForm(s.HandleAuthorizePost, "/auth", form); rr.Code != http.StatusUnauthorized || !strings.Contains(rr.Body.String(), "Wrong password") {
+			t.Fatalf("expected the consent page again, got %d", rr.Code)
+		}
+	}
+
+	// After too many attempts the request is gone, even with the right password.
+	_ = postForm(s.HandleAuthorizePost, "/auth", form)
+	form.Set("password", testPassword)
+	if rr := postForm(s.HandleAuthorizePost, "/auth", form); rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected the request to be dropped after too many wrong passwords, got %d", rr.Code)
+	}
+}
+
+func TestAuthorizeLocksOutAddress(t *testing.T) {
+	s, clientId := newTestServer(t)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	// Wrong passwords count against the address, across consent requests.
+	for i := range maxPasswordFailures {
+		id := startAuthorization(t, s, authorizeQuery(clientId, "create"))
+		if i%2 == 1 {
+			id = startAuthorization(t, s, authorizeQuery(clientId, "create"))
+		}
+		if rr := postForm(s.HandleAuthorizePost, "/auth", url.Values{"request": {id}, "action": {"approve"}, "password": {"wrong"}}); rr.Code != http.StatusUnauthorized {
+			t.Fatalf("attempt %d: expected 401, got %d", i, rr.Code)
+		}
+	}
+
+	approve := url.Values{"request": {startAuthorization(t, s, authorizeQuery(clientId, "create"))}, "action": {"approve"}, "password": {testPassword}}
+	rr := postForm(s.HandleAuthorizePost, "/auth", approve)
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Fatalf("expected the address to be locked out, got %d with Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	// Other addresses are unaffected.
+	req := httptest.NewRequest(http.MethodPost, "/auth", strings.NewReader(approve.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = "198.51.100.7:1234"
+	rr = httptest.NewRecorder()
+	s.HandleAuthorizePost(rr, req)
+	if rr.Code != http.StatusFound {
+		t.Fatalf("expected another address to sign in, got %d", rr.Code)
+	}
+
+	// Once the lockout is over the right password is accepted again.
+	now = now.Add(passwordLockout)
+	approve.Set("request", startAuthorization(t, s, authorizeQuery(clientId, "create")))
+	if rr := postForm(s.HandleAuthorizePost, "/auth", approve); rr.Code != http.StatusFound {
+		t.Fatalf("expected the lockout to end, got %d", rr.Code)
+	}
+}
+
+func TestAuthorizeDoesNotFetchLoopbackClients(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("unexpected fetch of %s", r.URL)
+		return nil, errors.New("no fetching")
+	})}
+
+	q := authorizeQuery("http://127.0.0.1:8080/", "create")
+	q.Set("redirect_uri", "http://127.0.0.1:8080/cb")
+	startAuthorization(t, s, q)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestAuthorizeCapsPendingRequests(t *testing.T) {
+	s, clientId := newTestServer(t)
+	now := time.Now()
+	s.now = func() time.Time {
+		now = now.Add(time.Millisecond)
+		return now
+	}
+
+	first := startAuthorization(t, s, authorizeQuery(clientId, "create"))
+	for range maxPendingRequests + 5 {
+		startAuthorization(t, s, authorizeQuery(clientId, "create"))
+	}
+
+	s.mu.Lock()
+	_, kept := s.requests[first]
+	pending := len(s.requests)
+	s.mu.Unlock()
+	if pending > maxPendingRequests || kept {
+		t.Fatalf("expected at most %d pending requests without the oldest, got %d (oldest kept: %v)", maxPendingRequests, pending, kept)
+	}
+}
+
+func TestAuthorizeDeny(t *testing.T) {
+	s, clientId := newTestServer(t)
+
+	id := startAuthorization(t, s, authorizeQuery(clientId, "create"))
+	rr := postForm(s.HandleAuthorizePost, "/auth", url.Values{"request": {id}, "action": {"deny"}})
+
+	location, _ := url.Parse(rr.Header().Get("Location"))
+	if rr.Code != http.StatusFound || location.Query().Get("error") != "access_denied" || location.Query().Get("code") != "" {
+		t.Fatalf("expected access_denied to be sent to the client, got %d %s", rr.Code, location)
+	}
+}
+
+func TestAuthorizeRejectsBadRequests(t *testing.T) {
+	s, clientId := newTestServer(t)
+
+	// An unregistered redirect URI is never redirected to.
+	q := authorizeQuery(clientId, "create")
+	q.Set("redirect_uri", "https://attacker.example/cb")
+	rr := httptest.NewRecorder()
+	s.HandleAuthorize(rr, httptest.NewRequest(http.MethodGet, "/auth?"+q.Encode(), nil))
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Location") != "" {
+		t.Fatalf("expected an error page for a foreign redirect_uri, got %d", rr.Code)
+	}
+
+	// Without PKCE the client is sent an error.
+	q = authorizeQuery(clientId, "create")
+	q.Del("code_challenge")
+	rr = httptest.NewRecorder()
+	s.HandleAuthorize(rr, httptest.NewRequest(http.MethodGet, "/auth?"+q.Encode(), nil))
+	location, _ := url.Parse(rr.Header().Get("Location"))
+	if rr.Code != http.StatusFound || location.Query().Get("error") != "invalid_request" {
+		t.Fatalf("expected invalid_request for a missing code_challenge, got %d %s", rr.Code, location)
+	}
+}
+
+func TestProfileOnlyCodeIssuesNoToken(t *testing.T) {
+	s, clientId := newTestServer(t)
+
+	id := startAuthorization(t, s, authorizeQuery(clientId, ""))
+	rr := postForm(s.HandleAuthorizePost, "/auth", url.Values{"request": {id}, "action": {"approve"}, "password": {testPassword}})
+	location, _ := url.Parse(rr.Header().Get("Location"))
+
+	rr = postForm(s.HandleAuthorizePost, "/auth", url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"client_id":     {clientId},
+		"redirect_uri":  {"https://callback.example/cb"},
+		"code_verifier": {testVerifier},
+	})
+	if rr.Code != http.StatusOK || strings.Contains(rr.Body.String(), "access_token") || !strings.Contains(rr.Body.String(), `"me":"https://example.org/"`) {
+		t.Fatalf("expected only the profile URL, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNewServerRequiresPasswordHash(t *testing.T) {
+	tokens, _ := NewTokenStore("")
+	for _, hash := range []string{"", "plaintext"} {
+		cfg := &config.Config{IndieAuth: config.IndieAuth{PasswordHash: hash}}
+		if _, err := NewServer(cfg, tokens, nil); err == nil {
+			t.Fatalf("expected password hash %q to be refused", hash)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package indieauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/server/auth"
+)
+
+// Token is an access token issued by the built-in server. The token itself is never stored, only
+// its hash.
+type Token struct {
+	// Id identifies the token when listing or revoking it, without revealing it.
+	Id        string    `json:"id"`
+	Hash      string    `json:"hash"`
+	Me        string    `json:"me"`
+	ClientId  string    `json:"client_id"`
+	Scope     string    `json:"scope"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	// LastUsedAt is when the token was last verified, to within lastUsedResolution.
+	LastUsedAt time.Time `json:"last_used_at,omitzero"`
+}
+
+func (t Token) expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// lastUsedResolution limits how often a token's last use is written back to the database file.
+const lastUsedResolution = time.Minute
+
+// TokenStore is the database of issued tokens, optionally persisted to a JSON file. It verifies the
+// tokens it issued in-process. Changes made to the file by another process, such as the token CLI,
+// are picked up.
+type TokenStore struct {
+	path string
+	now  func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]Token
+	// modTime is the modification time of the file as last read or written.
+	modTime time.Time
+}
+
+var _ auth.Verifier = (*TokenStore)(nil)
+
+func NewTokenStore(path string) (*TokenStore, error) {
+	ts := &TokenStore{path: path, now: time.Now, tokens: map[string]Token{}}
+	if err := ts.reload(); err != nil {
+		return nil, err
+	}
+
+	return ts, nil
+}
+
+// reload reads the database file again if it changed since it was last read or written. The caller
+// must hold ts.mu.
+func (ts *TokenStore) reload() error {
+	if ts.path == "" {
+		return nil
+	}
+
+	info, err := os.Stat(ts.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read token database: %w", err)
+	}
+	if info.ModTime().Equal(ts.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(ts.path)
+	if err != nil {
+		return fmt.Errorf("failed to read token database: %w", err)
+	}
+
+	var list []Token
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("failed to decode token database: %w", err)
+	}
+
+	tokens := make(map[string]Token, len(list))
+	for _, t := range list {
+		// Uses noted here but not yet written back are kept.
+		if known, ok := ts.tokens[t.Hash]; ok && known.LastUsedAt.After(t.LastUsedAt) {
+			t.LastUsedAt = known.LastUsedAt
+		}
+		tokens[t.Hash] = t
+	}
+
+	ts.tokens = tokens
+	ts.modTime = info.ModTime()
+	return nil
+}
+
+// Issue creates a token for me and clientId with the given scope. A zero lifetime never expires.
+// The token is only ever returned here.
+func (ts *TokenStore) Issue(me string, clientId string, scope string, lifetime time.Duration) (string, Token, error) {
+	token, err := randomString(32)
+	if err != nil {
+		return "", Token{}, err
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return "", Token{}, err
+	}
+
+	now := ts.now()
+	t := Token{Id: id, Hash: auth.HashToken(token), Me: me, ClientId: clientId, Scope: scope, IssuedAt: now}
+	if lifetime > 0 {
+		t.ExpiresAt = now.Add(lifetime)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	err = ts.change(func() bool {
+		ts.tokens[t.Hash] = t
+		return true
+	})
+	if err != nil {
+		delete(ts.tokens, t.Hash)
+		return "", Token{}, err
+	}
+
+	return token, t, nil
+}
+
+// Lookup returns the record of a token that was issued, has not been revoked and has not expired,
+// and notes that it was used.
+func (ts *TokenStore) Lookup(token string) (Token, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if err := ts.reload(); err != nil {
+		// Keep serving the tokens already known rather than locking everyone out.
+		log.Printf("error: %v", err)
+	}
+
+	hash := auth.HashToken(token)
+	t, ok := ts.tokens[hash]
+	now := ts.now()
+	if !ok || t.expired(now) {
+		return Token{}, false
+	}
+
+	if now.Sub(t.LastUsedAt) >= lastUsedResolution {
+		// The token is looked up again under the file's lock, in case it was revoked meanwhile.
+		err := ts.change(func() bool {
+			t, ok = ts.tokens[hash]
+			if !ok {
+				return false
+			}
+			t.LastUsedAt = now
+			ts.tokens[hash] = t
+			return true
+		})
+		if err != nil {
+			log.Printf("error: could not record token use: %v", err)
+		}
+		if !ok {
+			return Token{}, false
+		}
+	}
+
+	return t, true
+}
+
+// Verify implements auth.Verifier for the tokens in the store.
+func (ts *TokenStore) Verify(_ context.Context, token string) (*auth.TokenDetails, error) {
+	t, ok := ts.Lookup(token)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown, revoked or expired token", auth.ErrInvalidToken)
+	}
+
+	details := &auth.TokenDetails{Me: t.Me, ClientId: t.ClientId, Scope: t.Scope, IssuedAt: uint(t.IssuedAt.Unix())}
+	if !t.ExpiresAt.IsZero() {
+		details.ExpiresAt = t.ExpiresAt.Unix()
+	}
+
+	return details, nil
+}
+
+// Revoke forgets token. Revoking an unknown token is not an error.
+func (ts *TokenStore) Revoke(token string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	hash := auth.HashToken(token)
+	return ts.change(func() bool {
+		if _, ok := ts.tokens[hash]; !ok {
+			return false
+		}
+		delete(ts.tokens, hash)
+		return true
+	})
+}
+
+// RevokeId forgets the token with the given id, reporting whether there was one.
+func (ts *TokenStore) RevokeId(id string) (bool, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	found := false
+	err := ts.change(func() bool {
+		for hash, t := range ts.tokens {
+			if t.Id == id {
+				delete(ts.tokens, hash)
+				found = true
+				return true
+			}
+		}
+		return false
+	})
+
+	return found, err
+}
+
+// List returns the live tokens, oldest first.
+func (ts *TokenStore) List() []Token {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if err := ts.reload(); err != nil {
+		log.Printf("error: %v", err)
+	}
+
+	now := ts.now()
+	out := make([]Token, 0, len(ts.tokens))
+	for _, t := range ts.tokens {
+		if !t.expired(now) {
+			out = append(out, t)
+		}
+	}
+	slices.SortFunc(out, func(a, b Token) int { return a.IssuedAt.Compare(b.IssuedAt) })
+
+	return out
+}
+
+// change applies mutate to the tokens as they are in the database file and saves them if mutate
+// reports a change. The file is locked throughout, so a change made by another process, such as a
+// token revoked with the CLI, can't be overwritten with an older copy. The caller must hold ts.mu.
+func (ts *TokenStore) change(mutate func() bool) error {
+	if ts.path == "" {
+		if !mutate() {
+			return nil
+		}
+		return ts.save()
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ts.path), 0755); err != nil {
+		return fmt.Errorf("failed to create token database directory: %w", err)
+	}
+	unlock, err := lockFile(ts.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("failed to lock token database: %w", err)
+	}
+	defer unlock()
+
+	// Read the file even if its timestamp looks unchanged, as it may be too coarse to tell.
+	ts.modTime = time.Time{}
+	if err := ts.reload(); err != nil {
+		return err
+	}
+
+	if !mutate() {
+		return nil
+	}
+	return ts.save()
+}
+
+// save writes the live tokens to the database file, if any, dropping expired ones. The caller must
+// hold ts.mu and, with a file, its lock.
+func (ts *TokenStore) save() error {
+	now := ts.now()
+	for hash, t := range ts.tokens {
+		if t.expired(now) {
+			delete(ts.tokens, hash)
+		}
+	}
+
+	if ts.path == "" {
+		return nil
+	}
+
+	tokens := make([]Token, 0, len(ts.tokens))
+	for _, t := range ts.tokens {
+		tokens = append(tokens, t)
+	}
+	slices.SortFunc(tokens, func(a, b Token) int { return a.IssuedAt.Compare(b.IssuedAt) })
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(ts.path), 0755); err != nil {
+		return fmt.Errorf("failed to create token database directory: %w", err)
+	}
+
+	// Only hashes are stored, but the file still says who can post; keep it private.
+	tmp := ts.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token database: %w", err)
+	}
+
+	if err := os.Rename(tmp, ts.path); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(ts.path); err == nil {
+		ts.modTime = info.ModTime()
+	}
+	return nil
+}
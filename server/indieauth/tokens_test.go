@@ -0,0 +1,163 @@
+package indieauth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/server/auth"
+)
+
+func TestTokenStore_PersistsHashesOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	ts, err := NewTokenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, issued, err := ts.Issue("https://example.org/", "https://app.example/", "create", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Fatalf("expected the token database not to contain the token")
+	}
+
+	reopened, err := NewTokenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	details, err := reopened.Verify(context.Background(), token)
+	if err != nil {
+		t.Fatalf("expected the token to survive a restart, got %v", err)
+	}
+	if details.ClientId != "https://app.example/" || !details.HasScope(auth.ScopeCreate) {
+		t.Fatalf("unexpected details %+v", details)
+	}
+
+	if ok, err := reopened.RevokeId(issued.Id); !ok || err != nil {
+		t.Fatalf("expected the token to be revoked by id, got %v, %v", ok, err)
+	}
+	if _, err := reopened.Verify(context.Background(), token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("expected a revoked token to be invalid, got %v", err)
+	}
+}
+
+func TestTokenStore_Expiry(t *testing.T) {
+	ts, _ := NewTokenStore("")
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+
+	token, _, err := ts.Issue("https://example.org/", "https://app.example/", "create", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := ts.Verify(context.Background(), token)
+	if err != nil || details.ExpiresAt != now.Add(time.Hour).Unix() {
+		t.Fatalf("expected a token expiring in an hour, got %+v, %v", details, err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := ts.Verify(context.Background(), token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("expected an expired token to be invalid, got %v", err)
+	}
+	if len(ts.List()) != 0 {
+		t.Fatalf("expected expired tokens not to be listed")
+	}
+}
+
+func TestTokenStore_RecordsLastUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	ts, _ := NewTokenStore(path)
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+
+	token, _, err := ts.Issue("https://example.org/", "deploy script", "create", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := ts.Lookup(token); !ok {
+		t.Fatalf("expected the token to be found")
+	}
+	used := now
+	now = now.Add(lastUsedResolution / 2)
+	ts.Lookup(token)
+
+	reopened, _ := NewTokenStore(path)
+	if list := reopened.List(); len(list) != 1 || !list[0].LastUsedAt.Equal(used) {
+		t.Fatalf("expected the first use to be recorded, got %+v", list)
+	}
+}
+
+func TestTokenStore_PicksUpChangesFromOtherProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	server, _ := NewTokenStore(path)
+	cli, _ := NewTokenStore(path)
+
+	token, issued, err := cli.Issue("https://example.org/", "deploy script", "create", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Verify(context.Background(), token); err != nil {
+		t.Fatalf("expected a token created elsewhere to be accepted, got %v", err)
+	}
+
+	if ok, err := cli.RevokeId(issued.Id); !ok || err != nil {
+		t.Fatalf("expected the token to be revoked, got %v, %v", ok, err)
+	}
+	// Make sure the revocation shows up as a change even on coarse file timestamps.
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := server.Verify(context.Background(), token); !errors.Is(err, auth.ErrInvalidToken) {
+		t.Fatalf("expected a token revoked elsewhere to be invalid, got %v", err)
+	}
+}
+
+func TestTokenStore_KeepsRevocationsFromOtherProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	server, _ := NewTokenStore(path)
+	cli, _ := NewTokenStore(path)
+
+	token, issued, err := server.Issue("https://example.org/", "deploy script", "create", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := server.Lookup(token); !ok {
+		t.Fatalf("expected the token to be found")
+	}
+
+	// The CLI revokes the token while the server is in the middle of recording its next use.
+	now := time.Now().Add(lastUsedResolution)
+	revoked := false
+	server.now = func() time.Time {
+		if !revoked {
+			revoked = true
+			if ok, err := cli.RevokeId(issued.Id); !ok || err != nil {
+				t.Errorf("expected the token to be revoked, got %v, %v", ok, err)
+			}
+		}
+		return now
+	}
+
+	if _, ok := server.Lookup(token); ok {
+		t.Fatalf("expected the token revoked meanwhile not to be found")
+	}
+
+	reopened, _ := NewTokenStore(path)
+	if list := reopened.List(); len(list) != 0 {
+		t.Fatalf("expected the revoked token not to be written back, got %+v", list)
+	}
+}
@@ -0,0 +1,457 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/indieinfra/scribble/config"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultMaxAttempts = 8
+	baseDelay          = 30 * time.Second
+	maxDelay           = 6 * time.Hour
+
+	// jobTimeout bounds a single attempt. Attempts are not cancelled when the runner drains, so this
+	// also bounds how long shutdown can be held up by one job.
+	jobTimeout = 2 * time.Minute
+
+	// rescanInterval is how often the queue directory is re-read to pick up jobs retried from the CLI.
+	rescanInterval = time.Minute
+)
+
+// ErrUnknownJob is returned by Retry when no dead job has the given id.
+var ErrUnknownJob = errors.New("no such job")
+
+// Job is a unit of work that runs after the request which created it has been answered.
+type Job struct {
+	Id        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	CreatedAt time.Time       `json:"created_at"`
+	NotBefore time.Time       `json:"not_before"`
+	LastError string          `json:"last_error,omitempty"`
+
+	running bool
+}
+
+// Decode unmarshals the job payload into v.
+func (j *Job) Decode(v any) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler performs a job. Returning an error schedules a retry, unless the error is Permanent.
+type Handler func(ctx context.Context, job *Job) error
+
+type permanentError struct{ err error }
+
+func (pe permanentError) Error() string { return pe.err.Error() }
+func (pe permanentError) Unwrap() error { return pe.err }
+
+// Permanent marks an error as not worth retrying; the job goes straight to the dead-letter list.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	return errors.As(err, &permanent)
+}
+
+// Runner executes queued jobs in the background with a cap on concurrency, retrying failures with
+// exponential backoff and moving jobs that keep failing to a dead-letter list.
+type Runner struct {
+	store       store
+	concurrency int
+	maxAttempts int
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	pending  map[string]*Job
+	dead     map[string]*Job
+	started  bool
+	draining bool
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+	now  func() time.Time
+}
+
+func NewRunner(cfg *config.Jobs) (*Runner, error) {
+	r := &Runner{
+		store:       store{path: cfg.Path},
+		concurrency: cfg.Concurrency,
+		maxAttempts: cfg.MaxAttempts,
+		handlers:    map[string]Handler{},
+		pending:     map[string]*Job{},
+		dead:        map[string]*Job{},
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		now:         time.Now,
+	}
+
+	if r.concurrency <= 0 {
+		r.concurrency = defaultConcurrency
+	}
+	if r.maxAttempts <= 0 {
+		r.maxAttempts = defaultMaxAttempts
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Register sets the handler for jobs of the given kind. Handlers must be registered before Start.
+func (r *Runner) Register(kind string, handler Handler) {
+	r.mu.Lock()
+	r.handlers[kind] = handler
+	r.mu.Unlock()
+}
+
+// Enqueue adds a job that runs as soon as a worker is free.
+func (r *Runner) Enqueue(kind string, payload any) (*Job, error) {
+	return r.Schedule(kind, payload, time.Time{})
+}
+
+// Schedule adds a job that runs no earlier than at.
+func (r *Runner) Schedule(kind string, payload any, at time.Time) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	now := r.now()
+	if at.IsZero() {
+		at = now
+	}
+
+	job := &Job{Id: uuid.NewString(), Kind: kind, Payload: data, CreatedAt: now, NotBefore: at}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.store.write(queueDir, job); err != nil {
+		return nil, err
+	}
+	r.pending[job.Id] = job
+	r.notify()
+
+	return job, nil
+}
+
+// Pending returns copies of the queued jobs, next due first.
+func (r *Runner) Pending() []Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return sortedCopies(r.pending)
+}
+
+// Dead returns copies of the jobs that exhausted their attempts, oldest first.
+func (r *Runner) Dead() []Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return sortedCopies(r.dead)
+}
+
+// Retry moves a dead job back to the queue with a fresh set of attempts.
+func (r *Runner) Retry(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job, ok := r.dead[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, id)
+	}
+
+	job.Attempts = 0
+	job.NotBefore = r.now()
+	if err := r.store.move(deadDir, queueDir, job); err != nil {
+		return err
+	}
+
+	delete(r.dead, id)
+	r.pending[id] = job
+	r.notify()
+
+	return nil
+}
+
+// Start begins executing jobs in the background.
+func (r *Runner) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started || r.draining {
+		return
+	}
+	r.started = true
+
+	go r.loop()
+}
+
+// Drain stops the runner from starting new jobs and waits for running ones to finish. Jobs still
+// queued stay on disk and run after the next start.
+func (r *Runner) Drain(ctx context.Context) error {
+	r.mu.Lock()
+	if r.draining {
+		r.mu.Unlock()
+		return nil
+	}
+	r.draining = true
+	started := r.started
+	r.mu.Unlock()
+
+	close(r.stop)
+
+	finished := make(chan struct{})
+	go func() {
+		// The loop must have stopped claiming jobs before waiting on the ones it started.
+		if started {
+			<-r.done
+		}
+		r.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Runner) loop() {
+	defer close(r.done)
+
+	slots := make(chan struct{}, r.concurrency)
+	lastScan := r.now()
+
+	for {
+		if r.store.persistent() && r.now().Sub(lastScan) >= rescanInterval {
+			if err := r.reload(); err != nil {
+				log.Printf("error: failed to rescan job queue: %v", err)
+			}
+			lastScan = r.now()
+		}
+
+		job, wait := r.next()
+		if job == nil {
+			timer := time.NewTimer(min(wait, rescanInterval))
+			select {
+			case <-r.stop:
+				timer.Stop()
+				return
+			case <-r.wake:
+			case <-timer.C:
+			}
+			timer.Stop()
+			continue
+		}
+
+		select {
+		case <-r.stop:
+			r.release(job)
+			return
+		case slots <- struct{}{}:
+		}
+
+		r.wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				r.wg.Done()
+				r.notify()
+			}()
+			r.run(job)
+		}()
+	}
+}
+
+// next claims the job that is due soonest. When nothing is due it returns how long to wait.
+func (r *Runner) next() (*Job, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	wait := rescanInterval
+
+	var due *Job
+	for _, job := range r.pending {
+		if job.running {
+			continue
+		}
+		if job.NotBefore.After(now) {
+			wait = min(wait, job.NotBefore.Sub(now))
+			continue
+		}
+		if due == nil || less(job, due) {
+			due = job
+		}
+	}
+
+	if due != nil {
+		due.running = true
+	}
+
+	return due, wait
+}
+
+func (r *Runner) release(job *Job) {
+	r.mu.Lock()
+	job.running = false
+	r.mu.Unlock()
+}
+
+func (r *Runner) run(job *Job) {
+	r.mu.Lock()
+	handler, ok := r.handlers[job.Kind]
+	r.mu.Unlock()
+
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+		err = safeRun(ctx, handler, job)
+		cancel()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job.running = false
+	if err == nil {
+		delete(r.pending, job.Id)
+		if err := r.store.remove(queueDir, job.Id); err != nil {
+			log.Printf("error: failed to remove finished job %s: %v", job.Id, err)
+		}
+		return
+	}
+
+	job.Attempts++
+	job.LastError = err.Error()
+
+	if IsPermanent(err) || job.Attempts >= r.maxAttempts {
+		log.Printf("error: %s job %s failed after %d attempt(s), giving up: %v", job.Kind, job.Id, job.Attempts, err)
+		delete(r.pending, job.Id)
+		r.dead[job.Id] = job
+		if err := r.store.move(queueDir, deadDir, job); err != nil {
+			log.Printf("error: failed to dead-letter job %s: %v", job.Id, err)
+		}
+		return
+	}
+
+	delay := backoff(job.Attempts)
+	job.NotBefore = r.now().Add(delay)
+	log.Printf("%s job %s failed (attempt %d), retrying in %v: %v", job.Kind, job.Id, job.Attempts, delay, err)
+	if err := r.store.write(queueDir, job); err != nil {
+		log.Printf("error: failed to reschedule job %s: %v", job.Id, err)
+	}
+}
+
+// reload merges jobs written to disk by another process, such as a CLI retry. The files are read
+// with r.mu held: workers remove a finished job's file under it too, so a job that finishes while
+// the directories are read can't be merged back in and run again.
+func (r *Runner) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	queued, err := r.store.load(queueDir)
+	if err != nil {
+		return err
+	}
+	dead, err := r.store.load(deadDir)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range queued {
+		if _, ok := r.pending[job.Id]; !ok {
+			r.pending[job.Id] = job
+		}
+	}
+
+	r.dead = make(map[string]*Job, len(dead))
+	for _, job := range dead {
+		if _, ok := r.pending[job.Id]; !ok {
+			r.dead[job.Id] = job
+		}
+	}
+
+	return nil
+}
+
+func safeRun(ctx context.Context, handler Handler, job *Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+// backoff doubles the delay with every attempt, starting at baseDelay and capped at maxDelay.
+func backoff(attempts int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
+
+func less(a, b *Job) bool {
+	if !a.NotBefore.Equal(b.NotBefore) {
+		return a.NotBefore.Before(b.NotBefore)
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
+
+func sortedCopies(jobs map[string]*Job) []Job {
+	ptrs := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		ptrs = append(ptrs, job)
+	}
+	slices.SortFunc(ptrs, func(a, b *Job) int {
+		switch {
+		case less(a, b):
+			return -1
+		case less(b, a):
+			return 1
+		}
+		return 0
+	})
+
+	out := make([]Job, 0, len(ptrs))
+	for _, job := range ptrs {
+		out = append(out, *job)
+	}
+	return out
+}
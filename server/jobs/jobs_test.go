@@ -0,0 +1,200 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+)
+
+func newTestRunner(t *testing.T, cfg config.Jobs) *Runner {
+	t.Helper()
+	r, err := NewRunner(&cfg)
+	if err != nil {
+		t.Fatalf("failed to create runner: %v", err)
+	}
+	return r
+}
+
+func TestRunner_RunsJobs(t *testing.T) {
+	r := newTestRunner(t, config.Jobs{})
+
+	var got atomic.Value
+	r.Register("echo", func(_ context.Context, job *Job) error {
+		var payload string
+		if err := job.Decode(&payload); err != nil {
+			return err
+		}
+		got.Store(payload)
+		return nil
+	})
+	r.Start()
+
+	if _, err := r.Enqueue("echo", "hello"); err != nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(r.Pending()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := r.Drain(context.Background()); err != nil {
+		t.Fatalf("drain failed: %v", err)
+	}
+	if got.Load() != "hello" {
+		t.Fatalf("expected job to run, got %v", got.Load())
+	}
+}
+
+func TestRunner_BackoffAndDeadLetter(t *testing.T) {
+	r := newTestRunner(t, config.Jobs{MaxAttempts: 3})
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	r.Register("flaky", func(context.Context, *Job) error { return errors.New("nope") })
+
+	job, _ := r.Enqueue("flaky", nil)
+
+	for attempt, wantDelay := range []time.Duration{baseDelay, 2 * baseDelay} {
+		claimed, _ := r.next()
+		if claimed == nil {
+			t.Fatalf("attempt %d: expected a due job", attempt+1)
+		}
+		r.run(claimed)
+
+		if j, wait := r.next(); j != nil || wait != wantDelay {
+			t.Fatalf("attempt %d: expected wait of %v, got job %v wait %v", attempt+1, wantDelay, j, wait)
+		}
+		now = now.Add(wantDelay)
+	}
+
+	claimed, _ := r.next()
+	r.run(claimed)
+
+	if len(r.Pending()) != 0 {
+		t.Fatalf("expected job to leave the queue")
+	}
+	dead := r.Dead()
+	if len(dead) != 1 || dead[0].Id != job.Id || dead[0].Attempts != 3 || dead[0].LastError != "nope" {
+		t.Fatalf("unexpected dead jobs %+v", dead)
+	}
+
+	if err := r.Retry(job.Id); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if p := r.Pending(); len(p) != 1 || p[0].Attempts != 0 || len(r.Dead()) != 0 {
+		t.Fatalf("expected job back in the queue, got %+v", p)
+	}
+	if err := r.Retry("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Fatalf("expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestRunner_PermanentAndUnknownKinds(t *testing.T) {
+	r := newTestRunner(t, config.Jobs{})
+	r.Register("broken", func(context.Context, *Job) error { return Permanent(errors.New("bad payload")) })
+
+	_, _ = r.Enqueue("broken", nil)
+	_, _ = r.Enqueue("unregistered", nil)
+
+	for range 2 {
+		job, _ := r.next()
+		r.run(job)
+	}
+
+	if dead := r.Dead(); len(dead) != 2 || dead[0].Attempts != 1 || dead[1].Attempts != 1 {
+		t.Fatalf("expected both jobs dead after one attempt, got %+v", dead)
+	}
+}
+
+func TestRunner_Persistence(t *testing.T) {
+	dir := t.TempDir()
+
+	r := newTestRunner(t, config.Jobs{Path: dir, MaxAttempts: 1})
+	r.Register("fail", func(context.Context, *Job) error { return errors.New("down") })
+	queued, _ := r.Schedule("later", map[string]string{"k": "v"}, time.Now().Add(time.Hour))
+	failed, _ := r.Enqueue("fail", nil)
+	job, _ := r.next()
+	r.run(job)
+
+	restarted := newTestRunner(t, config.Jobs{Path: dir})
+	if p := restarted.Pending(); len(p) != 1 || p[0].Id != queued.Id || string(p[0].Payload) != `{"k":"v"}` {
+		t.Fatalf("expected queued job to survive restart, got %+v", p)
+	}
+	if d := restarted.Dead(); len(d) != 1 || d[0].Id != failed.Id {
+		t.Fatalf("expected dead job to survive restart, got %+v", d)
+	}
+
+	// A retry made by another process shows up on the next reload.
+	if err := restarted.Retry(failed.Id); err != nil {
+		t.Fatalf("retry failed: %v", err)
+	}
+	if err := r.reload(); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	if len(r.Dead()) != 0 || len(r.Pending()) != 2 {
+		t.Fatalf("expected reload to pick up the retried job, got %d pending, %d dead", len(r.Pending()), len(r.Dead()))
+	}
+}
+
+func TestRunner_ReloadDoesNotRequeueFinishedJobs(t *testing.T) {
+	r := newTestRunner(t, config.Jobs{Path: t.TempDir()})
+
+	runs := map[string]*atomic.Int32{}
+	r.Register("once", func(_ context.Context, job *Job) error {
+		runs[job.Id].Add(1)
+		return nil
+	})
+
+	const count = 200
+	for range count {
+		job, err := r.Enqueue("once", nil)
+		if err != nil {
+			t.Fatalf("enqueue failed: %v", err)
+		}
+		runs[job.Id] = &atomic.Int32{}
+	}
+
+	stop := make(chan struct{})
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				if err := r.reload(); err != nil {
+					t.Errorf("reload failed: %v", err)
+					return
+				}
+			}
+		}
+	}()
+
+	r.Start()
+	deadline := time.Now().Add(10 * time.Second)
+	for len(r.Pending()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(stop)
+	<-reloaded
+	if err := r.Drain(context.Background()); err != nil {
+		t.Fatalf("drain failed: %v", err)
+	}
+
+	for id, n := range runs {
+		if n.Load() != 1 {
+			t.Fatalf("job %s ran %d times", id, n.Load())
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if backoff(1) != baseDelay || backoff(3) != 4*baseDelay || backoff(100) != maxDelay {
+		t.Fatalf("unexpected backoff: %v %v %v", backoff(1), backoff(3), backoff(100))
+	}
+}
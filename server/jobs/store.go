@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	queueDir = "queue"
+	deadDir  = "dead"
+)
+
+// store keeps one JSON file per job: pending jobs in <path>/queue and failed ones in <path>/dead.
+// An empty path keeps jobs in memory only.
+type store struct {
+	path string
+}
+
+func (s store) persistent() bool {
+	return s.path != ""
+}
+
+func (s store) write(dir string, job *Job) error {
+	if !s.persistent() {
+		return nil
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(s.path, dir)
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return fmt.Errorf("failed to create job directory: %w", err)
+	}
+
+	file := filepath.Join(target, job.Id+".json")
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write job %s: %w", job.Id, err)
+	}
+
+	return os.Rename(tmp, file)
+}
+
+func (s store) remove(dir string, id string) error {
+	if !s.persistent() {
+		return nil
+	}
+
+	err := os.Remove(filepath.Join(s.path, dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// move writes the job to dir and removes it from from.
+func (s store) move(from string, to string, job *Job) error {
+	if err := s.write(to, job); err != nil {
+		return err
+	}
+
+	return s.remove(from, job.Id)
+}
+
+func (s store) load(dir string) ([]*Job, error) {
+	if !s.persistent() {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.path, dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*Job
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(s.path, dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil {
+			return nil, fmt.Errorf("failed to decode job file %q: %w", e.Name(), err)
+		}
+		out = append(out, &job)
+	}
+
+	return out, nil
+}
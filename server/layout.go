@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"path"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/author"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// pathResolver places documents beneath the content path according to the configuration: drafts,
+// scheduled and private posts go to their own path if one is set, and posts in a channel with a path
+// go to that channel's directory. Posts by an author with a path of their own go beneath it; the
+// author is the one writing the post, not whoever the document claims.
+func pathResolver(cfg *config.Config) content.PathResolver {
+	channelPaths := map[string]string{}
+	for _, ch := range cfg.Micropub.Channels {
+		channelPaths[ch.Uid] = ch.Path
+	}
+
+	resolve := func(doc util.Mf2Document) string {
+		if cfg.Micropub.DraftsPath != "" && content.IsDraft(doc) {
+			return cfg.Micropub.DraftsPath
+		}
+
+		if cfg.Micropub.ScheduledPath != "" && content.IsScheduled(doc) {
+			return cfg.Micropub.ScheduledPath
+		}
+
+		if cfg.Micropub.PrivatePath != "" && content.Visibility(doc) == content.VisibilityPrivate {
+			return cfg.Micropub.PrivatePath
+		}
+
+		for _, v := range doc.Properties["channel"] {
+			if uid, ok := v.(string); ok {
+				return channelPaths[uid]
+			}
+		}
+
+		return ""
+	}
+
+	return func(ctx context.Context, doc util.Mf2Document) string {
+		if a := author.Owner(ctx, &cfg.Micropub, doc); a != nil && a.Path != "" {
+			return path.Join(a.Path, resolve(doc))
+		}
+
+		return resolve(doc)
+	}
+}
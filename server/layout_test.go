@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/author"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func TestPathResolver(t *testing.T) {
+	resolve := pathResolver(&config.Config{Micropub: config.Micropub{Channels: []config.Channel{
+		{Uid: "notes", Name: "Notes", Path: "notes"},
+		{Uid: "misc", Name: "Misc"},
+	}, DraftsPath: "drafts", ScheduledPath: "scheduled", PrivatePath: "private"}})
+
+	cases := []struct {
+		doc  util.Mf2Document
+		want string
+	}{
+		{util.Mf2Document{Properties: map[string][]any{"channel": {"notes"}}}, "notes"},
+		{util.Mf2Document{Properties: map[string][]any{"channel": {"misc"}}}, ""},
+		{util.Mf2Document{Properties: map[string][]any{}}, ""},
+		{util.Mf2Document{Properties: map[string][]any{"channel": {"notes"}, "post-status": {"draft"}}}, "drafts"},
+		{util.Mf2Document{Properties: map[string][]any{"channel": {"notes"}, "post-status": {"published"}}}, "notes"},
+		{util.Mf2Document{Properties: map[string][]any{"post-status": {"scheduled"}}}, "scheduled"},
+		{util.Mf2Document{Properties: map[string][]any{"channel": {"notes"}, "visibility": {"Private"}}}, "private"},
+		{util.Mf2Document{Properties: map[string][]any{"channel": {"notes"}, "visibility": {"unlisted"}}}, "notes"},
+	}
+
+	for i, tc := range cases {
+		if got := resolve(context.Background(), tc.doc); got != tc.want {
+			t.Fatalf("case %d: expected %q, got %q", i, tc.want, got)
+		}
+	}
+}
+
+func TestPathResolver_AuthorPaths(t *testing.T) {
+	resolve := pathResolver(&config.Config{Micropub: config.Micropub{
+		DraftsPath: "drafts",
+		Authors:    []config.Author{{Me: "https://alice.example/", Path: "alice"}, {Me: "https://bob.example/"}},
+	}})
+
+	alice := map[string]any{"type": []any{"h-card"}, "properties": map[string]any{"url": []any{"https://alice.example"}}}
+	cases := []struct {
+		owner string
+		doc   util.Mf2Document
+		want  string
+	}{
+		{"https://alice.example", util.Mf2Document{Properties: map[string][]any{}}, "alice"},
+		{"https://alice.example/", util.Mf2Document{Properties: map[string][]any{"post-status": {"draft"}}}, "alice/drafts"},
+		{"https://bob.example/", util.Mf2Document{Properties: map[string][]any{}}, ""},
+		// The writer decides, not a claim in the document
+		{"https://bob.example/", util.Mf2Document{Properties: map[string][]any{"author": {alice}}}, ""},
+		{"https://stranger.example/", util.Mf2Document{Properties: map[string][]any{"author": {alice}}}, ""},
+		// Without a writer, as when publishing in the background, the stored attribution is used
+		{"", util.Mf2Document{Properties: map[string][]any{"author": {alice}}}, "alice"},
+	}
+
+	for i, tc := range cases {
+		ctx := context.Background()
+		if tc.owner != "" {
+			ctx = author.WithOwner(ctx, tc.owner)
+		}
+		if got := resolve(ctx, tc.doc); got != tc.want {
+			t.Fatalf("case %d: expected %q, got %q", i, tc.want, got)
+		}
+	}
+}
@@ -1,11 +1,11 @@
 package middleware
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
-	"github.com/indieinfra/scribble/config"
 	"github.com/indieinfra/scribble/server/auth"
 	"github.com/indieinfra/scribble/server/resp"
 	"github.com/indieinfra/scribble/server/util"
@@ -24,43 +24,45 @@ func extractBearerHeader(auth string) string {
 	return token
 }
 
+// BearerToken returns the token in the request's Authorization header, if any, without checking it.
+func BearerToken(r *http.Request) string {
+	return strings.TrimSpace(extractBearerHeader(r.Header.Get("Authorization")))
+}
+
 // function ValidateTokenMiddleware wraps a downstream handler. At execution time,
 // it extracts a Bearer token from the Authorization header, if any. If the Authorization
 // header is not present, or does not contain a Bearer token, it aborts the request.
-// If the token is present, it performs the VerifyAccessToken routine which makes a downstream
-// http request to the defined token endpoint to validate the token.
-func ValidateTokenMiddleware(cfg *config.Config, next http.Handler) http.Handler {
+// If the token is present, it is checked with the verifier: personal access tokens
+// first, if enabled, then usually the token endpoint.
+func ValidateTokenMiddleware(verifier auth.Verifier, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var token string
 		token = extractBearerHeader(r.Header.Get("Authorization"))
-
 		token = strings.TrimSpace(token)
 		if token == "" {
 			if r.Method == http.MethodGet {
 				resp.WriteUnauthorized(w, "An access token is required")
 				return
 			}
+
 			// For non-GET requests, allow handlers to pull tokens from the body.
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		details := auth.VerifyAccessToken(cfg, token)
-		if details == nil {
-			resp.WriteForbidden(w, "Token validation failed")
+		r, ok := verifyRequestToken(verifier, w, r, token)
+		if !ok {
 			return
 		}
 
-		rl := util.WithRequest(log.Default(), r, details.Me)
-		ctx := util.ContextWithLogger(r.Context(), rl)
-		next.ServeHTTP(w, r.WithContext(auth.AddToken(ctx, details)))
+		next.ServeHTTP(w, r)
 	})
 }
 
 // EnsureTokenForRequest attaches validated token details to the request context using the provided
 // token string when middleware has not already set them. It prefers existing context tokens and
 // returns an updated request pointer.
-func EnsureTokenForRequest(cfg *config.Config, w http.ResponseWriter, r *http.Request, token string) (*http.Request, bool) {
+func EnsureTokenForRequest(verifier auth.Verifier, w http.ResponseWriter, r *http.Request, token string) (*http.Request, bool) {
 	if auth.GetToken(r.Context()) != nil {
 		return r, true
 	}
@@ -71,13 +73,30 @@ func EnsureTokenForRequest(cfg *config.Config, w http.ResponseWriter, r *http.Re
 		return nil, false
 	}
 
-	details := auth.VerifyAccessToken(cfg, token)
-	if details == nil {
-		resp.WriteForbidden(w, "Token validation failed")
+	return verifyRequestToken(verifier, w, r, token)
+}
+
+// verifyRequestToken checks token and attaches its details to the request. Invalid tokens are
+// refused with 403; if the token can't be checked right now, the client is asked to retry later.
+func verifyRequestToken(verifier auth.Verifier, w http.ResponseWriter, r *http.Request, token string) (*http.Request, bool) {
+	details, err := verifier.Verify(r.Context(), token)
+	if err != nil {
+		var unavailable *auth.UnavailableError
+		switch {
+		case errors.Is(err, auth.ErrInvalidToken):
+			resp.WriteForbidden(w, "Token validation failed")
+		case errors.As(err, &unavailable):
+			log.Printf("error: %v", err)
+			resp.WriteServiceUnavailable(w, unavailable.RetryAfter, "Token could not be verified right now; try again later")
+		default:
+			log.Printf("error: token verification failed: %v", err)
+			resp.WriteInternalServerError(w, "Token could not be verified")
+		}
 		return nil, false
 	}
 
 	rl := util.WithRequest(log.Default(), r, details.Me)
 	ctx := util.ContextWithLogger(r.Context(), rl)
+	ctx = auth.AddTokenHash(ctx, token)
 	return r.WithContext(auth.AddToken(ctx, details)), true
 }
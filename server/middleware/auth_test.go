@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/server/auth"
+)
+
+type verifierFunc func(ctx context.Context, token string) (*auth.TokenDetails, error)
+
+func (fn verifierFunc) Verify(ctx context.Context, token string) (*auth.TokenDetails, error) {
+	return fn(ctx, token)
+}
+
+func TestValidateTokenMiddleware(t *testing.T) {
+	verifier := verifierFunc(func(_ context.Context, token string) (*auth.TokenDetails, error) {
+		switch token {
+		case "good":
+			return &auth.TokenDetails{Me: "https://example.org/", Scope: "read"}, nil
+		case "down":
+			return nil, &auth.UnavailableError{RetryAfter: 30 * time.Second, Err: fmt.Errorf("connection refused")}
+		default:
+			return nil, fmt.Errorf("%w: revoked", auth.ErrInvalidToken)
+		}
+	})
+
+	handler := ValidateTokenMiddleware(verifier, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth.GetToken(r.Context()) == nil {
+			t.Fatalf("expected token details in the request context")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	cases := []struct {
+		token      string
+		code       int
+		retryAfter string
+	}{
+		{"good", http.StatusNoContent, ""},
+		{"revoked", http.StatusForbidden, ""},
+		{"down", http.StatusServiceUnavailable, "30"},
+		{"", http.StatusUnauthorized, ""},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/?q=config", nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tc.code {
+			t.Fatalf("token %q: expected %d, got %d", tc.token, tc.code, rr.Code)
+		}
+		if got := rr.Header().Get("Retry-After"); got != tc.retryAfter {
+			t.Fatalf("token %q: expected Retry-After %q, got %q", tc.token, tc.retryAfter, got)
+		}
+	}
+}
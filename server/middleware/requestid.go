@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// RequestIdHeader carries the request ID, both from a proxy that assigned one and back to the client.
+const RequestIdHeader = "X-Request-Id"
+
+type requestIdKeyType struct{}
+
+var requestIdKey = requestIdKeyType{}
+
+// validRequestId keeps IDs from proxies short and printable, as they end up in logs.
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestId gives every request an ID, taken from the X-Request-Id header when a proxy set a sane
+// one, so what it did can be traced through the logs. The ID is echoed in the response.
+func RequestId(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIdHeader)
+		if !validRequestId.MatchString(id) {
+			id = newRequestId()
+		}
+
+		w.Header().Set(RequestIdHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdKey, id)))
+	})
+}
+
+// GetRequestId returns the ID RequestId gave the request, or an empty string.
+func GetRequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey).(string)
+	return id
+}
+
+func newRequestId() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
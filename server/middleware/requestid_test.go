@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestId(t *testing.T) {
+	var seen string
+	handler := RequestId(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = GetRequestId(r.Context())
+	}))
+
+	for header, keep := range map[string]bool{"abc-123": true, "": false, "has spaces\n": false} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set(RequestIdHeader, header)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if seen == "" || rr.Header().Get(RequestIdHeader) != seen {
+			t.Fatalf("%q: expected the request ID %q to be echoed, got %q", header, seen, rr.Header().Get(RequestIdHeader))
+		}
+		if (seen == header) != keep {
+			t.Fatalf("%q: expected keep=%v, got ID %q", header, keep, seen)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/resp"
+)
+
+// Denial is the error returned when a policy refuses a request.
+type Denial struct {
+	// Forbidden is set when the client is refused outright, rather than for what it asked to do.
+	Forbidden   bool
+	Description string
+}
+
+func (d *Denial) Error() string {
+	return d.Description
+}
+
+// Request describes what a client is asking to do.
+type Request struct {
+	// Write is set for anything that changes content or uploads media.
+	Write bool
+	// PostType is the type of the post being created, if any.
+	PostType string
+	// Properties are the properties being set, by name.
+	Properties []string
+}
+
+// For returns the first policy that applies to the token, or nil if none does.
+func For(policies []config.Policy, details *auth.TokenDetails) *config.Policy {
+	if details == nil {
+		return nil
+	}
+
+	for i, p := range policies {
+		if p.ClientId != "" && normalize(p.ClientId) != normalize(details.ClientId) {
+			continue
+		}
+		if p.Me != "" && !details.HasMe(p.Me) {
+			continue
+		}
+		return &policies[i]
+	}
+
+	return nil
+}
+
+// Check decides whether the token's policy allows the request, returning a *Denial if it doesn't.
+func Check(policies []config.Policy, details *auth.TokenDetails, req Request) *Denial {
+	p := For(policies, details)
+	if p == nil {
+		return nil
+	}
+
+	client := details.ClientId
+	if client == "" {
+		client = details.Me
+	}
+
+	switch {
+	case p.Deny:
+		return &Denial{Forbidden: true, Description: fmt.Sprintf("client %q is not allowed to use this endpoint", client)}
+	case !req.Write:
+		return nil
+	case p.ReadOnly:
+		return &Denial{Description: fmt.Sprintf("client %q may only read", client)}
+	case req.PostType != "" && len(p.PostTypes) > 0 && !slices.Contains(p.PostTypes, req.PostType):
+		return &Denial{Description: fmt.Sprintf("client %q may not create %s posts", client, req.PostType)}
+	}
+
+	if len(p.Properties) > 0 {
+		for _, prop := range req.Properties {
+			if !slices.Contains(p.Properties, prop) {
+				return &Denial{Description: fmt.Sprintf("client %q may not set the %q property", client, prop)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Enforce checks the request's token against the policies, answering with forbidden or
+// insufficient_scope if they refuse it. It reports whether the request may go ahead.
+func Enforce(policies []config.Policy, w http.ResponseWriter, r *http.Request, req Request) bool {
+	denial := Check(policies, auth.GetToken(r.Context()), req)
+	switch {
+	case denial == nil:
+		return true
+	case denial.Forbidden:
+		resp.WriteForbidden(w, denial.Description)
+	default:
+		resp.WriteInsufficientScope(w, denial.Description)
+	}
+
+	return false
+}
+
+// Middleware enforces the policies on read-only requests, whose token the wrapped handler expects to
+// find in the request context already.
+func Middleware(policies []config.Policy, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if Enforce(policies, w, r, Request{}) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// MaxPayloadSize returns the request body limit for the token: its policy's override, or def.
+func MaxPayloadSize(policies []config.Policy, details *auth.TokenDetails, def uint) uint {
+	if p := For(policies, details); p != nil && p.MaxPayloadSize > 0 {
+		return p.MaxPayloadSize
+	}
+
+	return def
+}
+
+func normalize(u string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(u)), "/")
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+)
+
+var testPolicies = []config.Policy{
+	{ClientId: "https://spam.example/", Deny: true},
+	{ClientId: "https://reader.example/", ReadOnly: true},
+	{ClientId: "https://quill.example", Me: "https://example.org/", PostTypes: []string{"note", "photo"}, Properties: []string{"content", "photo"}, MaxPayloadSize: 1024},
+	{Me: "https://guest.example/", PostTypes: []string{"like"}},
+}
+
+func TestFor(t *testing.T) {
+	cases := []struct {
+		details *auth.TokenDetails
+		want    int
+	}{
+		{&auth.TokenDetails{Me: "https://example.org/", ClientId: "https://quill.example/"}, 2},
+		{&auth.TokenDetails{Me: "https://other.example/", ClientId: "https://quill.example/"}, -1},
+		{&auth.TokenDetails{Me: "https://guest.example", ClientId: "https://any.example/"}, 3},
+		{&auth.TokenDetails{Me: "https://example.org/", ClientId: "https://unknown.example/"}, -1},
+		{nil, -1},
+	}
+
+	for _, c := range cases {
+		got := For(testPolicies, c.details)
+		switch {
+		case c.want < 0 && got != nil:
+			t.Fatalf("%v: expected no policy, got %+v", c.details, got)
+		case c.want >= 0 && got != &testPolicies[c.want]:
+			t.Fatalf("%v: expected policy %d, got %+v", c.details, c.want, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	quill := &auth.TokenDetails{Me: "https://example.org/", ClientId: "https://quill.example/"}
+	reader := &auth.TokenDetails{Me: "https://example.org/", ClientId: "https://reader.example/"}
+	spam := &auth.TokenDetails{Me: "https://example.org/", ClientId: "https://spam.example/"}
+	other := &auth.TokenDetails{Me: "https://example.org/", ClientId: "https://other.example/"}
+
+	cases := []struct {
+		name      string
+		details   *auth.TokenDetails
+		req       Request
+		allowed   bool
+		forbidden bool
+	}{
+		{"denied client reads", spam, Request{}, false, true},
+		{"read-only client reads", reader, Request{}, true, false},
+		{"read-only client writes", reader, Request{Write: true}, false, false},
+		{"allowed post type", quill, Request{Write: true, PostType: "photo", Properties: []string{"content", "photo"}}, true, false},
+		{"disallowed post type", quill, Request{Write: true, PostType: "article", Properties: []string{"content"}}, false, false},
+		{"disallowed property", quill, Request{Write: true, PostType: "note", Properties: []string{"content", "category"}}, false, false},
+		{"update with allowed properties", quill, Request{Write: true, Properties: []string{"content"}}, true, false},
+		{"no policy", other, Request{Write: true, PostType: "article", Properties: []string{"name"}}, true, false},
+	}
+
+	for _, c := range cases {
+		denial := Check(testPolicies, c.details, c.req)
+		if (denial == nil) != c.allowed {
+			t.Fatalf("%s: expected allowed=%v, got %v", c.name, c.allowed, denial)
+		}
+		if denial != nil && denial.Forbidden != c.forbidden {
+			t.Fatalf("%s: expected forbidden=%v, got %+v", c.name, c.forbidden, denial)
+		}
+	}
+}
+
+func TestMaxPayloadSize(t *testing.T) {
+	quill := &auth.TokenDetails{Me: "https://example.org/", ClientId: "https://quill.example/"}
+	if got := MaxPayloadSize(testPolicies, quill, 2048); got != 1024 {
+		t.Fatalf("expected the policy's limit, got %d", got)
+	}
+	if got := MaxPayloadSize(testPolicies, &auth.TokenDetails{ClientId: "https://reader.example/"}, 2048); got != 2048 {
+		t.Fatalf("expected the default limit, got %d", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware(testPolicies, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for clientId, want := range map[string]int{
+		"https://spam.example/":   http.StatusForbidden,
+		"https://reader.example/": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/?q=config", nil)
+		req = req.WithContext(auth.AddToken(req.Context(), &auth.TokenDetails{Me: "https://example.org/", ClientId: clientId}))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("%s: expected %d, got %d", clientId, want, rr.Code)
+		}
+	}
+}
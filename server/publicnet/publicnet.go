@@ -0,0 +1,156 @@
+// Package publicnet fetches URLs that anyone on the web can hand the server, such as webmention
+// sources and IndieAuth client_ids, without letting them point it at loopback, private or other
+// non-public addresses.
+package publicnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxRedirects caps how many redirects a client follows.
+const maxRedirects = 10
+
+// ErrPrivateAddress is returned for hosts on loopback, private, link-local or otherwise non-public
+// addresses.
+var ErrPrivateAddress = errors.New("not a public address")
+
+// reservedPrefixes are ranges that are neither private nor public by netip's reckoning, but must not
+// be reached from a URL supplied from outside either.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// IsPublic reports whether addr may be fetched on behalf of someone else.
+func IsPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsLoopback reports whether host is localhost or a loopback IP literal.
+func IsLoopback(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	return err == nil && addr.Unmap().IsLoopback()
+}
+
+// CheckHost refuses hosts that name a non-public address outright: IP literals and localhost. Other
+// names are only checked once they have been resolved.
+func CheckHost(host string) error {
+	if IsLoopback(host) {
+		return fmt.Errorf("%s: %w", host, ErrPrivateAddress)
+	}
+
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil && !IsPublic(addr) {
+		return fmt.Errorf("%s: %w", host, ErrPrivateAddress)
+	}
+
+	return nil
+}
+
+// Resolver looks up the addresses of a host name.
+type Resolver func(ctx context.Context, host string) ([]netip.Addr, error)
+
+// LookupHost resolves host with the system resolver.
+func LookupHost(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// CheckUrl refuses a URL whose host is, or resolves to, a non-public address. A host that doesn't
+// resolve is let through; a client from NewClient checks the address again when it connects.
+func CheckUrl(ctx context.Context, lookup Resolver, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	host := u.Hostname()
+	if err := CheckHost(host); err != nil {
+		return err
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !IsPublic(addr) {
+			return fmt.Errorf("%s resolves to %s: %w", host, addr, ErrPrivateAddress)
+		}
+	}
+
+	return nil
+}
+
+// NewClient returns a client that only connects to public addresses, checked once the host has been
+// resolved so a name can't point it somewhere else, and applies the same check to every redirect.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !IsPublic(addr) {
+				return fmt.Errorf("%s: %w", addr, ErrPrivateAddress)
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the connection on the server's behalf, unchecked.
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return CheckHost(req.URL.Hostname())
+		},
+	}
+}
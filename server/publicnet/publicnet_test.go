@@ -0,0 +1,85 @@
+package publicnet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestIsPublic(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"::ffff:127.0.0.1": false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"224.0.0.1":        false,
+	}
+
+	for addr, want := range cases {
+		if got := IsPublic(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPublic(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestCheckUrl(t *testing.T) {
+	lookup := func(_ context.Context, host string) ([]netip.Addr, error) {
+		switch host {
+		case "private.example":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.1")}, nil
+		case "public.example":
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	cases := map[string]bool{
+		"https://public.example/":     true,
+		"https://missing.example/":    true,
+		"https://93.184.216.34/":      true,
+		"https://private.example/":    false,
+		"http://localhost:8080/":      false,
+		"http://app.localhost/":       false,
+		"http://127.0.0.1/":           false,
+		"http://[::1]/":               false,
+		"http://169.254.169.254/meta": false,
+	}
+
+	for raw, ok := range cases {
+		err := CheckUrl(context.Background(), lookup, raw)
+		if ok && err != nil {
+			t.Errorf("%s: unexpected error: %v", raw, err)
+		}
+		if !ok && !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("%s: expected ErrPrivateAddress, got %v", raw, err)
+		}
+	}
+}
+
+func TestNewClient_RefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	client := NewClient(time.Second)
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("expected the loopback server not to be reached, got %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://10.1.2.3/", nil)
+	if err := client.CheckRedirect(req, []*http.Request{httptest.NewRequest(http.MethodGet, "https://a.example/", nil)}); !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("expected a redirect to a private address to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,359 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/middleware"
+	"github.com/indieinfra/scribble/server/resp"
+)
+
+// Kind names a budget.
+type Kind string
+
+const (
+	Read       Kind = "read"
+	Write      Kind = "write"
+	Media      Kind = "media"
+	Webmention Kind = "webmention"
+	Auth       Kind = "auth"
+)
+
+// sweepInterval is how often buckets that have filled up again are forgotten.
+const sweepInterval = time.Minute
+
+// writeRetryAfter is suggested to clients turned away because too many writes are in progress.
+const writeRetryAfter = time.Second
+
+var defaultKeys = []string{"token", "client", "ip"}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// limited is set while the bucket's key is being turned away, so that is logged once.
+	limited bool
+}
+
+type budget struct {
+	// rate is in requests per second.
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	allowed uint64
+	limited uint64
+}
+
+// Limiter keeps a token bucket per key for each budget, and caps the writes in progress. A nil
+// Limiter allows everything.
+type Limiter struct {
+	keys         []string
+	forwardedFor bool
+	now          func() time.Time
+
+	mu        sync.Mutex
+	budgets   map[Kind]*budget
+	lastSweep time.Time
+
+	// writes holds a slot for each write in progress; it is nil when writes are not capped.
+	writes         chan struct{}
+	rejectedWrites uint64
+}
+
+// NewLimiter creates a limiter for the configured budgets, or returns nil if nothing is limited.
+func NewLimiter(cfg *config.RateLimit) *Limiter {
+	l := &Limiter{keys: cfg.Keys, forwardedFor: cfg.ForwardedFor, now: time.Now, budgets: map[Kind]*budget{}}
+	if len(l.keys) == 0 {
+		l.keys = defaultKeys
+	}
+
+	for kind, b := range map[Kind]config.RateBudget{Read: cfg.Read, Write: cfg.Write, Media: cfg.Media, Webmention: cfg.Webmention, Auth: cfg.Auth} {
+		if b.PerMinute <= 0 {
+			continue
+		}
+		burst := float64(b.Burst)
+		if burst <= 0 {
+			burst = math.Max(1, math.Ceil(b.PerMinute/10))
+		}
+		l.budgets[kind] = &budget{rate: b.PerMinute / 60, burst: burst, buckets: map[string]*bucket{}}
+	}
+
+	if cfg.MaxInFlightWrites > 0 {
+		l.writes = make(chan struct{}, cfg.MaxInFlightWrites)
+	}
+
+	if len(l.budgets) == 0 && l.writes == nil {
+		return nil
+	}
+
+	return l
+}
+
+// Allow takes a request from the budget of each of its keys that Middleware hasn't already taken
+// it from. If any of them is used up, nothing is taken and the time until the request would fit is
+// returned.
+func (l *Limiter) Allow(kind Kind, r *http.Request) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+
+	counted, _ := r.Context().Value(countedKey).([]string)
+	var keys []string
+	for _, key := range l.requestKeys(kind, r) {
+		if !slices.Contains(counted, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return l.allow(kind, keys)
+}
+
+func (l *Limiter) allow(kind Kind, keys []string) (time.Duration, bool) {
+	if len(keys) == 0 {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.budgets[kind]
+	if b == nil {
+		return 0, true
+	}
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	var wait time.Duration
+	for _, key := range keys {
+		bk := b.refill(key, now)
+		if bk.tokens < 1 {
+			wait = max(wait, time.Duration((1-bk.tokens)/b.rate*float64(time.Second)))
+		}
+	}
+
+	if wait > 0 {
+		b.limited++
+		for _, key := range keys {
+			if bk := b.buckets[key]; bk.tokens < 1 && !bk.limited {
+				bk.limited = true
+				log.Printf("warning: rate limiting %s requests from %s", kind, key)
+			}
+		}
+		return wait, false
+	}
+
+	b.allowed++
+	for _, key := range keys {
+		bk := b.buckets[key]
+		bk.tokens--
+		bk.limited = false
+	}
+	return 0, true
+}
+
+// refill tops up the bucket for key for the time since it was last used, creating a full one for
+// a new key. The caller must hold l.mu.
+func (b *budget) refill(key string, now time.Time) *bucket {
+	bk, ok := b.buckets[key]
+	if !ok {
+		bk = &bucket{tokens: b.burst, last: now}
+		b.buckets[key] = bk
+		return bk
+	}
+
+	bk.tokens = math.Min(b.burst, bk.tokens+now.Sub(bk.last).Seconds()*b.rate)
+	bk.last = now
+	return bk
+}
+
+// sweep forgets buckets that have filled up again, as they are no different from new ones. The
+// caller must hold l.mu.
+func (l *Limiter) sweep(now time.Time) {
+	for _, b := range l.budgets {
+		for key, bk := range b.buckets {
+			if bk.tokens+now.Sub(bk.last).Seconds()*b.rate >= b.burst {
+				delete(b.buckets, key)
+			}
+		}
+	}
+	l.lastSweep = now
+}
+
+// requestKeys names the buckets a request is counted in. The token is known by the hash of the
+// Authorization header before it is verified, and by the hash recorded with the verified token after.
+// Webmentions and IndieAuth requests come without a token, so they are only counted by address.
+func (l *Limiter) requestKeys(kind Kind, r *http.Request) []string {
+	if kind == Webmention || kind == Auth {
+		return []string{"ip " + l.remoteIp(r)}
+	}
+
+	var keys []string
+	for _, k := range l.keys {
+		switch k {
+		case "token":
+			hash := auth.GetTokenHash(r.Context())
+			if token := middleware.BearerToken(r); hash == "" && token != "" {
+				hash = auth.HashToken(token)
+			}
+			if hash != "" {
+				keys = append(keys, "token "+hash[:16])
+			}
+		case "client":
+			if token := auth.GetToken(r.Context()); token != nil && token.ClientId != "" {
+				keys = append(keys, "client "+token.ClientId)
+			}
+		case "ip":
+			keys = append(keys, "ip "+l.remoteIp(r))
+		}
+	}
+
+	return keys
+}
+
+func (l *Limiter) remoteIp(r *http.Request) string {
+	return middleware.RemoteIp(r, l.forwardedFor)
+}
+
+// Enforce takes the request from its budget, answering with 429 and a Retry-After header if it
+// doesn't fit. It reports whether the request may go ahead. Once the token has been verified, this
+// counts the keys Middleware couldn't: the client, and a token sent in the request body.
+func (l *Limiter) Enforce(kind Kind, w http.ResponseWriter, r *http.Request) bool {
+	wait, ok := l.Allow(kind, r)
+	if !ok {
+		resp.WriteTooManyRequests(w, wait, "Too many "+string(kind)+" requests; slow down")
+	}
+
+	return ok
+}
+
+type countedKeyType struct{}
+
+// countedKey holds the keys Middleware took a request from, so Enforce doesn't take it twice.
+var countedKey = countedKeyType{}
+
+// Middleware enforces the budget of kind before any work is done on the request: before its body
+// is read or its token verified. Only the keys known by then are counted, the address and the
+// Authorization header's token; Enforce or TokenMiddleware count the rest.
+func (l *Limiter) Middleware(kind Kind, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		keys := l.requestKeys(kind, r)
+		wait, ok := l.allow(kind, keys)
+		if !ok {
+			resp.WriteTooManyRequests(w, wait, "Too many "+string(kind)+" requests; slow down")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), countedKey, keys)))
+	})
+}
+
+// TokenMiddleware enforces the budget of kind for the keys only known once the token in the request
+// context has been verified.
+func (l *Limiter) TokenMiddleware(kind Kind, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.Enforce(kind, w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// AcquireWrite reserves a slot for a write, unless the cap on writes in progress is reached. The
+// returned function gives the slot back.
+func (l *Limiter) AcquireWrite() (release func(), ok bool) {
+	if l == nil || l.writes == nil {
+		return func() {}, true
+	}
+
+	select {
+	case l.writes <- struct{}{}:
+		return func() { <-l.writes }, true
+	default:
+		l.mu.Lock()
+		l.rejectedWrites++
+		l.mu.Unlock()
+		return nil, false
+	}
+}
+
+// EnforceWrite reserves a slot for a write, answering with 429 if too many are in progress. The
+// returned function gives the slot back once the write is done.
+func (l *Limiter) EnforceWrite(w http.ResponseWriter) (release func(), ok bool) {
+	release, ok = l.AcquireWrite()
+	if !ok {
+		resp.WriteTooManyRequests(w, writeRetryAfter, "Too many changes are in progress; try again shortly")
+	}
+
+	return release, ok
+}
+
+// BudgetStats describes the use of one budget.
+type BudgetStats struct {
+	PerMinute float64 `json:"per_minute"`
+	Burst     int     `json:"burst"`
+	// Tracked is the number of keys that have used some of their budget recently.
+	Tracked int    `json:"tracked"`
+	Allowed uint64 `json:"allowed"`
+	Limited uint64 `json:"limited"`
+	// Remaining is what is left of the budget for the request's own keys.
+	Remaining int `json:"remaining"`
+}
+
+// Stats is a snapshot of the limiter's state.
+type Stats struct {
+	Budgets           map[Kind]BudgetStats `json:"budgets"`
+	InFlightWrites    int                  `json:"in_flight_writes"`
+	MaxInFlightWrites int                  `json:"max_in_flight_writes"`
+	RejectedWrites    uint64               `json:"rejected_writes"`
+}
+
+// Stats describes the limiter's state, including what is left for the keys of r.
+func (l *Limiter) Stats(r *http.Request) Stats {
+	stats := Stats{Budgets: map[Kind]BudgetStats{}}
+	if l == nil {
+		return stats
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for kind, b := range l.budgets {
+		keys := l.requestKeys(kind, r)
+		remaining := b.burst
+		for _, key := range keys {
+			tokens := b.burst
+			if bk, ok := b.buckets[key]; ok {
+				tokens = math.Min(b.burst, bk.tokens+now.Sub(bk.last).Seconds()*b.rate)
+			}
+			remaining = math.Min(remaining, tokens)
+		}
+
+		stats.Budgets[kind] = BudgetStats{
+			PerMinute: b.rate * 60,
+			Burst:     int(b.burst),
+			Tracked:   len(b.buckets),
+			Allowed:   b.allowed,
+			Limited:   b.limited,
+			Remaining: int(remaining),
+		}
+	}
+
+	stats.InFlightWrites = len(l.writes)
+	stats.MaxInFlightWrites = cap(l.writes)
+	stats.RejectedWrites = l.rejectedWrites
+	return stats
+}
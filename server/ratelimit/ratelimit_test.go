@@ -0,0 +1,243 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/auth"
+)
+
+func newTestLimiter(cfg config.RateLimit) (*Limiter, *time.Time) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(&cfg)
+	if l != nil {
+		l.now = func() time.Time { return now }
+	}
+	return l, &now
+}
+
+func requestFrom(addr, token, clientId string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/?q=config", nil)
+	r.RemoteAddr = addr
+	if token != "" {
+		ctx := auth.AddTokenHash(r.Context(), token)
+		r = r.WithContext(auth.AddToken(ctx, &auth.TokenDetails{Me: "https://example.org/", ClientId: clientId}))
+	}
+	return r
+}
+
+func TestNewLimiter_NothingConfigured(t *testing.T) {
+	if l := NewLimiter(&config.RateLimit{}); l != nil {
+		t.Fatalf("expected no limiter, got %+v", l)
+	}
+
+	var l *Limiter
+	if _, ok := l.Allow(Write, requestFrom("192.0.2.1:1234", "", "")); !ok {
+		t.Fatalf("a nil limiter should allow everything")
+	}
+	release, ok := l.AcquireWrite()
+	if !ok {
+		t.Fatalf("a nil limiter should not cap writes")
+	}
+	release()
+}
+
+func TestLimiter_BurstAndRefill(t *testing.T) {
+	l, now := newTestLimiter(config.RateLimit{Write: config.RateBudget{PerMinute: 60, Burst: 2}})
+	r := requestFrom("192.0.2.1:1234", "secret", "https://quill.example/")
+
+	for i := range 2 {
+		if _, ok := l.Allow(Write, r); !ok {
+			t.Fatalf("request %d should fit the burst", i)
+		}
+	}
+
+	wait, ok := l.Allow(Write, r)
+	if ok {
+		t.Fatalf("expected the third request to be limited")
+	}
+	if wait != time.Second {
+		t.Fatalf("expected to wait a second, got %v", wait)
+	}
+
+	if _, ok := l.Allow(Read, r); !ok {
+		t.Fatalf("reads have no budget configured and should be allowed")
+	}
+
+	*now = now.Add(time.Second)
+	if _, ok := l.Allow(Write, r); !ok {
+		t.Fatalf("expected a request to fit after refilling")
+	}
+}
+
+func TestLimiter_Keys(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimit{Write: config.RateBudget{PerMinute: 1, Burst: 1}})
+
+	if _, ok := l.Allow(Write, requestFrom("192.0.2.1:1234", "one", "https://quill.example/")); !ok {
+		t.Fatalf("expected the first request to be allowed")
+	}
+
+	// Same client, different token and address
+	if _, ok := l.Allow(Write, requestFrom("192.0.2.2:1234", "two", "https://quill.example/")); ok {
+		t.Fatalf("expected the client's budget to be used up")
+	}
+
+	// Same address, different token and client
+	if _, ok := l.Allow(Write, requestFrom("192.0.2.1:4321", "three", "https://other.example/")); ok {
+		t.Fatalf("expected the address's budget to be used up")
+	}
+
+	if _, ok := l.Allow(Write, requestFrom("192.0.2.3:1234", "four", "https://other.example/")); !ok {
+		t.Fatalf("expected an unrelated request to be allowed")
+	}
+}
+
+func TestLimiter_ForwardedFor(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimit{Read: config.RateBudget{PerMinute: 1}, Keys: []string{"ip"}, ForwardedFor: true})
+
+	r := requestFrom("10.0.0.1:1234", "", "")
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.7")
+	if got := l.remoteIp(r); got != "203.0.113.7" {
+		t.Fatalf("expected the last forwarded address, got %q", got)
+	}
+
+	if _, ok := l.Allow(Read, r); !ok {
+		t.Fatalf("expected the first request to be allowed")
+	}
+	if _, ok := l.Allow(Read, requestFrom("10.0.0.1:1234", "", "")); !ok {
+		t.Fatalf("expected the proxy's own address to be counted separately")
+	}
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	l, now := newTestLimiter(config.RateLimit{Read: config.RateBudget{PerMinute: 60, Burst: 1}, Keys: []string{"ip"}})
+
+	l.Allow(Read, requestFrom("192.0.2.1:1234", "", ""))
+	if got := l.Stats(requestFrom("192.0.2.9:1", "", "")).Budgets[Read].Tracked; got != 1 {
+		t.Fatalf("expected 1 tracked key, got %d", got)
+	}
+
+	*now = now.Add(2 * sweepInterval)
+	l.Allow(Read, requestFrom("192.0.2.2:1234", "", ""))
+	if got := l.Stats(requestFrom("192.0.2.9:1", "", "")).Budgets[Read].Tracked; got != 1 {
+		t.Fatalf("expected the refilled key to be forgotten, got %d tracked", got)
+	}
+}
+
+func TestLimiter_InFlightWrites(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimit{MaxInFlightWrites: 1})
+
+	release, ok := l.AcquireWrite()
+	if !ok {
+		t.Fatalf("expected the first write to get a slot")
+	}
+
+	rr := httptest.NewRecorder()
+	if _, ok := l.EnforceWrite(rr); ok {
+		t.Fatalf("expected the second write to be turned away")
+	}
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected 429 with Retry-After, got %d %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+
+	stats := l.Stats(requestFrom("192.0.2.1:1234", "", ""))
+	if stats.InFlightWrites != 1 || stats.MaxInFlightWrites != 1 || stats.RejectedWrites != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	release()
+	if release, ok := l.AcquireWrite(); !ok {
+		t.Fatalf("expected a slot once the first write finished")
+	} else {
+		release()
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimit{Read: config.RateBudget{PerMinute: 30, Burst: 1}})
+	handler := l.Middleware(Read, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, requestFrom("192.0.2.1:1234", "secret", "https://quill.example/"))
+		if rr.Code != want {
+			t.Fatalf("request %d: expected %d, got %d", i, want, rr.Code)
+		}
+		if want == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "2" {
+			t.Fatalf("expected Retry-After 2, got %q", rr.Header().Get("Retry-After"))
+		}
+	}
+
+	stats := l.Stats(requestFrom("192.0.2.1:1234", "secret", "https://quill.example/"))
+	read := stats.Budgets[Read]
+	if read.Allowed != 1 || read.Limited != 1 || read.Remaining != 0 || read.Tracked != 3 {
+		t.Fatalf("unexpected stats %+v", read)
+	}
+}
+
+func TestMiddleware_BeforeVerification(t *testing.T) {
+	l, _ := newTestLimiter(config.RateLimit{Write: config.RateBudget{PerMinute: 60, Burst: 2}})
+
+	var verified int
+	handler := l.Middleware(Write, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// What token verification would add, followed by the check the handler makes
+		verified++
+		ctx := auth.AddTokenHash(r.Context(), "secret")
+		r = r.WithContext(auth.AddToken(ctx, &auth.TokenDetails{Me: "https://example.org/", ClientId: "https://quill.example/"}))
+		if l.Enforce(Write, w, r) {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Authorization", "Bearer secret")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("request %d: expected %d, got %d", i, want, rr.Code)
+		}
+	}
+
+	if verified != 2 {
+		t.Fatalf("expected the limited request to be turned away before verification, got %d verified", verified)
+	}
+
+	// The token was counted once per request, not again after it was verified
+	stats := l.Stats(requestFrom("192.0.2.1:1234", "secret", "https://quill.example/"))
+	if write := stats.Budgets[Write]; write.Allowed != 4 || write.Tracked != 3 {
+		t.Fatalf("unexpected stats %+v", write)
+	}
+}
+
+func TestMiddleware_ByAddressOnly(t *testing.T) {
+	budget := config.RateBudget{PerMinute: 1, Burst: 1}
+	for _, kind := range []Kind{Webmention, Auth} {
+		l, _ := newTestLimiter(config.RateLimit{Webmention: budget, Auth: budget, Keys: []string{"token"}})
+		handler := l.Middleware(kind, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		}))
+
+		for i, c := range []struct {
+			addr string
+			want int
+		}{
+			{"192.0.2.1:1234", http.StatusAccepted},
+			{"192.0.2.1:4321", http.StatusTooManyRequests},
+			{"192.0.2.2:1234", http.StatusAccepted},
+		} {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, requestFrom(c.addr, "", ""))
+			if rr.Code != c.want {
+				t.Fatalf("%s request %d: expected %d, got %d", kind, i, c.want, rr.Code)
+			}
+		}
+	}
+}
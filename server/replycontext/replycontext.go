@@ -0,0 +1,326 @@
+package replycontext
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/util"
+	"golang.org/x/net/html"
+)
+
+const (
+	defaultTimeout  = 5 * time.Second
+	defaultMaxBytes = 1 << 20
+	// maxContentWords limits the excerpt of the cited content.
+	maxContentWords = 80
+)
+
+// Properties are the response properties whose URLs are enriched, in the order they are processed.
+var Properties = []string{"in-reply-to", "like-of", "repost-of", "bookmark-of"}
+
+// ErrNoContext is returned by Cite when the page has neither microformats nor OpenGraph metadata.
+var ErrNoContext = errors.New("no context found")
+
+// Fetcher turns the bare URLs a post responds to into embedded h-cite objects describing the page:
+// its title, author, excerpt and publication date.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+	replace  bool
+}
+
+func NewFetcher(cfg *config.ReplyContext, client *http.Client) *Fetcher {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if client == nil {
+		client = &http.Client{Timeout: timeout}
+	}
+
+	maxBytes := cfg.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+
+	return &Fetcher{client: client, maxBytes: maxBytes, replace: cfg.Mode != "append"}
+}
+
+// Enrich fetches every URL in the document's response properties concurrently and embeds an h-cite
+// for each, either in place of the URL or after it. URLs that can't be fetched are left as they are;
+// their errors are returned together.
+func (f *Fetcher) Enrich(ctx context.Context, doc *util.Mf2Document) error {
+	type citation struct {
+		property string
+		index    int
+		cite     *util.Mf2Document
+		err      error
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan citation)
+
+	for _, prop := range Properties {
+		for i, val := range doc.Properties[prop] {
+			u, ok := val.(string)
+			if !ok || !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+				// Already embedded by the client, or not something we can fetch.
+				continue
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cite, err := f.Cite(ctx, u)
+				results <- citation{property: prop, index: i, cite: cite, err: err}
+			}()
+		}
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	cites := map[string]map[int]*util.Mf2Document{}
+	var errs []error
+	for c := range results {
+		if c.err != nil {
+			errs = append(errs, c.err)
+			continue
+		}
+		if cites[c.property] == nil {
+			cites[c.property] = map[int]*util.Mf2Document{}
+		}
+		cites[c.property][c.index] = c.cite
+	}
+
+	for prop, byIndex := range cites {
+		values := make([]any, 0, len(doc.Properties[prop])+len(byIndex))
+		for i, val := range doc.Properties[prop] {
+			cite, ok := byIndex[i]
+			if !ok || !f.replace {
+				values = append(values, val)
+			}
+			if ok {
+				values = append(values, *cite)
+			}
+		}
+		doc.Properties[prop] = values
+	}
+
+	return errors.Join(errs...)
+}
+
+// Cite fetches the page at u and describes it as an h-cite, from its h-entry or h-card when it has
+// one and from its OpenGraph metadata otherwise.
+func (f *Fetcher) Cite(ctx context.Context, u string) (*util.Mf2Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html, application/xhtml+xml;q=0.9")
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("fetching %q: unexpected status %d", u, res.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("fetching %q: %w in %s", u, ErrNoContext, mediaType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, f.maxBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	cite := &util.Mf2Document{Type: []string{"h-cite"}, Properties: map[string][]any{"url": {u}}}
+
+	items, err := util.ParseMicroformats(bytes.NewReader(body), res.Request.URL)
+	if err == nil && citeMicroformats(cite, items) {
+		return cite, nil
+	}
+
+	node, err := html.Parse(bytes.NewReader(body))
+	if err == nil && citeOpenGraph(cite, node) {
+		return cite, nil
+	}
+
+	return nil, fmt.Errorf("fetching %q: %w", u, ErrNoContext)
+}
+
+func citeMicroformats(cite *util.Mf2Document, items []*util.Microformat) bool {
+	entry := util.Find(items, "h-entry")
+	if entry == nil {
+		card := util.Find(items, "h-card")
+		if card == nil {
+			return false
+		}
+		set(cite, "name", firstString(card.Properties["name"]))
+		set(cite, "photo", firstString(card.Properties["photo"]))
+		return true
+	}
+
+	props := entry.Properties
+	text := util.PropertyText(props["content"], maxContentWords)
+	if text == "" {
+		text = util.PropertyText(props["summary"], maxContentWords)
+	}
+
+	// Notes have no real title; an implied name would just repeat the content.
+	if name := firstString(props["name"]); name != "" && !strings.HasPrefix(strings.Join(strings.Fields(text), " "), strings.Join(strings.Fields(name), " ")) {
+		set(cite, "name", name)
+	}
+	set(cite, "content", text)
+	set(cite, "published", firstString(props["published"]))
+	set(cite, "photo", firstString(props["photo"]))
+	if u := firstString(props["url"]); u != "" {
+		cite.Properties["url"] = []any{u}
+	}
+
+	if author := citeAuthor(props["author"], items); author != nil {
+		cite.Properties["author"] = []any{*author}
+	}
+
+	return true
+}
+
+// citeAuthor builds an h-card from the entry's author property, falling back to the page's h-card.
+func citeAuthor(values []any, items []*util.Microformat) *util.Mf2Document {
+	card := &util.Mf2Document{Type: []string{"h-card"}, Properties: map[string][]any{}}
+
+	if len(values) > 0 {
+		switch a := values[0].(type) {
+		case string:
+			if strings.HasPrefix(a, "http://") || strings.HasPrefix(a, "https://") {
+				set(card, "url", a)
+			} else {
+				set(card, "name", a)
+			}
+		case map[string]any:
+			if props, ok := a["properties"].(map[string]any); ok {
+				for _, key := range []string{"name", "url", "photo"} {
+					if vals, ok := props[key].([]any); ok {
+						set(card, key, firstString(vals))
+					}
+				}
+			}
+		}
+	} else if hcard := util.Find(items, "h-card"); hcard != nil {
+		for _, key := range []string{"name", "url", "photo"} {
+			set(card, key, firstString(hcard.Properties[key]))
+		}
+	}
+
+	if len(card.Properties) == 0 {
+		return nil
+	}
+
+	return card
+}
+
+// citeOpenGraph fills the citation from OpenGraph meta tags and the page title.
+func citeOpenGraph(cite *util.Mf2Document, root *html.Node) bool {
+	meta := map[string]string{}
+	title := ""
+
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "meta":
+				key := attr(n, "property")
+				if key == "" {
+					key = attr(n, "name")
+				}
+				key = strings.ToLower(key)
+				if _, seen := meta[key]; key != "" && !seen {
+					meta[key] = strings.TrimSpace(attr(n, "content"))
+				}
+			case "title":
+				if title == "" && n.FirstChild != nil {
+					title = strings.TrimSpace(n.FirstChild.Data)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+
+	name := meta["og:title"]
+	if name == "" {
+		name = title
+	}
+	if name == "" && meta["og:description"] == "" {
+		return false
+	}
+
+	set(cite, "name", name)
+	set(cite, "content", meta["og:description"])
+	set(cite, "photo", meta["og:image"])
+	set(cite, "published", meta["article:published_time"])
+	if u := meta["og:url"]; strings.HasPrefix(u, "http") {
+		cite.Properties["url"] = []any{u}
+	}
+
+	author := meta["article:author"]
+	if author == "" {
+		author = meta["author"]
+	}
+	if author == "" {
+		author = meta["og:site_name"]
+	}
+	if author != "" {
+		card := util.Mf2Document{Type: []string{"h-card"}, Properties: map[string][]any{}}
+		if strings.HasPrefix(author, "http://") || strings.HasPrefix(author, "https://") {
+			card.Properties["url"] = []any{author}
+		} else {
+			card.Properties["name"] = []any{author}
+		}
+		cite.Properties["author"] = []any{card}
+	}
+
+	return true
+}
+
+func set(doc *util.Mf2Document, key string, value string) {
+	if value != "" {
+		doc.Properties[key] = []any{value}
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func firstString(values []any) string {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
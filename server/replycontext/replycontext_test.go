@@ -0,0 +1,131 @@
+package replycontext
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/entry", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<article class="h-entry">
+			<h1 class="p-name">On Gardens</h1>
+			<a class="p-author h-card" href="/"><img class="u-photo" src="/me.jpg" alt="">Alice</a>
+			<div class="e-content"><p>Gardens are good for you.</p></div>
+			<time class="dt-published" datetime="2025-05-01T09:00:00Z">May 1</time>
+		</article>`))
+	})
+	mux.HandleFunc("/note", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<div class="h-entry"><p class="p-name e-content">Just a thought</p></div>`))
+	})
+	mux.HandleFunc("/og", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<html><head><title>Fallback</title>
+			<meta property="og:title" content="An Article">
+			<meta property="og:description" content="What it is about">
+			<meta property="og:site_name" content="The Paper">
+			</head><body></body></html>`))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(strings.Repeat(" ", 4096) + `<title>Too far down</title>`))
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCite(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewFetcher(&config.ReplyContext{MaxBytes: 1024}, srv.Client())
+	ctx := context.Background()
+
+	cite, err := f.Cite(ctx, srv.URL+"/entry")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	props := cite.Properties
+	if cite.Type[0] != "h-cite" || props["name"][0] != "On Gardens" || props["content"][0] != "Gardens are good for you." || props["published"][0] != "2025-05-01T09:00:00Z" {
+		t.Fatalf("unexpected cite %+v", cite)
+	}
+	author := props["author"][0].(util.Mf2Document)
+	if author.Properties["name"][0] != "Alice" || author.Properties["url"][0] != srv.URL+"/" || author.Properties["photo"][0] != srv.URL+"/me.jpg" {
+		t.Fatalf("unexpected author %+v", author)
+	}
+
+	note, err := f.Cite(ctx, srv.URL+"/note")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := note.Properties["name"]; ok || note.Properties["content"][0] != "Just a thought" {
+		t.Fatalf("expected a note without a name, got %+v", note)
+	}
+
+	og, err := f.Cite(ctx, srv.URL+"/og")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if og.Properties["name"][0] != "An Article" || og.Properties["content"][0] != "What it is about" || og.Properties["author"][0].(util.Mf2Document).Properties["name"][0] != "The Paper" {
+		t.Fatalf("unexpected OpenGraph cite %+v", og)
+	}
+
+	if _, err := f.Cite(ctx, srv.URL+"/big"); !errors.Is(err, ErrNoContext) {
+		t.Fatalf("expected the size cap to hide the title, got %v", err)
+	}
+}
+
+func TestEnrich(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	doc := func() *util.Mf2Document {
+		return &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+			"in-reply-to": {srv.URL + "/entry", srv.URL + "/broken"},
+			"like-of":     {util.Mf2Document{Type: []string{"h-cite"}, Properties: map[string][]any{"url": {"https://a.example/"}}}},
+		}}
+	}
+
+	replaced := doc()
+	err := NewFetcher(&config.ReplyContext{}, srv.Client()).Enrich(ctx, replaced)
+	if err == nil {
+		t.Fatalf("expected the broken URL to be reported")
+	}
+	reply := replaced.Properties["in-reply-to"]
+	if len(reply) != 2 || reply[1] != srv.URL+"/broken" {
+		t.Fatalf("expected the unfetchable URL to stay as it was, got %+v", reply)
+	}
+	if cite, ok := reply[0].(util.Mf2Document); !ok || cite.Properties["url"][0] != srv.URL+"/entry" {
+		t.Fatalf("expected the URL to be replaced by an h-cite, got %+v", reply[0])
+	}
+	if err := util.ValidateMf2(*replaced); err != nil {
+		t.Fatalf("enriched document is invalid: %v", err)
+	}
+	if _, ok := replaced.Properties["like-of"][0].(util.Mf2Document); !ok || len(replaced.Properties["like-of"]) != 1 {
+		t.Fatalf("expected an embedded value to be left alone, got %+v", replaced.Properties["like-of"])
+	}
+
+	appended := doc()
+	_ = NewFetcher(&config.ReplyContext{Mode: "append"}, srv.Client()).Enrich(ctx, appended)
+	reply = appended.Properties["in-reply-to"]
+	if len(reply) != 3 || reply[0] != srv.URL+"/entry" || reply[2] != srv.URL+"/broken" {
+		t.Fatalf("expected the h-cite after its URL, got %+v", reply)
+	}
+	if _, ok := reply[1].(util.Mf2Document); !ok {
+		t.Fatalf("expected an h-cite after the URL, got %+v", reply[1])
+	}
+}
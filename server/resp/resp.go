@@ -3,7 +3,10 @@ package resp
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type ErrorResponse struct {
@@ -67,6 +70,19 @@ func WriteNotFound(w http.ResponseWriter, description string) {
 	writeError(w, http.StatusNotFound, "not_found", description)
 }
 
+// WriteServiceUnavailable tells the client to come back after retryAfter, rounded up to whole seconds.
+func WriteServiceUnavailable(w http.ResponseWriter, retryAfter time.Duration, description string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeError(w, http.StatusServiceUnavailable, "temporarily_unavailable", description)
+}
+
+// WriteTooManyRequests tells a client over its rate limit to come back after retryAfter, rounded up
+// to whole seconds.
+func WriteTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, description string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, "too_many_requests", description)
+}
+
 func writeError(w http.ResponseWriter, status int, err string, description string) {
 	writeResp(w, status, ErrorResponse{
 		Error:       err,
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestWriteOK(t *testing.T) {
@@ -84,6 +85,16 @@ func TestWriteErrorVariants(t *testing.T) {
 			write: func(w http.ResponseWriter) { WriteInsufficientScope(w, "need scope") },
 			code:  http.StatusUnauthorized, err: "insufficient_scope", desc: "need scope",
 		},
+		{
+			name:  "unavailable",
+			write: func(w http.ResponseWriter) { WriteServiceUnavailable(w, 1500*time.Millisecond, "try later") },
+			code:  http.StatusServiceUnavailable, err: "temporarily_unavailable", desc: "try later",
+		},
+		{
+			name:  "too many requests",
+			write: func(w http.ResponseWriter) { WriteTooManyRequests(w, 1500*time.Millisecond, "slow down") },
+			code:  http.StatusTooManyRequests, err: "too_many_requests", desc: "slow down",
+		},
 	}
 
 	for _, tc := range cases {
@@ -102,6 +113,9 @@ func TestWriteErrorVariants(t *testing.T) {
 			if body.Error != tc.err || body.Description != tc.desc {
 				t.Fatalf("unexpected body %+v", body)
 			}
+			if (tc.code == http.StatusServiceUnavailable || tc.code == http.StatusTooManyRequests) && rr.Header().Get("Retry-After") != "2" {
+				t.Fatalf("expected Retry-After rounded up to 2, got %q", rr.Header().Get("Retry-After"))
+			}
 		})
 	}
 }
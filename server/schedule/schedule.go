@@ -0,0 +1,388 @@
+// Package schedule holds back posts with a published date in the future and publishes them when
+// that date arrives.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/syndication"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// HeldTargetsProperty keeps the syndication targets requested with mp-syndicate-to on a scheduled
+// post, so they survive restarts. It is removed when the post is published. Unlike mp-* commands it
+// is stored, but it is the server's own record: clients can't set it and don't see it in q=source.
+const HeldTargetsProperty = "held-syndicate-to"
+
+// retryDelay is how long a post that failed to publish waits before the next attempt.
+const retryDelay = time.Minute
+
+// publishTimeout bounds a single round of publishing due posts.
+const publishTimeout = 2 * time.Minute
+
+// Clock tells the scheduler the time and wakes it up. Tests substitute a fake.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock is the wall clock.
+var SystemClock Clock = systemClock{}
+
+// Entry is a post waiting to be published.
+type Entry struct {
+	Url       string    `json:"url"`
+	Published time.Time `json:"published"`
+}
+
+// Scheduler tracks scheduled posts and publishes them on time. It must be registered with the
+// content hooks so it notices posts being scheduled, rescheduled, published early or deleted.
+type Scheduler struct {
+	store       content.ContentStore
+	hooks       *hooks.Hooks
+	syndication *syndication.Dispatcher
+	clock       Clock
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewScheduler creates a scheduler publishing through store. Published posts are announced on h
+// and syndicated through dispatcher, either of which may be nil.
+func NewScheduler(store content.ContentStore, h *hooks.Hooks, dispatcher *syndication.Dispatcher, clock Clock) *Scheduler {
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	return &Scheduler{
+		store:       store,
+		hooks:       h,
+		syndication: dispatcher,
+		clock:       clock,
+		pending:     map[string]time.Time{},
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Load picks up the scheduled posts already in the content store, such as those left from the last
+// run. It does nothing if the store cannot list its documents.
+func (s *Scheduler) Load(ctx context.Context) error {
+	lister, ok := s.store.(content.Lister)
+	if !ok {
+		return nil
+	}
+
+	result, err := lister.List(ctx, content.ListOptions{IncludeScheduled: true, IncludePrivate: true})
+	if err != nil {
+		return err
+	}
+
+	for _, doc := range result.Items {
+		if url, ok := firstString(doc.Properties["url"]); ok {
+			s.track(url, doc)
+		}
+	}
+
+	return nil
+}
+
+// Hold marks doc as scheduled if its published date is in the future, keeping syndicateTo for when
+// it is published. It reports whether the post was held. Drafts are never held, and a nil scheduler
+// holds nothing.
+func (s *Scheduler) Hold(doc *util.Mf2Document, syndicateTo []string) bool {
+	if s == nil || content.IsDraft(*doc) {
+		return false
+	}
+
+	published, ok := util.PropertyTime(*doc, "published")
+	if !ok || !published.After(s.clock.Now()) {
+		return false
+	}
+
+	doc.Properties["post-status"] = []any{"scheduled"}
+	HoldTargets(doc, syndicateTo)
+
+	return true
+}
+
+// HoldTargets keeps syndicateTo on doc until it is published, as is done for scheduled posts and
+// drafts.
+func HoldTargets(doc *util.Mf2Document, syndicateTo []string) {
+	if len(syndicateTo) == 0 {
+		return
+	}
+
+	targets := make([]any, 0, len(syndicateTo))
+	for _, uid := range syndicateTo {
+		targets = append(targets, uid)
+	}
+	doc.Properties[HeldTargetsProperty] = targets
+}
+
+// Pending returns the posts waiting to be published, soonest first. A nil scheduler has none.
+func (s *Scheduler) Pending() []Entry {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(s.pending))
+	for url, at := range s.pending {
+		entries = append(entries, Entry{Url: url, Published: at})
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if c := a.Published.Compare(b.Published); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Url, b.Url)
+	})
+
+	return entries
+}
+
+// ContentChanged keeps the schedule in step with the content store.
+func (s *Scheduler) ContentChanged(_ context.Context, ev hooks.Event) {
+	switch {
+	case ev.Action == hooks.ActionDelete:
+		s.untrack(ev.Url)
+	case ev.Document != nil:
+		s.track(ev.Url, *ev.Document)
+	}
+}
+
+// Start runs the scheduler in the background until Stop is called.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(s.stop, s.done)
+}
+
+// Stop ends the background loop and waits for a publish in progress to finish. Posts not yet
+// published stay scheduled in the store and are picked up again by Load.
+func (s *Scheduler) Stop() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	s.stop = nil
+	s.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+}
+
+func (s *Scheduler) run(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	for {
+		var due <-chan time.Time
+		if next, ok := s.next(); ok {
+			due = s.clock.After(max(next.Sub(s.clock.Now()), 0))
+		}
+
+		select {
+		case <-due:
+			ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+			s.PublishDue(ctx)
+			cancel()
+		case <-s.wake:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// PublishDue publishes every post whose time has come. Posts that fail to publish are retried a
+// little later.
+func (s *Scheduler) PublishDue(ctx context.Context) {
+	now := s.clock.Now()
+
+	var due []string
+	s.mu.Lock()
+	for url, at := range s.pending {
+		if !at.After(now) {
+			due = append(due, url)
+		}
+	}
+	s.mu.Unlock()
+	slices.Sort(due)
+
+	for _, url := range due {
+		if err := s.Publish(ctx, url); err != nil {
+			log.Printf("error: failed to publish scheduled post %q: %v", url, err)
+
+			s.mu.Lock()
+			if _, ok := s.pending[url]; ok {
+				s.pending[url] = now.Add(retryDelay)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Publish publishes the scheduled post at url now: it is marked published, which moves it into
+// place, the content hooks are fired and any held syndication is queued. Posts that are no longer
+// scheduled are left alone.
+func (s *Scheduler) Publish(ctx context.Context, url string) error {
+	previous, err := s.store.Get(ctx, url)
+	if errors.Is(err, content.ErrNotFound) {
+		s.untrack(url)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !content.IsScheduled(*previous) || content.IsDeleted(*previous) {
+		s.untrack(url)
+		return nil
+	}
+
+	targets := HeldTargets(*previous)
+
+	newUrl, err := s.store.Update(ctx, url, map[string][]any{"post-status": {"published"}}, nil, []string{HeldTargetsProperty})
+	if err != nil {
+		return err
+	}
+	s.untrack(url)
+
+	doc, err := s.store.Get(ctx, newUrl)
+	if err != nil {
+		log.Printf("error: failed to read back %q for hooks: %v", newUrl, err)
+		doc = nil
+	}
+	ev := hooks.Event{Action: hooks.ActionUpdate, Url: newUrl, Document: doc, Previous: previous}
+	if newUrl != url {
+		ev.PreviousUrl = url
+	}
+	s.hooks.Fire(ctx, ev)
+
+	if doc != nil && content.Visibility(*doc) != content.VisibilityPublic {
+		// Held targets of a post that has since been made unlisted or private are dropped.
+		targets = nil
+	}
+	if err := s.syndication.Dispatch(newUrl, targets); err != nil {
+		// The post is live; failing to queue syndication must not make it count as unpublished.
+		log.Printf("error: %v", err)
+	}
+
+	return nil
+}
+
+// HeldTargets returns the syndication targets held on doc until it is published.
+func HeldTargets(doc util.Mf2Document) []string {
+	var targets []string
+	for _, v := range doc.Properties[HeldTargetsProperty] {
+		if uid, ok := v.(string); ok && uid != "" {
+			targets = append(targets, uid)
+		}
+	}
+
+	return targets
+}
+
+// WithoutHeldTargets returns doc without its held targets, for showing to clients. doc itself is
+// left untouched.
+func WithoutHeldTargets(doc *util.Mf2Document) *util.Mf2Document {
+	if _, ok := doc.Properties[HeldTargetsProperty]; !ok {
+		return doc
+	}
+
+	out := &util.Mf2Document{Type: doc.Type, Properties: make(map[string][]any, len(doc.Properties))}
+	for k, v := range doc.Properties {
+		if k != HeldTargetsProperty {
+			out.Properties[k] = v
+		}
+	}
+
+	return out
+}
+
+func (s *Scheduler) track(url string, doc util.Mf2Document) {
+	published, ok := util.PropertyTime(doc, "published")
+	if !ok || !content.IsScheduled(doc) || content.IsDeleted(doc) {
+		s.untrack(url)
+		return
+	}
+
+	s.mu.Lock()
+	s.pending[url] = published
+	s.mu.Unlock()
+	s.poke()
+}
+
+func (s *Scheduler) untrack(url string) {
+	s.mu.Lock()
+	_, ok := s.pending[url]
+	delete(s.pending, url)
+	s.mu.Unlock()
+
+	if ok {
+		s.poke()
+	}
+}
+
+// poke wakes the background loop so it recomputes when the next post is due.
+func (s *Scheduler) poke() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) next() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next time.Time
+	found := false
+	for _, at := range s.pending {
+		if !found || at.Before(next) {
+			next, found = at, true
+		}
+	}
+
+	return next, found
+}
+
+func firstString(values []any) (string, bool) {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			return s, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,206 @@
+package schedule
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/syndication"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	after chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, after: make(chan time.Time)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(time.Duration) <-chan time.Time { return c.after }
+
+// Advance moves the clock forward and wakes the scheduler loop.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	c.mu.Unlock()
+	c.after <- now
+}
+
+type memoryStore struct {
+	content.ContentStore
+	mu   sync.Mutex
+	docs map[string]util.Mf2Document
+}
+
+func (m *memoryStore) Get(_ context.Context, url string) (*util.Mf2Document, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc, ok := m.docs[url]
+	if !ok {
+		return nil, content.ErrNotFound
+	}
+	return &doc, nil
+}
+
+func (m *memoryStore) Update(_ context.Context, url string, replacements map[string][]any, _ map[string][]any, deletions any) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	doc := m.docs[url]
+	props := map[string][]any{}
+	for k, v := range doc.Properties {
+		props[k] = v
+	}
+	for k, v := range replacements {
+		props[k] = v
+	}
+	for _, k := range deletions.([]string) {
+		delete(props, k)
+	}
+	doc.Properties = props
+	m.docs[url] = doc
+	return url, nil
+}
+
+func (m *memoryStore) List(context.Context, content.ListOptions) (*content.ListResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var items []util.Mf2Document
+	for url, doc := range m.docs {
+		doc.Properties["url"] = []any{url}
+		items = append(items, doc)
+	}
+	return &content.ListResult{Items: items}, nil
+}
+
+func TestHold(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewScheduler(nil, nil, nil, newFakeClock(now))
+
+	future := util.Mf2Document{Properties: map[string][]any{"published": {"2025-01-02T00:00:00Z"}}}
+	if !s.Hold(&future, []string{"masto"}) {
+		t.Fatalf("expected future post to be held")
+	}
+	if !content.IsScheduled(future) || len(future.Properties[HeldTargetsProperty]) != 1 {
+		t.Fatalf("unexpected held document: %+v", future.Properties)
+	}
+
+	past := util.Mf2Document{Properties: map[string][]any{"published": {"2024-12-31T00:00:00Z"}}}
+	draft := util.Mf2Document{Properties: map[string][]any{"published": {"2025-01-02T00:00:00Z"}, "post-status": {"draft"}}}
+	if s.Hold(&past, nil) || s.Hold(&draft, nil) {
+		t.Fatalf("expected past posts and drafts to go through")
+	}
+
+	var nilScheduler *Scheduler
+	if nilScheduler.Hold(&future, nil) || nilScheduler.Pending() != nil {
+		t.Fatalf("expected nil scheduler to hold nothing")
+	}
+}
+
+func TestSchedulerPublishesWhenDue(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(now)
+	store := &memoryStore{docs: map[string]util.Mf2Document{
+		"https://example.org/soon": {Type: []string{"h-entry"}, Properties: map[string][]any{
+			"published":         {"2025-01-01T13:00:00Z"},
+			"post-status":       {"scheduled"},
+			HeldTargetsProperty: {"masto"},
+		}},
+		"https://example.org/later": {Type: []string{"h-entry"}, Properties: map[string][]any{
+			"published":   {"2025-01-02T12:00:00Z"},
+			"post-status": {"scheduled"},
+		}},
+		"https://example.org/live": {Type: []string{"h-entry"}, Properties: map[string][]any{
+			"published": {"2024-01-01T12:00:00Z"},
+		}},
+	}}
+
+	runner, err := jobs.NewRunner(&config.Jobs{})
+	if err != nil {
+		t.Fatalf("failed to create job runner: %v", err)
+	}
+	dispatcher, err := syndication.NewDispatcher(&config.Syndication{}, store, runner)
+	if err != nil {
+		t.Fatalf("failed to create dispatcher: %v", err)
+	}
+
+	var h hooks.Hooks
+	events := make(chan hooks.Event, 4)
+	h.Register(hooks.Public(hooks.ListenerFunc(func(_ context.Context, ev hooks.Event) { events <- ev })))
+
+	s := NewScheduler(store, &h, dispatcher, clock)
+	h.Register(s)
+
+	// A restart: the scheduled posts are only known from the store.
+	if err := s.Load(context.Background()); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	pending := s.Pending()
+	if len(pending) != 2 || pending[0].Url != "https://example.org/soon" || pending[1].Url != "https://example.org/later" {
+		t.Fatalf("unexpected pending posts: %+v", pending)
+	}
+
+	s.Start()
+	defer s.Stop()
+
+	clock.Advance(2 * time.Hour)
+
+	ev := <-events
+	if ev.Action != hooks.ActionCreate || ev.Url != "https://example.org/soon" {
+		t.Fatalf("expected publish to be announced as a create, got %+v", ev)
+	}
+
+	doc, _ := store.Get(context.Background(), "https://example.org/soon")
+	if content.IsScheduled(*doc) || len(doc.Properties[HeldTargetsProperty]) != 0 {
+		t.Fatalf("expected post to be published, got %+v", doc.Properties)
+	}
+	if jobs := runner.Pending(); len(jobs) != 1 || jobs[0].Kind != syndication.JobKind {
+		t.Fatalf("expected held syndication to be queued, got %+v", jobs)
+	}
+	if pending := s.Pending(); len(pending) != 1 || pending[0].Url != "https://example.org/later" {
+		t.Fatalf("expected only the later post to remain, got %+v", pending)
+	}
+}
+
+func TestSchedulerFollowsContentChanges(t *testing.T) {
+	s := NewScheduler(nil, nil, nil, newFakeClock(time.Now()))
+	url := "https://example.org/post"
+
+	s.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionCreate, Url: url, Document: &util.Mf2Document{Properties: map[string][]any{
+		"published":   {"2030-01-01T00:00:00Z"},
+		"post-status": {"scheduled"},
+	}}})
+	if pending := s.Pending(); len(pending) != 1 || pending[0].Published.Year() != 2030 {
+		t.Fatalf("expected post to be tracked, got %+v", pending)
+	}
+
+	s.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionUpdate, Url: url, Document: &util.Mf2Document{Properties: map[string][]any{
+		"published":   {"2031-01-01T00:00:00Z"},
+		"post-status": {"scheduled"},
+	}}})
+	if pending := s.Pending(); len(pending) != 1 || pending[0].Published.Year() != 2031 {
+		t.Fatalf("expected post to be rescheduled, got %+v", pending)
+	}
+
+	s.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionDelete, Url: url})
+	if pending := s.Pending(); len(pending) != 0 {
+		t.Fatalf("expected deleted post to be dropped, got %+v", pending)
+	}
+}
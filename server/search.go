@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/storage/content"
+	"github.com/indieinfra/scribble/storage/search"
+	searchfactory "github.com/indieinfra/scribble/storage/search/factory"
+)
+
+func initializeSearch(st *state.ScribbleState) error {
+	idx, err := searchfactory.Create(&st.Cfg.Search)
+	if err != nil {
+		return err
+	}
+	if idx == nil {
+		return nil
+	}
+	st.SearchIndex = idx
+
+	// An in-memory index starts out empty, and a persisted one may not have been built yet.
+	if embedded, ok := idx.(*search.EmbeddedIndex); ok && (!embedded.Persistent() || embedded.Len() == 0) {
+		if lister, ok := st.ContentStore.(content.Lister); ok {
+			n, err := search.Rebuild(context.Background(), idx, lister)
+			if err != nil {
+				return err
+			}
+			log.Printf("built search index with %d documents", n)
+		}
+	}
+
+	st.Hooks.Register(hooks.Public(hooks.ListenerFunc(func(ctx context.Context, ev hooks.Event) {
+		// A post that moved is no longer found at its old URL.
+		if ev.PreviousUrl != "" && ev.PreviousUrl != ev.Url {
+			if err := idx.Remove(ctx, ev.PreviousUrl); err != nil {
+				log.Printf("error: failed to remove %q from the search index: %v", ev.PreviousUrl, err)
+			}
+		}
+
+		var err error
+		switch {
+		case ev.Action == hooks.ActionDelete:
+			err = idx.Remove(ctx, ev.Url)
+		case ev.Document != nil:
+			err = idx.Index(ctx, ev.Url, *ev.Document)
+		}
+
+		if err != nil {
+			log.Printf("error: failed to update search index for %q: %v", ev.Url, err)
+		}
+	})))
+
+	return nil
+}
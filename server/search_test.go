@@ -0,0 +1,34 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func TestSearchIndex_FollowsMovedPosts(t *testing.T) {
+	st := &state.ScribbleState{
+		Cfg:   &config.Config{Search: config.Search{Strategy: "embedded"}},
+		Hooks: &hooks.Hooks{},
+	}
+	if err := initializeSearch(st); err != nil {
+		t.Fatalf("failed to set up search: %v", err)
+	}
+
+	ctx := context.Background()
+	doc := &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"hello scribble"}}}
+	st.Hooks.Fire(ctx, hooks.Event{Action: hooks.ActionCreate, Url: "https://example.org/old", Document: doc})
+	st.Hooks.Fire(ctx, hooks.Event{Action: hooks.ActionUpdate, Url: "https://example.org/new", Document: doc, Previous: doc, PreviousUrl: "https://example.org/old"})
+
+	results, err := st.SearchIndex.Search(ctx, "scribble", 10)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Url != "https://example.org/new" {
+		t.Fatalf("expected only the new url to be found, got %+v", results)
+	}
+}
@@ -11,36 +11,46 @@ import (
 	"time"
 
 	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/audit"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/author"
+	"github.com/indieinfra/scribble/server/contact"
+	"github.com/indieinfra/scribble/server/expiry"
 	"github.com/indieinfra/scribble/server/handler/get"
 	"github.com/indieinfra/scribble/server/handler/post"
 	"github.com/indieinfra/scribble/server/handler/upload"
+	webmentionhandler "github.com/indieinfra/scribble/server/handler/webmention"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/indieauth"
+	"github.com/indieinfra/scribble/server/jobs"
 	"github.com/indieinfra/scribble/server/middleware"
+	"github.com/indieinfra/scribble/server/policy"
+	"github.com/indieinfra/scribble/server/ratelimit"
+	"github.com/indieinfra/scribble/server/replycontext"
+	"github.com/indieinfra/scribble/server/schedule"
 	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/syndication"
+	"github.com/indieinfra/scribble/server/websub"
 	"github.com/indieinfra/scribble/storage/content"
 	contentfactory "github.com/indieinfra/scribble/storage/content/factory"
 	"github.com/indieinfra/scribble/storage/media"
 	mediafactory "github.com/indieinfra/scribble/storage/media/factory"
 )
 
+// drainTimeout bounds how long shutdown waits for running jobs.
+const drainTimeout = 30 * time.Second
+
 func StartServer(cfg *config.Config) error {
 	log.Println("initializing...")
-	st, err := initialize(&state.ScribbleState{Cfg: cfg})
+	sites, err := initializeSites(cfg)
 	if err != nil {
-		if st != nil && st.ContentStore != nil {
-			cleanup(st)
-		}
 		return fmt.Errorf("initialization failed: %w", err)
 	}
 
 	log.Println("configuring routes...")
-	mux := http.NewServeMux()
-	mux.Handle("GET /", middleware.ValidateTokenMiddleware(st.Cfg, get.DispatchGet(st)))
-	mux.Handle("POST /", middleware.ValidateTokenMiddleware(st.Cfg, post.DispatchPost(st)))
-	mux.Handle("POST /media", middleware.ValidateTokenMiddleware(st.Cfg, upload.HandleMediaUpload(st)))
-
 	srv := &http.Server{
-		Addr:    fmt.Sprintf("%v:%v", st.Cfg.Server.Address, st.Cfg.Server.Port),
-		Handler: mux,
+		Addr:    fmt.Sprintf("%v:%v", cfg.Server.Address, cfg.Server.Port),
+		Handler: middleware.RequestId(sites),
 	}
 
 	// Start serving in background to support graceful shutdown.
@@ -64,21 +74,63 @@ func StartServer(cfg *config.Config) error {
 		if err := srv.Shutdown(ctx); err != nil {
 			log.Printf("graceful shutdown failed: %v", err)
 		}
-		cleanup(st)
+		sites.cleanup()
 		return nil
 	case err := <-errChan:
-		cleanup(st)
+		sites.cleanup()
 		return err
 	}
 }
 
+// routes sets up the endpoints of a site.
+func routes(st *state.ScribbleState) *http.ServeMux {
+	mux := http.NewServeMux()
+	// Rate limits apply before tokens are verified or bodies read, and again once the client is known.
+	mux.Handle("GET /", st.Limits.Middleware(ratelimit.Read, middleware.ValidateTokenMiddleware(st.Tokens, st.Limits.TokenMiddleware(ratelimit.Read, policy.Middleware(st.Cfg.Micropub.Policies, get.DispatchGet(st))))))
+	mux.Handle("POST /", st.Limits.Middleware(ratelimit.Write, middleware.ValidateTokenMiddleware(st.Tokens, post.DispatchPost(st))))
+	mux.Handle("POST /media", st.Limits.Middleware(ratelimit.Media, middleware.ValidateTokenMiddleware(st.Tokens, upload.HandleMediaUpload(st))))
+	if st.IndieAuth != nil {
+		// Clients come here to obtain tokens, so these endpoints are not token protected; they are
+		// limited by address instead.
+		authLimited := func(h http.HandlerFunc) http.Handler { return st.Limits.Middleware(ratelimit.Auth, h) }
+		mux.HandleFunc("GET /.well-known/oauth-authorization-server", st.IndieAuth.HandleMetadata)
+		mux.Handle("GET /auth", authLimited(st.IndieAuth.HandleAuthorize))
+		mux.Handle("POST /auth", authLimited(st.IndieAuth.HandleAuthorizePost))
+		mux.Handle("GET /token", authLimited(st.IndieAuth.HandleTokenInfo))
+		mux.Handle("POST /token", authLimited(st.IndieAuth.HandleToken))
+		mux.Handle("POST /revoke", authLimited(st.IndieAuth.HandleRevoke))
+	}
+	if st.Mentions != nil {
+		// Webmentions come from anyone on the web, so this endpoint is not token protected.
+		mux.Handle("POST /webmention", st.Limits.Middleware(ratelimit.Webmention, webmentionhandler.HandleWebmention(st)))
+	}
+
+	return mux
+}
+
 func initialize(st *state.ScribbleState) (*state.ScribbleState, error) {
+	if err := initializeTokens(st); err != nil {
+		return nil, err
+	}
+
+	st.Limits = ratelimit.NewLimiter(&st.Cfg.Server.RateLimit)
+
+	auditLog, err := audit.NewLog(&st.Cfg.Audit)
+	if err != nil {
+		return nil, err
+	}
+	st.Audit = auditLog
+
 	contentStore, err := initializeContentStore(&st.Cfg.Content)
 	if err != nil {
 		return nil, err
 	}
 	st.ContentStore = contentStore
 
+	if placer, ok := contentStore.(content.Placer); ok {
+		placer.SetPathResolver(pathResolver(st.Cfg))
+	}
+
 	mediaStore, err := initializeMediaStore(&st.Cfg.Media)
 	if err != nil {
 		if gitStore, ok := st.ContentStore.(*content.GitContentStore); ok {
@@ -88,9 +140,110 @@ func initialize(st *state.ScribbleState) (*state.ScribbleState, error) {
 	}
 	st.MediaStore = mediaStore
 
+	runner, err := jobs.NewRunner(&st.Cfg.Jobs)
+	if err != nil {
+		return st, err
+	}
+	st.Jobs = runner
+
+	if err := initializeSearch(st); err != nil {
+		return st, err
+	}
+
+	if err := initializeCategories(st); err != nil {
+		return st, err
+	}
+
+	reader, _ := st.ContentStore.(content.FileReader)
+	st.Contacts = contact.NewDirectory(&st.Cfg.Micropub.Contacts, reader)
+
+	dispatcher, err := syndication.NewDispatcher(&st.Cfg.Syndication, st.ContentStore, st.Jobs)
+	if err != nil {
+		return st, err
+	}
+	st.Syndication = dispatcher
+
+	st.Scheduler = schedule.NewScheduler(st.ContentStore, st.Hooks, st.Syndication, nil)
+	if err := st.Scheduler.Load(context.Background()); err != nil {
+		return st, err
+	}
+	st.Hooks.Register(st.Scheduler)
+
+	st.Expiry = expiry.NewExpirer(st.Cfg, st.ContentStore, st.MediaStore, st.Hooks, st.Jobs)
+	st.Hooks.Register(st.Expiry)
+
+	if err := initializeWebmention(st); err != nil {
+		return st, err
+	}
+
+	if st.Cfg.ReplyContext.Enabled {
+		st.ReplyContext = replycontext.NewFetcher(&st.Cfg.ReplyContext, nil)
+	}
+
+	if len(st.Cfg.WebSub.Hubs) > 0 {
+		st.WebSub = websub.NewPublisher(&st.Cfg.WebSub, st.Jobs, nil)
+		st.Hooks.Register(hooks.Public(st.WebSub))
+	}
+
+	// Every job kind is registered by now; start working through what was left from the last run.
+	st.Jobs.Start()
+	st.Scheduler.Start()
+
 	return st, nil
 }
 
+// initializeTokens sets up token verification: the built-in IndieAuth server's own token database,
+// local JWT verification, or a client for the configured token or introspection endpoint. Personal
+// access tokens, if enabled, are checked before any of them. Authors' tokens are limited to the
+// scopes each is allowed.
+func initializeTokens(st *state.ScribbleState) error {
+	switch st.Cfg.Micropub.TokenVerification {
+	case auth.VerificationBuiltin:
+		if err := initializeIndieAuth(st); err != nil {
+			return err
+		}
+	case auth.VerificationJwt:
+		verifier, err := auth.NewJwtVerifier(st.Cfg, nil)
+		if err != nil {
+			return err
+		}
+		st.Tokens = verifier
+	default:
+		st.Tokens = auth.NewTokenClient(st.Cfg, nil)
+	}
+
+	if path := st.Cfg.Auth.PersonalTokensPath; path != "" {
+		personal, err := indieauth.NewTokenStore(path)
+		if err != nil {
+			return err
+		}
+		st.Tokens = auth.Chain{personal, st.Tokens}
+	}
+
+	if author.Enabled(&st.Cfg.Micropub) {
+		st.Tokens = author.Restrict(&st.Cfg.Micropub, st.Tokens)
+	}
+
+	return nil
+}
+
+func initializeIndieAuth(st *state.ScribbleState) error {
+	tokens, err := indieauth.NewTokenStore(st.Cfg.IndieAuth.TokensPath)
+	if err != nil {
+		return err
+	}
+
+	server, err := indieauth.NewServer(st.Cfg, tokens, nil)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("built-in IndieAuth server enabled; link to %s from %s with rel=\"indieauth-metadata\"", server.MetadataUrl(), st.Cfg.Micropub.MeUrl)
+	st.IndieAuth = server
+	st.Tokens = tokens
+	return nil
+}
+
 func initializeContentStore(cfg *config.Content) (content.ContentStore, error) {
 	return contentfactory.Create(cfg)
 }
@@ -100,6 +253,25 @@ func initializeMediaStore(cfg *config.Media) (media.MediaStore, error) {
 }
 
 func cleanup(state *state.ScribbleState) {
+	// Posts not yet due stay scheduled in the content store and are picked up on the next start
+	state.Scheduler.Stop()
+
+	// Let running jobs finish before the content store goes away; queued ones resume on the next start
+	if state.Jobs != nil {
+		// Pings still waiting out the debounce window are queued rather than dropped
+		state.WebSub.Flush()
+
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+		if err := state.Jobs.Drain(ctx); err != nil {
+			log.Printf("error draining jobs: %v", err)
+		}
+	}
+
+	if err := state.Audit.Close(); err != nil {
+		log.Printf("error during cleanup: %v", err)
+	}
+
 	// Cleanup git content store if applicable
 	if gitStore, ok := state.ContentStore.(*content.GitContentStore); ok {
 		if err := gitStore.Cleanup(); err != nil {
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"cmp"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/resp"
+	"github.com/indieinfra/scribble/server/state"
+)
+
+// site is one of the sites served by the instance, with its own state and routes.
+type site struct {
+	host    string
+	prefix  string
+	state   *state.ScribbleState
+	handler http.Handler
+}
+
+// siteRouter hands each request to the site it is for, going by its Host header and path. Sites
+// are ordered from most to least specific, so the first match wins.
+type siteRouter []*site
+
+// initializeSites sets up every configured site, or the one site of a configuration without any.
+// If a site fails, those already set up are cleaned up again.
+func initializeSites(cfg *config.Config) (siteRouter, error) {
+	sites := cfg.Sites
+	if len(sites) == 0 {
+		sites = []config.Site{{Config: cfg}}
+	}
+
+	var router siteRouter
+	for _, s := range sites {
+		if s.Name() != "" {
+			log.Printf("initializing site %q...", s.Name())
+		}
+
+		st, err := initialize(&state.ScribbleState{Cfg: s.Config, Hooks: &hooks.Hooks{}})
+		if err != nil {
+			if st != nil && st.ContentStore != nil {
+				cleanup(st)
+			}
+			router.cleanup()
+			return nil, err
+		}
+
+		router = append(router, &site{host: strings.ToLower(s.Host), prefix: s.PathPrefix, state: st, handler: routes(st)})
+	}
+
+	return newSiteRouter(router), nil
+}
+
+// newSiteRouter orders sites so those for a host come before those for any host, and longer path
+// prefixes before shorter ones.
+func newSiteRouter(sites []*site) siteRouter {
+	slices.SortStableFunc(sites, func(a, b *site) int {
+		if (a.host == "") != (b.host == "") {
+			if a.host != "" {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(len(b.prefix), len(a.prefix))
+	})
+
+	return sites
+}
+
+func (sr siteRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(host)
+
+	for _, s := range sr {
+		if s.host != "" && s.host != host {
+			continue
+		}
+		if s.prefix == "" {
+			s.handler.ServeHTTP(w, r)
+			return
+		}
+
+		rest, ok := strings.CutPrefix(r.URL.Path, s.prefix)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			continue
+		}
+		if rest == "" {
+			rest = "/"
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = rest
+		r2.URL.RawPath = ""
+		s.handler.ServeHTTP(w, r2)
+		return
+	}
+
+	resp.WriteNotFound(w, "No site is served here")
+}
+
+// cleanup shuts down every site's background work and stores.
+func (sr siteRouter) cleanup() {
+	for _, s := range sr {
+		cleanup(s.state)
+	}
+}
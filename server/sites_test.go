@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func namedHandler(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Site", name)
+		w.Header().Set("X-Path", r.URL.Path)
+	})
+}
+
+func TestSiteRouter(t *testing.T) {
+	router := newSiteRouter([]*site{
+		{prefix: "/projects", handler: namedHandler("projects")},
+		{host: "family.example", handler: namedHandler("family")},
+		{host: "family.example", prefix: "/grandma", handler: namedHandler("grandma")},
+		{prefix: "/projects/scribble", handler: namedHandler("scribble")},
+	})
+
+	cases := []struct {
+		host, path string
+		site, rest string
+	}{
+		{"family.example", "/", "family", "/"},
+		{"FAMILY.example:8080", "/media", "family", "/media"},
+		{"family.example", "/grandma", "grandma", "/"},
+		{"family.example", "/grandma/media", "grandma", "/media"},
+		{"family.example", "/grandmas", "family", "/grandmas"},
+		{"family.example", "/projects", "family", "/projects"},
+		{"other.example", "/projects/media", "projects", "/media"},
+		{"other.example", "/projects/scribble/media", "scribble", "/media"},
+		{"other.example", "/", "", ""},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Host = c.host
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("X-Site"); got != c.site {
+			t.Fatalf("%s%s: expected site %q, got %q", c.host, c.path, c.site, got)
+		}
+		if got := rr.Header().Get("X-Path"); got != c.rest {
+			t.Fatalf("%s%s: expected path %q, got %q", c.host, c.path, c.rest, got)
+		}
+		if c.site == "" && rr.Code != http.StatusNotFound {
+			t.Fatalf("%s%s: expected 404, got %d", c.host, c.path, rr.Code)
+		}
+	}
+}
@@ -2,12 +2,49 @@ package state
 
 import (
 	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/audit"
+	"github.com/indieinfra/scribble/server/auth"
+	"github.com/indieinfra/scribble/server/contact"
+	"github.com/indieinfra/scribble/server/expiry"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/indieauth"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/ratelimit"
+	"github.com/indieinfra/scribble/server/replycontext"
+	"github.com/indieinfra/scribble/server/schedule"
+	"github.com/indieinfra/scribble/server/syndication"
+	"github.com/indieinfra/scribble/server/webmention"
+	"github.com/indieinfra/scribble/server/websub"
+	"github.com/indieinfra/scribble/storage/category"
 	"github.com/indieinfra/scribble/storage/content"
 	"github.com/indieinfra/scribble/storage/media"
+	"github.com/indieinfra/scribble/storage/search"
 )
 
 type ScribbleState struct {
-	Cfg          *config.Config
+	Cfg    *config.Config
+	Tokens auth.Verifier
+	// IndieAuth is nil unless the built-in IndieAuth server is enabled.
+	IndieAuth    *indieauth.Server
 	ContentStore content.ContentStore
 	MediaStore   media.MediaStore
+	// SearchIndex is nil when search is disabled.
+	SearchIndex search.Index
+	Categories  *category.Vocabulary
+	Contacts    *contact.Directory
+	Hooks       *hooks.Hooks
+	Jobs        *jobs.Runner
+	Syndication *syndication.Dispatcher
+	Scheduler   *schedule.Scheduler
+	Expiry      *expiry.Expirer
+	// Mentions is nil unless receiving webmentions is enabled.
+	Mentions *webmention.Receiver
+	// WebSub is nil unless hubs are configured.
+	WebSub *websub.Publisher
+	// ReplyContext is nil unless reply-context enrichment is enabled.
+	ReplyContext *replycontext.Fetcher
+	// Audit is nil unless audit sinks are configured.
+	Audit *audit.Log
+	// Limits is nil unless rate limits or a cap on writes in progress are configured.
+	Limits *ratelimit.Limiter
 }
@@ -0,0 +1,78 @@
+package syndication
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+const (
+	mastodonStatusLimit = 500
+	// mastodonLinkLength is the fixed length Mastodon counts for any URL in a status.
+	mastodonLinkLength = 23
+)
+
+// MastodonSyndicator posts statuses through the Mastodon client API, which is also implemented by
+// compatible servers such as Pleroma, Akkoma and GoToSocial.
+type MastodonSyndicator struct {
+	cfg    *config.MastodonSyndication
+	client *http.Client
+}
+
+func NewMastodonSyndicator(cfg *config.MastodonSyndication, client *http.Client) *MastodonSyndicator {
+	return &MastodonSyndicator{cfg: cfg, client: client}
+}
+
+type mastodonStatus struct {
+	Status     string `json:"status"`
+	Visibility string `json:"visibility,omitempty"`
+}
+
+func (ms *MastodonSyndicator) Syndicate(ctx context.Context, url string, doc util.Mf2Document) (string, error) {
+	body, err := json.Marshal(mastodonStatus{
+		Status:     composeText(url, doc, mastodonStatusLimit, mastodonLinkLength),
+		Visibility: ms.cfg.Visibility,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := strings.TrimSuffix(ms.cfg.Instance, "/") + "/api/v1/statuses"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+ms.cfg.AccessToken)
+	// Retried deliveries of the same post must not create duplicate statuses.
+	req.Header.Set("Idempotency-Key", url)
+
+	res, err := ms.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", fmt.Errorf("mastodon responded with %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var status struct {
+		Url string `json:"url"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&status); err != nil {
+		return "", fmt.Errorf("mastodon returned an unreadable status: %w", err)
+	}
+
+	return status.Url, nil
+}
@@ -0,0 +1,57 @@
+package syndication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func TestMastodonSyndicator(t *testing.T) {
+	var got mastodonStatus
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/statuses" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("unexpected authorization %q", auth)
+		}
+		if key := r.Header.Get("Idempotency-Key"); key != "https://example.org/post" {
+			t.Errorf("unexpected idempotency key %q", key)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","url":"https://social.example/@me/1"}`))
+	}))
+	defer srv.Close()
+
+	ms := NewMastodonSyndicator(&config.MastodonSyndication{Instance: srv.URL + "/", AccessToken: "secret", Visibility: "unlisted"}, srv.Client())
+	doc := util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"Hello"}}}
+
+	url, err := ms.Syndicate(context.Background(), "https://example.org/post", doc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if url != "https://social.example/@me/1" {
+		t.Fatalf("unexpected url %q", url)
+	}
+	if got.Status != "Hello" || got.Visibility != "unlisted" {
+		t.Fatalf("unexpected status %+v", got)
+	}
+}
+
+func TestMastodonSyndicator_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"The access token is invalid"}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	ms := NewMastodonSyndicator(&config.MastodonSyndication{Instance: srv.URL}, srv.Client())
+	if _, err := ms.Syndicate(context.Background(), "https://example.org/post", util.Mf2Document{}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
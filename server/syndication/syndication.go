@@ -0,0 +1,247 @@
+package syndication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// requestTimeout bounds every request made to a syndication target.
+const requestTimeout = 30 * time.Second
+
+// Syndicator publishes a copy of a post to a third-party service.
+type Syndicator interface {
+	// Syndicate publishes the document stored at url and returns the URL of the syndicated
+	// copy. An empty URL means the service accepted the post without reporting where it lives.
+	Syndicate(ctx context.Context, url string, doc util.Mf2Document) (string, error)
+}
+
+// Factory builds a syndicator for the provided target config.
+type Factory func(target *config.SyndicationTarget, client *http.Client) (Syndicator, error)
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Factory{}
+)
+
+// Register adds or replaces a syndicator factory for the given target type.
+func Register(kind string, factory Factory) {
+	mu.Lock()
+	registry[kind] = factory
+	mu.Unlock()
+}
+
+func init() {
+	Register("mastodon", func(target *config.SyndicationTarget, client *http.Client) (Syndicator, error) {
+		return NewMastodonSyndicator(target.Mastodon, client), nil
+	})
+	Register("webhook", func(target *config.SyndicationTarget, client *http.Client) (Syndicator, error) {
+		return NewWebhookSyndicator(target.Webhook, client), nil
+	})
+}
+
+// Target is a configured syndication destination.
+type Target struct {
+	Uid        string
+	Name       string
+	Service    config.SyndicationService
+	Syndicator Syndicator
+}
+
+// Dispatcher sends posts to the configured syndication targets and records the resulting URLs in
+// the post's syndication property.
+type Dispatcher struct {
+	targets []*Target
+	store   content.ContentStore
+	jobs    *jobs.Runner
+}
+
+// NewDispatcher builds the configured targets and registers the syndication job with runner.
+func NewDispatcher(cfg *config.Syndication, store content.ContentStore, runner *jobs.Runner) (*Dispatcher, error) {
+	client := &http.Client{Timeout: requestTimeout}
+	d := &Dispatcher{store: store, jobs: runner}
+	runner.Register(JobKind, d.runJob)
+	runner.Register(RecordJobKind, d.runRecordJob)
+
+	for i := range cfg.Targets {
+		target := &cfg.Targets[i]
+
+		mu.RLock()
+		factory, ok := registry[target.Type]
+		mu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown syndication target type %q", target.Type)
+		}
+
+		s, err := factory(target, client)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up syndication target %q: %w", target.Uid, err)
+		}
+
+		d.AddTarget(&Target{Uid: target.Uid, Name: target.Name, Service: target.Service, Syndicator: s})
+	}
+
+	return d, nil
+}
+
+// AddTarget registers an additional target.
+func (d *Dispatcher) AddTarget(target *Target) {
+	d.targets = append(d.targets, target)
+}
+
+// Targets returns the targets in configuration order. A nil dispatcher has no targets.
+func (d *Dispatcher) Targets() []*Target {
+	if d == nil {
+		return nil
+	}
+
+	return d.targets
+}
+
+// Target returns the target with the given uid, or nil.
+func (d *Dispatcher) Target(uid string) *Target {
+	if d == nil {
+		return nil
+	}
+
+	for _, t := range d.targets {
+		if t.Uid == uid {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// post publishes the document to one target and returns the URL of the copy, if it reported one.
+func (d *Dispatcher) post(ctx context.Context, url string, doc util.Mf2Document, uid string) (string, error) {
+	target := d.Target(uid)
+	if target == nil {
+		return "", fmt.Errorf("unknown syndication target %q", uid)
+	}
+
+	syndicated, err := target.Syndicator.Syndicate(ctx, url, doc)
+	if err != nil {
+		return "", fmt.Errorf("syndication to %q failed: %w", uid, err)
+	}
+
+	return syndicated, nil
+}
+
+// record adds the URLs of syndicated copies to the stored post, skipping those it already lists.
+func (d *Dispatcher) record(ctx context.Context, url string, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	doc, err := d.store.Get(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to record syndication urls: %w", err)
+	}
+
+	var additions []any
+	for _, u := range urls {
+		if !slices.Contains(doc.Properties["syndication"], any(u)) {
+			additions = append(additions, u)
+		}
+	}
+	if len(additions) == 0 {
+		return nil
+	}
+
+	if _, err := d.store.Update(ctx, url, nil, map[string][]any{"syndication": additions}, nil); err != nil {
+		return fmt.Errorf("failed to record syndication urls: %w", err)
+	}
+
+	return nil
+}
+
+// JobKind is the job kind used to syndicate a post to a single target.
+const JobKind = "syndicate"
+
+// RecordJobKind is the job kind used to add the URL of a syndicated copy to the post. It is a job of
+// its own so that failing to record the URL is retried without posting to the target again.
+const RecordJobKind = "syndicate-record"
+
+type jobPayload struct {
+	Url    string `json:"url"`
+	Target string `json:"target"`
+}
+
+type recordPayload struct {
+	Url        string `json:"url"`
+	Syndicated string `json:"syndicated"`
+}
+
+// Dispatch queues one job per target so the micropub response is not held up by third parties, and
+// a failing target is retried without posting to the others again.
+func (d *Dispatcher) Dispatch(url string, uids []string) error {
+	if d == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, uid := range uids {
+		if _, err := d.jobs.Enqueue(JobKind, jobPayload{Url: url, Target: uid}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to queue syndication to %q: %w", uid, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) runJob(ctx context.Context, job *jobs.Job) error {
+	var payload jobPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	if d.Target(payload.Target) == nil {
+		return jobs.Permanent(fmt.Errorf("syndication target %q is no longer configured", payload.Target))
+	}
+
+	doc, err := d.store.Get(ctx, payload.Url)
+	if errors.Is(err, content.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+
+	syndicated, err := d.post(ctx, payload.Url, *doc, payload.Target)
+	if err != nil || syndicated == "" {
+		return err
+	}
+	log.Printf("syndicated %q to %s", payload.Url, syndicated)
+
+	// From here on the copy exists, so nothing may fail this job and have it post again.
+	if _, err := d.jobs.Enqueue(RecordJobKind, recordPayload{Url: payload.Url, Syndicated: syndicated}); err != nil {
+		log.Printf("error: failed to queue recording syndication url %s for %q: %v", syndicated, payload.Url, err)
+	}
+
+	return nil
+}
+
+func (d *Dispatcher) runRecordJob(ctx context.Context, job *jobs.Job) error {
+	var payload recordPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	err := d.record(ctx, payload.Url, []string{payload.Syndicated})
+	if errors.Is(err, content.ErrNotFound) {
+		return jobs.Permanent(err)
+	}
+
+	return err
+}
@@ -0,0 +1,162 @@
+package syndication
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+type fakeSyndicator struct {
+	url string
+	err error
+}
+
+func (fs *fakeSyndicator) Syndicate(context.Context, string, util.Mf2Document) (string, error) {
+	return fs.url, fs.err
+}
+
+type updateRecorder struct {
+	content.ContentStore
+	url       string
+	additions map[string][]any
+}
+
+func (ur *updateRecorder) Get(_ context.Context, url string) (*util.Mf2Document, error) {
+	if url != "https://example.org/post" {
+		return nil, content.ErrNotFound
+	}
+	return &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"hi"}}}, nil
+}
+
+func (ur *updateRecorder) Update(_ context.Context, url string, _ map[string][]any, additions map[string][]any, _ any) (string, error) {
+	ur.url = url
+	ur.additions = additions
+	return url, nil
+}
+
+func TestNewDispatcher_UnknownType(t *testing.T) {
+	_, err := NewDispatcher(&config.Syndication{Targets: []config.SyndicationTarget{{Uid: "x", Type: "carrier-pigeon"}}}, nil, newRunner(t))
+	if err == nil {
+		t.Fatalf("expected error for unknown target type")
+	}
+}
+
+func TestDispatcher_NilIsEmpty(t *testing.T) {
+	var d *Dispatcher
+	if d.Target("a") != nil || len(d.Targets()) != 0 {
+		t.Fatalf("expected nil dispatcher to have no targets")
+	}
+	if err := d.Dispatch("https://example.org/post", []string{"a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDispatcher_Job(t *testing.T) {
+	store := &updateRecorder{}
+	runner := newRunner(t)
+	d, _ := NewDispatcher(&config.Syndication{}, store, runner)
+	d.AddTarget(&Target{Uid: "a", Syndicator: &fakeSyndicator{url: "https://a.example/1"}})
+
+	if err := d.Dispatch("https://example.org/post", []string{"a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pending := runner.Pending()
+	if len(pending) != 1 {
+		t.Fatalf("expected one job, got %d", len(pending))
+	}
+
+	if err := d.runJob(context.Background(), &pending[0]); err != nil {
+		t.Fatalf("unexpected job error: %v", err)
+	}
+	record := runner.Pending()
+	if len(record) != 2 || record[1].Kind != RecordJobKind {
+		t.Fatalf("expected a job recording the syndication url, got %+v", record)
+	}
+	if err := d.runRecordJob(context.Background(), &record[1]); err != nil {
+		t.Fatalf("unexpected record job error: %v", err)
+	}
+	if len(store.additions["syndication"]) != 1 {
+		t.Fatalf("expected syndication url to be recorded, got %v", store.additions)
+	}
+
+	gone := pending[0]
+	gone.Payload = []byte(`{"url":"https://example.org/gone","target":"a"}`)
+	if err := d.runJob(context.Background(), &gone); !jobs.IsPermanent(err) || !errors.Is(err, content.ErrNotFound) {
+		t.Fatalf("expected permanent not-found error, got %v", err)
+	}
+}
+
+type countingSyndicator struct {
+	calls int
+}
+
+func (cs *countingSyndicator) Syndicate(context.Context, string, util.Mf2Document) (string, error) {
+	cs.calls++
+	return "https://a.example/1", nil
+}
+
+type failingUpdates struct {
+	updateRecorder
+}
+
+func (fu *failingUpdates) Update(context.Context, string, map[string][]any, map[string][]any, any) (string, error) {
+	return "", errors.New("push rejected")
+}
+
+func TestDispatcher_JobDoesNotRepostWhenRecordingFails(t *testing.T) {
+	runner := newRunner(t)
+	d, _ := NewDispatcher(&config.Syndication{}, &failingUpdates{}, runner)
+	target := &countingSyndicator{}
+	d.AddTarget(&Target{Uid: "a", Syndicator: target})
+
+	if err := d.Dispatch("https://example.org/post", []string{"a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	job := runner.Pending()[0]
+	if err := d.runJob(context.Background(), &job); err != nil {
+		t.Fatalf("expected posting to succeed, got %v", err)
+	}
+
+	record := runner.Pending()[1]
+	if err := d.runRecordJob(context.Background(), &record); err == nil || jobs.IsPermanent(err) {
+		t.Fatalf("expected a retryable error recording the url, got %v", err)
+	}
+	if target.calls != 1 {
+		t.Fatalf("expected the target to be posted to once, got %d", target.calls)
+	}
+}
+
+func newRunner(t *testing.T) *jobs.Runner {
+	runner, err := jobs.NewRunner(&config.Jobs{})
+	if err != nil {
+		t.Fatalf("failed to create job runner: %v", err)
+	}
+	return runner
+}
+
+func TestComposeText(t *testing.T) {
+	note := util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"Hello world"}}}
+	if got := composeText("https://example.org/n", note, 500, 23); got != "Hello world" {
+		t.Fatalf("unexpected note text %q", got)
+	}
+
+	long := util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {strings.Repeat("word ", 200)}}}
+	got := composeText("https://example.org/l", long, 500, 23)
+	if !strings.HasSuffix(got, "…\n\nhttps://example.org/l") {
+		t.Fatalf("expected truncated text with link, got %q", got)
+	}
+	if n := len([]rune(strings.TrimSuffix(got, "https://example.org/l"))); n > 500-23 {
+		t.Fatalf("text too long: %d", n)
+	}
+
+	article := util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"name": {"My Title"}, "content": {"Body text"}}}
+	if got := composeText("https://example.org/a", article, 500, 23); got != "My Title\n\nhttps://example.org/a" {
+		t.Fatalf("unexpected article text %q", got)
+	}
+}
@@ -0,0 +1,59 @@
+package syndication
+
+import (
+	"strings"
+
+	"github.com/indieinfra/scribble/server/util"
+)
+
+const maxTextWords = 2_000
+
+// composeText renders a plain-text status for a post: notes are posted as their text, articles as
+// their title. The permalink is appended to articles and to any text that had to be shortened to fit
+// into limit characters. linkLength is how many characters the service counts for a link.
+func composeText(url string, doc util.Mf2Document, limit int, linkLength int) string {
+	text := util.PropertyText(doc.Properties["content"], maxTextWords)
+	if text == "" {
+		text = util.PropertyText(doc.Properties["summary"], maxTextWords)
+	}
+
+	withLink := false
+	if util.PostType(doc) == "article" {
+		text = util.PropertyText(doc.Properties["name"], maxTextWords)
+		withLink = true
+	}
+
+	if url == "" {
+		return truncate(text, limit)
+	}
+
+	if !withLink && len([]rune(text)) <= limit {
+		return text
+	}
+
+	// Reserve room for the separator and the link.
+	room := limit - linkLength - 2
+	if text == "" {
+		return url
+	}
+
+	return truncate(text, room) + "\n\n" + url
+}
+
+// truncate shortens text to at most limit characters, cutting at a word boundary and adding an ellipsis.
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	if limit <= 1 {
+		return "…"
+	}
+
+	cut := string(runes[:limit-1])
+	if i := strings.LastIndexAny(cut, " \n\t"); i > 0 {
+		cut = cut[:i]
+	}
+
+	return strings.TrimRight(cut, " \n\t.,;:") + "…"
+}
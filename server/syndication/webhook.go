@@ -0,0 +1,86 @@
+package syndication
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body when a webhook secret is set.
+const SignatureHeader = "X-Scribble-Signature"
+
+// IdempotencyHeader carries the post's URL, which stays the same when a delivery is retried, so the
+// receiver can recognize a post it has already syndicated.
+const IdempotencyHeader = "Idempotency-Key"
+
+// WebhookSyndicator posts the full post as JSON to an arbitrary URL. The receiver may report the
+// URL of its copy in a JSON "url" field or in the Location header.
+type WebhookSyndicator struct {
+	cfg    *config.WebhookSyndication
+	client *http.Client
+}
+
+func NewWebhookSyndicator(cfg *config.WebhookSyndication, client *http.Client) *WebhookSyndicator {
+	return &WebhookSyndicator{cfg: cfg, client: client}
+}
+
+type webhookPayload struct {
+	Url        string           `json:"url"`
+	Type       []string         `json:"type"`
+	Properties map[string][]any `json:"properties"`
+}
+
+func (ws *WebhookSyndicator) Syndicate(ctx context.Context, url string, doc util.Mf2Document) (string, error) {
+	body, err := json.Marshal(webhookPayload{Url: url, Type: doc.Type, Properties: doc.Properties})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ws.cfg.Url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(IdempotencyHeader, url)
+	if ws.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(ws.cfg.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	res, err := ws.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", fmt.Errorf("webhook responded with %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var reply struct {
+		Url string `json:"url"`
+	}
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/json") {
+		_ = json.NewDecoder(res.Body).Decode(&reply)
+	}
+
+	if reply.Url != "" {
+		return reply.Url, nil
+	}
+
+	return res.Header.Get("Location"), nil
+}
@@ -0,0 +1,58 @@
+package syndication
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func TestWebhookSyndicator_SignsAndReadsLocation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(body)
+		if sig := r.Header.Get(SignatureHeader); sig != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("unexpected signature %q", sig)
+		}
+		if key := r.Header.Get(IdempotencyHeader); key != "https://example.org/post" {
+			t.Errorf("unexpected idempotency key %q", key)
+		}
+		w.Header().Set("Location", "https://hook.example/copy/1")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	ws := NewWebhookSyndicator(&config.WebhookSyndication{Url: srv.URL, Secret: "s3cret"}, srv.Client())
+	url, err := ws.Syndicate(context.Background(), "https://example.org/post", util.Mf2Document{Type: []string{"h-entry"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if url != "https://hook.example/copy/1" {
+		t.Fatalf("unexpected url %q", url)
+	}
+}
+
+func TestWebhookSyndicator_JsonUrl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(SignatureHeader) != "" {
+			t.Errorf("expected no signature without a secret")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"url":"https://hook.example/copy/2"}`))
+	}))
+	defer srv.Close()
+
+	ws := NewWebhookSyndicator(&config.WebhookSyndication{Url: srv.URL}, srv.Client())
+	url, err := ws.Syndicate(context.Background(), "https://example.org/post", util.Mf2Document{})
+	if err != nil || url != "https://hook.example/copy/2" {
+		t.Fatalf("unexpected result %q, %v", url, err)
+	}
+}
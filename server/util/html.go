@@ -97,3 +97,39 @@ func removeControlCharacters(text string) string {
 		return r
 	}, text)
 }
+
+// HtmlLinks returns the absolute http(s) URLs linked from <a href> elements in the HTML fragment,
+// in document order and without duplicates.
+func HtmlLinks(input string) []string {
+	doc, err := html.Parse(strings.NewReader(input))
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	seen := map[string]bool{}
+
+	var traverse func(*html.Node)
+	traverse = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			for _, attr := range n.Attr {
+				if attr.Key != "href" {
+					continue
+				}
+
+				href := strings.TrimSpace(attr.Val)
+				if (strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://")) && !seen[href] {
+					seen[href] = true
+					links = append(links, href)
+				}
+			}
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			traverse(c)
+		}
+	}
+	traverse(doc)
+
+	return links
+}
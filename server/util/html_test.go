@@ -172,3 +172,15 @@ func FuzzHtmlToText(f *testing.F) {
 		}
 	})
 }
+
+func TestHtmlLinks(t *testing.T) {
+	input := `<p>See <a href="https://a.example/1">this</a>, <a href="/relative">that</a>,
+		<a href=" https://b.example/2 ">another</a> and <a href="https://a.example/1">this again</a>.</p>
+		<a href="mailto:me@example.org">mail</a>`
+
+	got := HtmlLinks(input)
+	want := []string{"https://a.example/1", "https://b.example/2"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,433 @@
+package util
+
+import (
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Microformat is an item parsed from HTML by ParseMicroformats. Properties hold strings for plain
+// values, {"html", "value"} maps for e-* properties and {"type", "properties", "value"} maps for
+// nested items, matching the JSON shape used by micropub.
+type Microformat struct {
+	Type       []string
+	Properties map[string][]any
+	// Children are nested items that are not the value of a property, e.g. the entries of an h-feed.
+	Children []*Microformat
+}
+
+// Document returns the item without its children.
+func (m *Microformat) Document() Mf2Document {
+	return Mf2Document{Type: m.Type, Properties: m.Properties}
+}
+
+// Find returns the first item of the given type in document order, searching children too.
+func Find(items []*Microformat, typ string) *Microformat {
+	for _, item := range items {
+		if slices.Contains(item.Type, typ) {
+			return item
+		}
+		if found := Find(item.Children, typ); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// ParseMicroformats extracts the top-level microformats2 items from an HTML document. It implements
+// the commonly used parts of the parsing spec (http://microformats.org/wiki/microformats2-parsing):
+// root and property classes, nested items and implied name, url and photo. The value-class pattern
+// and backcompat classes are not supported. Relative URLs are resolved against base.
+func ParseMicroformats(r io.Reader, base *url.URL) ([]*Microformat, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := mfParser{base: base}
+	if b := findBase(doc); b != "" && base != nil {
+		if ref, err := url.Parse(b); err == nil {
+			p.base = base.ResolveReference(ref)
+		}
+	}
+
+	return p.findRoots(doc), nil
+}
+
+type mfParser struct {
+	base *url.URL
+}
+
+func (p mfParser) findRoots(n *html.Node) []*Microformat {
+	var items []*Microformat
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+
+		if types := rootClasses(c); len(types) > 0 {
+			items = append(items, p.parseItem(c, types))
+			continue
+		}
+
+		items = append(items, p.findRoots(c)...)
+	}
+
+	return items
+}
+
+func (p mfParser) parseItem(n *html.Node, types []string) *Microformat {
+	item := &Microformat{Type: types, Properties: map[string][]any{}}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.walkProperties(c, item)
+	}
+
+	p.implyProperties(n, item)
+
+	return item
+}
+
+// walkProperties collects the properties of item from n and its descendants, stopping at nested items.
+func (p mfParser) walkProperties(n *html.Node, item *Microformat) {
+	if n.Type != html.ElementNode {
+		return
+	}
+
+	props := propertyClasses(n)
+	nestedTypes := rootClasses(n)
+
+	if len(nestedTypes) > 0 {
+		nested := p.parseItem(n, nestedTypes)
+		if len(props) == 0 {
+			item.Children = append(item.Children, nested)
+			return
+		}
+
+		for _, prop := range props {
+			value := map[string]any{
+				"type":       anySlice(nested.Type),
+				"properties": propertiesMap(nested.Properties),
+				"value":      p.embeddedValue(n, prop, nested),
+			}
+			item.Properties[prop.name] = append(item.Properties[prop.name], value)
+		}
+		return
+	}
+
+	for _, prop := range props {
+		item.Properties[prop.name] = append(item.Properties[prop.name], p.propertyValue(n, prop.prefix))
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		p.walkProperties(c, item)
+	}
+}
+
+func (p mfParser) propertyValue(n *html.Node, prefix string) any {
+	switch prefix {
+	case "u":
+		if link := p.urlAttribute(n); link != "" {
+			return link
+		}
+		if v := valueAttribute(n); v != "" {
+			return v
+		}
+		return textContent(n)
+	case "dt":
+		switch n.Data {
+		case "time", "ins", "del":
+			if v := attr(n, "datetime"); v != "" {
+				return v
+			}
+		case "abbr":
+			if v := attr(n, "title"); v != "" {
+				return v
+			}
+		case "data", "input":
+			if v := attr(n, "value"); v != "" {
+				return v
+			}
+		}
+		return textContent(n)
+	case "e":
+		return map[string]any{"html": innerHtml(n), "value": textContent(n)}
+	default:
+		if v := valueAttribute(n); v != "" {
+			return v
+		}
+		return textContent(n)
+	}
+}
+
+// embeddedValue is the "value" of a nested item: its url for u-* properties, its name otherwise.
+func (p mfParser) embeddedValue(n *html.Node, prop propertyClass, nested *Microformat) any {
+	key := "name"
+	if prop.prefix == "u" {
+		key = "url"
+	}
+
+	if vals := nested.Properties[key]; len(vals) > 0 {
+		if s, ok := vals[0].(string); ok {
+			return s
+		}
+	}
+
+	return p.propertyValue(n, prop.prefix)
+}
+
+func (p mfParser) implyProperties(n *html.Node, item *Microformat) {
+	hasPlainOrEmbedded := false
+	hasNested := len(item.Children) > 0
+	for c := n.FirstChild; c != nil && !hasPlainOrEmbedded; c = c.NextSibling {
+		hasPlainOrEmbedded = hasPropertyPrefix(c, "p", "e")
+	}
+	for _, vals := range item.Properties {
+		for _, v := range vals {
+			if m, ok := v.(map[string]any); ok && m["type"] != nil {
+				hasNested = true
+			}
+		}
+	}
+
+	if _, ok := item.Properties["name"]; !ok && !hasPlainOrEmbedded && !hasNested {
+		name := ""
+		switch {
+		case (n.Data == "img" || n.Data == "area") && attr(n, "alt") != "":
+			name = attr(n, "alt")
+		case n.Data == "abbr" && attr(n, "title") != "":
+			name = attr(n, "title")
+		default:
+			if img := onlyChild(n, "img"); img != nil && attr(img, "alt") != "" {
+				name = attr(img, "alt")
+			} else {
+				name = textContent(n)
+			}
+		}
+		item.Properties["name"] = []any{name}
+	}
+
+	if _, ok := item.Properties["photo"]; !ok {
+		if src := p.impliedAttribute(n, "img", "src"); src != "" {
+			item.Properties["photo"] = []any{src}
+		}
+	}
+
+	if _, ok := item.Properties["url"]; !ok {
+		if href := p.impliedAttribute(n, "a", "href"); href != "" {
+			item.Properties["url"] = []any{href}
+		}
+	}
+}
+
+// impliedAttribute resolves an implied url or photo from the root element or its only child of the
+// given element type.
+func (p mfParser) impliedAttribute(n *html.Node, element string, key string) string {
+	if n.Data == element {
+		return p.resolve(attr(n, key))
+	}
+
+	if child := onlyChild(n, element); child != nil && len(rootClasses(child)) == 0 {
+		return p.resolve(attr(child, key))
+	}
+
+	return ""
+}
+
+func (p mfParser) urlAttribute(n *html.Node) string {
+	var key string
+	switch n.Data {
+	case "a", "area", "link":
+		key = "href"
+	case "img", "audio", "video", "source", "iframe":
+		key = "src"
+	case "object":
+		key = "data"
+	default:
+		return ""
+	}
+
+	if n.Data == "video" && attr(n, "src") == "" {
+		key = "poster"
+	}
+
+	return p.resolve(attr(n, key))
+}
+
+func (p mfParser) resolve(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || p.base == nil {
+		return raw
+	}
+
+	ref, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+
+	return p.base.ResolveReference(ref).String()
+}
+
+type propertyClass struct {
+	prefix string
+	name   string
+}
+
+func rootClasses(n *html.Node) []string {
+	var out []string
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if strings.HasPrefix(c, "h-") && len(c) > 2 && !slices.Contains(out, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func propertyClasses(n *html.Node) []propertyClass {
+	var out []propertyClass
+	for _, c := range strings.Fields(attr(n, "class")) {
+		prefix, name, ok := strings.Cut(c, "-")
+		if !ok || name == "" {
+			continue
+		}
+
+		switch prefix {
+		case "p", "u", "dt", "e":
+			pc := propertyClass{prefix: prefix, name: name}
+			if !slices.Contains(out, pc) {
+				out = append(out, pc)
+			}
+		}
+	}
+	return out
+}
+
+// hasPropertyPrefix reports whether n or a descendant (outside nested items) has a property class
+// with one of the given prefixes.
+func hasPropertyPrefix(n *html.Node, prefixes ...string) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+
+	for _, pc := range propertyClasses(n) {
+		if slices.Contains(prefixes, pc.prefix) {
+			return true
+		}
+	}
+
+	if len(rootClasses(n)) > 0 {
+		return false
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if hasPropertyPrefix(c, prefixes...) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func onlyChild(n *html.Node, element string) *html.Node {
+	var only *html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if only != nil {
+			return nil
+		}
+		only = c
+	}
+
+	if only != nil && only.Data == element {
+		return only
+	}
+
+	return nil
+}
+
+func valueAttribute(n *html.Node) string {
+	switch n.Data {
+	case "abbr", "link":
+		return attr(n, "title")
+	case "data", "input":
+		return attr(n, "value")
+	case "img", "area":
+		return attr(n, "alt")
+	}
+	return ""
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func findBase(n *html.Node) string {
+	if n.Type == html.ElementNode && n.Data == "base" {
+		return attr(n, "href")
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href := findBase(c); href != "" {
+			return href
+		}
+	}
+
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+		case n.Type == html.ElementNode && (n.Data == "script" || n.Data == "style"):
+			return
+		case n.Type == html.ElementNode && n.Data == "img":
+			b.WriteString(attr(n, "alt"))
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func innerHtml(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		_ = html.Render(&b, c)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func anySlice(s []string) []any {
+	out := make([]any, 0, len(s))
+	for _, v := range s {
+		out = append(out, v)
+	}
+	return out
+}
+
+func propertiesMap(props map[string][]any) map[string]any {
+	out := make(map[string]any, len(props))
+	for k, v := range props {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseMicroformats(t *testing.T) {
+	page := `<html><body>
+	<div class="h-feed">
+	  <article class="h-entry">
+	    <a class="p-author h-card" href="/about"><img src="/me.jpg" alt="Alice"></a>
+	    <a class="u-in-reply-to" href="https://example.org/post">in reply to</a>
+	    <div class="e-content"><p>Great <b>post</b>!</p></div>
+	    <time class="dt-published" datetime="2025-01-02T03:04:05Z">Jan 2</time>
+	    <a class="u-url" href="/replies/1">permalink</a>
+	  </article>
+	</div>
+	<div class="h-card"><a href="https://bob.example/">Bob</a></div>
+	</body></html>`
+
+	base, _ := url.Parse("https://alice.example/feed")
+	items, err := ParseMicroformats(strings.NewReader(page), base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 top-level items, got %d", len(items))
+	}
+
+	entry := Find(items, "h-entry")
+	if entry == nil {
+		t.Fatalf("expected to find the h-entry inside the feed")
+	}
+
+	props := entry.Properties
+	if props["in-reply-to"][0] != "https://example.org/post" {
+		t.Fatalf("unexpected in-reply-to %v", props["in-reply-to"])
+	}
+	if props["url"][0] != "https://alice.example/replies/1" {
+		t.Fatalf("unexpected url %v", props["url"])
+	}
+	if props["published"][0] != "2025-01-02T03:04:05Z" {
+		t.Fatalf("unexpected published %v", props["published"])
+	}
+	content := props["content"][0].(map[string]any)
+	if content["html"] != "<p>Great <b>post</b>!</p>" || content["value"] != "Great post!" {
+		t.Fatalf("unexpected content %v", content)
+	}
+	if _, ok := props["name"]; ok {
+		t.Fatalf("expected no implied name when e-* properties exist, got %v", props["name"])
+	}
+
+	author := props["author"][0].(map[string]any)
+	authorProps := author["properties"].(map[string]any)
+	if author["value"] != "Alice" || authorProps["url"].([]any)[0] != "https://alice.example/about" || authorProps["photo"].([]any)[0] != "https://alice.example/me.jpg" {
+		t.Fatalf("unexpected author %v", author)
+	}
+
+	card := items[1]
+	if card.Properties["name"][0] != "Bob" || card.Properties["url"][0] != "https://bob.example/" {
+		t.Fatalf("unexpected implied card properties %v", card.Properties)
+	}
+}
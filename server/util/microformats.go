@@ -3,6 +3,7 @@ package util
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 type Mf2Document struct {
@@ -49,3 +50,66 @@ func ValidateMf2(doc Mf2Document) error {
 
 	return nil
 }
+
+// PropertyText returns the plain text of every value of a property joined by spaces, limited to the
+// given number of words. HTML content is converted to text and embedded microformats contribute
+// their name or value.
+func PropertyText(values []any, words int) string {
+	var parts []string
+	for _, v := range values {
+		if text := valueText(v, words); text != "" {
+			parts = append(parts, text)
+		}
+	}
+
+	return truncateWords(strings.Join(parts, " "), words)
+}
+
+func valueText(v any, words int) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case Mf2Document:
+		return PropertyText(x.Properties["name"], words)
+	case map[string]any:
+		if h, ok := x["html"]; ok {
+			if s, ok := firstText(h); ok {
+				return HtmlToText(s, words)
+			}
+		}
+		if val, ok := x["value"]; ok {
+			if s, ok := firstText(val); ok {
+				return s
+			}
+		}
+		if props, ok := x["properties"].(map[string]any); ok {
+			if name, ok := props["name"]; ok {
+				if s, ok := firstText(name); ok {
+					return s
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// firstText returns the string itself, or the first string of a slice.
+func firstText(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case []any:
+		for _, e := range x {
+			if s, ok := e.(string); ok {
+				return s, true
+			}
+		}
+	case []string:
+		if len(x) > 0 {
+			return x[0], true
+		}
+	}
+
+	return "", false
+}
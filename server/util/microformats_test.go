@@ -43,3 +43,21 @@ func TestValidateMf2Embedded(t *testing.T) {
 		t.Fatalf("expected invalid embedded mf2 to be rejected")
 	}
 }
+
+func TestPropertyText(t *testing.T) {
+	values := []any{
+		"plain words",
+		map[string]any{"html": []any{"<p>Some <b>html</b></p>"}, "value": []any{"ignored"}},
+		map[string]any{"value": "value only"},
+		map[string]any{"type": []any{"h-card"}, "properties": map[string]any{"name": []any{"Alice"}}},
+		Mf2Document{Type: []string{"h-card"}, Properties: map[string][]any{"name": {"Bob"}}},
+		42,
+	}
+
+	if got := PropertyText(values, 100); got != "plain words Some html value only Alice Bob" {
+		t.Fatalf("unexpected text %q", got)
+	}
+	if got := PropertyText(values, 3); got != "plain words Some" {
+		t.Fatalf("expected word limit to apply, got %q", got)
+	}
+}
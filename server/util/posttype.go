@@ -0,0 +1,61 @@
+package util
+
+import (
+	"slices"
+	"strings"
+)
+
+// responseProperties are checked in order by the Post Type Discovery algorithm; the first
+// property present on the document decides the post type.
+var responseProperties = []struct {
+	property string
+	postType string
+}{
+	{"repost-of", "repost"},
+	{"like-of", "like"},
+	{"in-reply-to", "reply"},
+	{"bookmark-of", "bookmark"},
+	{"quotation-of", "quotation"},
+	{"checkin", "checkin"},
+	{"video", "video"},
+	{"audio", "audio"},
+	{"photo", "photo"},
+}
+
+// PostType implements a variant of the IndieWeb Post Type Discovery algorithm
+// (https://www.w3.org/TR/post-type-discovery/) and returns the implied post type
+// of the document, e.g. "note", "article", "reply" or "photo".
+func PostType(doc Mf2Document) string {
+	if slices.Contains(doc.Type, "h-event") {
+		return "event"
+	}
+
+	if rsvp := strings.ToLower(extractTextFromProperty(doc.Properties["rsvp"])); rsvp != "" {
+		if slices.Contains([]string{"yes", "no", "maybe", "interested"}, rsvp) {
+			return "rsvp"
+		}
+	}
+
+	for _, rp := range responseProperties {
+		if len(doc.Properties[rp.property]) > 0 {
+			return rp.postType
+		}
+	}
+
+	content := extractTextFromProperty(doc.Properties["content"])
+	if content == "" {
+		content = extractTextFromProperty(doc.Properties["summary"])
+	}
+
+	name := strings.Join(strings.Fields(extractTextFromProperty(doc.Properties["name"])), " ")
+	if name == "" {
+		return "note"
+	}
+
+	content = strings.Join(strings.Fields(content), " ")
+	if content != "" && strings.HasPrefix(content, name) {
+		return "note"
+	}
+
+	return "article"
+}
@@ -0,0 +1,28 @@
+package util
+
+import "testing"
+
+func TestPostType(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  Mf2Document
+		want string
+	}{
+		{"event", Mf2Document{Type: []string{"h-event"}, Properties: map[string][]any{}}, "event"},
+		{"rsvp", Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"rsvp": {"Yes"}, "in-reply-to": {"https://e.org"}}}, "rsvp"},
+		{"like", Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"like-of": {"https://e.org"}}}, "like"},
+		{"reply", Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"in-reply-to": {"https://e.org"}, "content": {"hi"}}}, "reply"},
+		{"photo", Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"photo": {"https://e.org/p.jpg"}}}, "photo"},
+		{"note without name", Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"just a note"}}}, "note"},
+		{"note with name prefix", Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"name": {"just a"}, "content": {"just a   note"}}}, "note"},
+		{"article", Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"name": {"Title"}, "content": {map[string]any{"html": "<p>Body</p>"}}}}, "article"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := PostType(tc.doc); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
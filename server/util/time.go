@@ -0,0 +1,47 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeLayouts are the date formats commonly sent by Micropub clients for dt-* properties.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseTime parses a microformats date-time string. Values without a timezone are interpreted as UTC.
+func ParseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized date-time %q", s)
+}
+
+// PropertyTime returns the first value of the given property parsed as a date-time, if any.
+func PropertyTime(doc Mf2Document, property string) (time.Time, bool) {
+	for _, v := range doc.Properties[property] {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		if t, err := ParseTime(s); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	want := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	for _, in := range []string{"2024-05-01T10:30:00Z", "2024-05-01T12:30:00+02:00", "2024-05-01T10:30:00+0000", "2024-05-01 10:30:00", "2024-05-01T10:30"} {
+		got, err := ParseTime(in)
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", in, err)
+		}
+		if !got.Equal(want) {
+			t.Fatalf("parsed %q as %v, expected %v", in, got, want)
+		}
+	}
+
+	if _, err := ParseTime("yesterday"); err == nil {
+		t.Fatalf("expected unparseable date to error")
+	}
+}
+
+func TestPropertyTime(t *testing.T) {
+	doc := Mf2Document{Properties: map[string][]any{"published": {123, "2024-05-01"}}}
+	got, ok := PropertyTime(doc, "published")
+	if !ok || got.Year() != 2024 {
+		t.Fatalf("expected published to parse, got %v %v", got, ok)
+	}
+
+	if _, ok := PropertyTime(doc, "updated"); ok {
+		t.Fatalf("expected missing property to report false")
+	}
+}
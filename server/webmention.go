@@ -0,0 +1,34 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/state"
+	"github.com/indieinfra/scribble/server/webmention"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+func initializeWebmention(st *state.ScribbleState) error {
+	cfg := &st.Cfg.Webmention
+
+	if cfg.Send {
+		status, err := webmention.NewStatusLog(cfg.StatusPath)
+		if err != nil {
+			return err
+		}
+
+		st.Hooks.Register(hooks.Public(webmention.NewSender(st.Jobs, status, nil)))
+	}
+
+	if cfg.Receive {
+		mentions, ok := st.ContentStore.(content.MentionStore)
+		if !ok {
+			return fmt.Errorf("content strategy %q cannot store received webmentions", st.Cfg.Content.Strategy)
+		}
+
+		st.Mentions = webmention.NewReceiver(st.ContentStore, mentions, st.Jobs, nil, cfg.Moderate)
+	}
+
+	return nil
+}
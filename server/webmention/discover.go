@@ -0,0 +1,134 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxDiscoveryBody caps how much of a target page is read while looking for its endpoint.
+const maxDiscoveryBody = 1 << 20
+
+// ErrNoEndpoint is returned by Discover when the target does not advertise a webmention endpoint.
+var ErrNoEndpoint = errors.New("no webmention endpoint")
+
+var (
+	linkValuePattern = regexp.MustCompile(`<([^>]*)>([^<]*)`)
+	relParamPattern  = regexp.MustCompile(`(?i);\s*rel\s*=\s*(?:"([^"]*)"|([^\s";,]+))`)
+)
+
+// Discover finds the webmention endpoint of target, following the discovery rules of the
+// Webmention spec: the first Link header with rel=webmention wins, then the first <link> or <a>
+// element with rel=webmention in the HTML. Relative endpoints are resolved against the final URL
+// after redirects.
+func Discover(ctx context.Context, client *http.Client, target string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return "", &StatusError{Code: res.StatusCode}
+	}
+
+	base := res.Request.URL
+
+	for _, header := range res.Header.Values("Link") {
+		if endpoint, ok := endpointFromLinkHeader(header); ok {
+			return resolve(base, endpoint)
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", ErrNoEndpoint
+	}
+
+	doc, err := html.Parse(io.LimitReader(res.Body, maxDiscoveryBody))
+	if err != nil {
+		return "", err
+	}
+
+	if endpoint, ok := endpointFromHtml(doc); ok {
+		return resolve(base, endpoint)
+	}
+
+	return "", ErrNoEndpoint
+}
+
+// StatusError reports an unexpected HTTP status from a remote server.
+type StatusError struct {
+	Code int
+}
+
+func (se *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d %s", se.Code, http.StatusText(se.Code))
+}
+
+func endpointFromLinkHeader(header string) (string, bool) {
+	for _, m := range linkValuePattern.FindAllStringSubmatch(header, -1) {
+		for _, rel := range relParamPattern.FindAllStringSubmatch(m[2], -1) {
+			if hasRel(rel[1] + rel[2]) {
+				return m[1], true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func endpointFromHtml(n *html.Node) (string, bool) {
+	if n.Type == html.ElementNode && (n.Data == "link" || n.Data == "a") {
+		var href, rel string
+		hasHref := false
+		for _, attr := range n.Attr {
+			switch attr.Key {
+			case "href":
+				href, hasHref = attr.Val, true
+			case "rel":
+				rel = attr.Val
+			}
+		}
+
+		if hasHref && hasRel(rel) {
+			return href, true
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if endpoint, ok := endpointFromHtml(c); ok {
+			return endpoint, true
+		}
+	}
+
+	return "", false
+}
+
+func hasRel(rel string) bool {
+	return slices.Contains(strings.Fields(strings.ToLower(rel)), "webmention")
+}
+
+func resolve(base *url.URL, endpoint string) (string, error) {
+	ref, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return "", err
+	}
+
+	return base.ResolveReference(ref).String(), nil
+}
@@ -0,0 +1,70 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/header", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `<https://other.example/>; rel="me", </wm/header>; rel="webmention"`)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<link rel="webmention" href="/wm/html">`))
+	})
+	mux.HandleFunc("/multi-rel", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `<https://wm.example/endpoint?x=1>; rel="webmention somethingelse"`)
+	})
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(`<html><head><link rel="stylesheet" href="/s.css"></head>
+			<body><a rel="webmention" href="wm/relative">wm</a><link rel="webmention" href="/wm/later"></body></html>`))
+	})
+	mux.HandleFunc("/empty-href", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<link rel="webmention" href="">`))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/sub/html", http.StatusFound)
+	})
+	mux.HandleFunc("/sub/html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<link rel="webmention" href="endpoint">`))
+	})
+	mux.HandleFunc("/none", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<p>nothing here</p>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/header", srv.URL + "/wm/header"},
+		{"/multi-rel", "https://wm.example/endpoint?x=1"},
+		{"/html", srv.URL + "/wm/relative"},
+		{"/empty-href", srv.URL + "/empty-href"},
+		{"/redirect", srv.URL + "/sub/endpoint"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.path, func(t *testing.T) {
+			got, err := Discover(context.Background(), srv.Client(), srv.URL+tc.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	if _, err := Discover(context.Background(), srv.Client(), srv.URL+"/none"); !errors.Is(err, ErrNoEndpoint) {
+		t.Fatalf("expected ErrNoEndpoint, got %v", err)
+	}
+}
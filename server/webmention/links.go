@@ -0,0 +1,80 @@
+package webmention
+
+import (
+	"github.com/indieinfra/scribble/server/util"
+)
+
+// linkProperties hold the URLs a post responds to. Values are either plain URLs or embedded h-cite
+// objects carrying a url property.
+var linkProperties = []string{"in-reply-to", "like-of", "repost-of", "bookmark-of"}
+
+// Targets returns every URL the document links to that should receive a webmention: the targets of
+// its response properties followed by the links inside its HTML content.
+func Targets(doc *util.Mf2Document) []string {
+	if doc == nil {
+		return nil
+	}
+
+	var out []string
+	seen := map[string]bool{}
+	add := func(u string) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			out = append(out, u)
+		}
+	}
+
+	for _, prop := range linkProperties {
+		for _, val := range doc.Properties[prop] {
+			add(valueUrl(val))
+		}
+	}
+
+	for _, val := range doc.Properties["content"] {
+		m, ok := val.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		for _, link := range util.HtmlLinks(firstString(m["html"])) {
+			add(link)
+		}
+	}
+
+	return out
+}
+
+func valueUrl(val any) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case map[string]any:
+		if props, ok := v["properties"].(map[string]any); ok {
+			return firstString(props["url"])
+		}
+		return firstString(v["value"])
+	case util.Mf2Document:
+		return firstString(v.Properties["url"])
+	}
+
+	return ""
+}
+
+func firstString(val any) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case []any:
+		for _, e := range v {
+			if s, ok := e.(string); ok && s != "" {
+				return s
+			}
+		}
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	}
+
+	return ""
+}
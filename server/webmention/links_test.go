@@ -0,0 +1,31 @@
+package webmention
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func TestTargets(t *testing.T) {
+	doc := &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"in-reply-to": {"https://a.example/post"},
+		"like-of": {map[string]any{
+			"type":       []any{"h-cite"},
+			"properties": map[string]any{"url": []any{"https://b.example/liked"}},
+		}},
+		"content": {map[string]any{
+			"html":  `<p>As <a href="https://c.example/">c</a> said in <a href="https://a.example/post">a</a></p>`,
+			"value": "As c said in a",
+		}},
+	}}
+
+	want := []string{"https://a.example/post", "https://b.example/liked", "https://c.example/"}
+	if got := Targets(doc); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if got := Targets(nil); got != nil {
+		t.Fatalf("expected no targets for nil document, got %v", got)
+	}
+}
@@ -0,0 +1,341 @@
+package webmention
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/publicnet"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+	"golang.org/x/net/html"
+)
+
+// VerifyJobKind is the job kind used to verify a received webmention.
+const VerifyJobKind = "webmention-verify"
+
+// Moderation statuses of received mentions.
+const (
+	MentionPending  = "pending"
+	MentionApproved = "approved"
+	MentionRejected = "rejected"
+)
+
+const (
+	// maxSourceBody caps how much of a source page is read during verification.
+	maxSourceBody = 2 << 20
+	// maxContentWords limits the excerpt of the source content kept with a mention.
+	maxContentWords = 100
+)
+
+// ErrInvalidMention is returned by Accept for requests that can be rejected without fetching the source.
+var ErrInvalidMention = errors.New("invalid webmention")
+
+// Receiver accepts webmentions for the store's documents. Requests are checked synchronously and
+// verified in the background: the source is fetched, checked for a link to the target and parsed for
+// microformats to classify the mention before it is stored.
+type Receiver struct {
+	store    content.ContentStore
+	mentions content.MentionStore
+	jobs     *jobs.Runner
+	client   *http.Client
+	// moderate holds new mentions as pending until they are approved.
+	moderate bool
+	now      func() time.Time
+	lookup   publicnet.Resolver
+}
+
+// NewReceiver registers the verification job with runner. Without a client, sources are fetched with
+// one that only connects to public addresses.
+func NewReceiver(store content.ContentStore, mentions content.MentionStore, runner *jobs.Runner, client *http.Client, moderate bool) *Receiver {
+	if client == nil {
+		client = publicnet.NewClient(requestTimeout)
+	}
+
+	rc := &Receiver{store: store, mentions: mentions, jobs: runner, client: client, moderate: moderate, now: time.Now, lookup: publicnet.LookupHost}
+	runner.Register(VerifyJobKind, rc.runJob)
+
+	return rc
+}
+
+// Accept checks a received webmention and queues its verification. Errors wrapping ErrInvalidMention
+// describe a bad request; a target that is not one of the store's documents yields content.ErrNotFound.
+func (rc *Receiver) Accept(ctx context.Context, source string, target string) error {
+	if !isHttpUrl(source) || !isHttpUrl(target) {
+		return fmt.Errorf("%w: source and target must be http(s) URLs", ErrInvalidMention)
+	}
+	if source == target {
+		return fmt.Errorf("%w: source and target must differ", ErrInvalidMention)
+	}
+	if err := publicnet.CheckUrl(ctx, rc.lookup, source); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMention, err)
+	}
+
+	doc, err := rc.store.Get(ctx, target)
+	if err != nil {
+		return err
+	}
+	if content.IsDeleted(*doc) || content.Visibility(*doc) == content.VisibilityPrivate {
+		return content.ErrNotFound
+	}
+
+	_, err = rc.jobs.Enqueue(VerifyJobKind, jobPayload{Source: source, Target: target})
+	return err
+}
+
+// Moderate changes the moderation status of the mention of target by source.
+func (rc *Receiver) Moderate(ctx context.Context, target string, source string, status string) error {
+	if !slices.Contains([]string{MentionPending, MentionApproved, MentionRejected}, status) {
+		return fmt.Errorf("%w: unknown moderation status %q", ErrInvalidMention, status)
+	}
+
+	mentions, err := rc.mentions.Mentions(ctx, content.MentionFilter{Target: target})
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(mentions, func(m content.Mention) bool { return m.Source == source })
+	if i < 0 {
+		return content.ErrNotFound
+	}
+
+	m := mentions[i]
+	m.Status = status
+	m.Updated = rc.now()
+
+	return rc.mentions.SaveMention(ctx, m)
+}
+
+// Mentions returns the stored mentions matching the filter.
+func (rc *Receiver) Mentions(ctx context.Context, filter content.MentionFilter) ([]content.Mention, error) {
+	return rc.mentions.Mentions(ctx, filter)
+}
+
+// Verify fetches the source and stores, updates or removes the mention of target accordingly.
+func (rc *Receiver) Verify(ctx context.Context, source string, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	req.Header.Set("Accept", "text/html, */*;q=0.5")
+
+	res, err := rc.client.Do(req)
+	if errors.Is(err, publicnet.ErrPrivateAddress) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusGone || res.StatusCode == http.StatusNotFound:
+		// The source was deleted; so is the mention.
+		return rc.remove(ctx, source, target)
+	case res.StatusCode < 200 || res.StatusCode > 299:
+		err := &StatusError{Code: res.StatusCode}
+		if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxSourceBody))
+	if err != nil {
+		return err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	isHtml := mediaType == "text/html" || mediaType == "application/xhtml+xml"
+
+	if !linksTo(body, isHtml, target) {
+		log.Printf("webmention source %q does not link to %q", source, target)
+		return rc.remove(ctx, source, target)
+	}
+
+	m := content.Mention{Source: source, Target: target, Type: "mention", Url: source}
+	if isHtml {
+		items, err := util.ParseMicroformats(bytes.NewReader(body), res.Request.URL)
+		if err == nil {
+			describe(&m, items)
+		}
+	}
+
+	existing, err := rc.mentions.Mentions(ctx, content.MentionFilter{Target: target})
+	if err != nil {
+		if errors.Is(err, content.ErrNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	now := rc.now()
+	m.Received, m.Updated = now, now
+	m.Status = MentionApproved
+	if rc.moderate {
+		m.Status = MentionPending
+	}
+	if i := slices.IndexFunc(existing, func(e content.Mention) bool { return e.Source == source }); i >= 0 {
+		// An updated source keeps its moderation decision.
+		m.Received, m.Status = existing[i].Received, existing[i].Status
+	}
+
+	if err := rc.mentions.SaveMention(ctx, m); err != nil {
+		if errors.Is(err, content.ErrNotFound) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (rc *Receiver) remove(ctx context.Context, source string, target string) error {
+	err := rc.mentions.DeleteMention(ctx, target, source)
+	if errors.Is(err, content.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (rc *Receiver) runJob(ctx context.Context, job *jobs.Job) error {
+	var payload jobPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	if err := rc.Verify(ctx, payload.Source, payload.Target); err != nil {
+		return fmt.Errorf("verify webmention from %q to %q: %w", payload.Source, payload.Target, err)
+	}
+
+	return nil
+}
+
+// mentionTypes maps response properties to the type of mention they make, in order of precedence.
+var mentionTypes = []struct {
+	property string
+	kind     string
+}{
+	{"like-of", "like"},
+	{"repost-of", "repost"},
+	{"bookmark-of", "bookmark"},
+	{"in-reply-to", "reply"},
+}
+
+// describe fills in the type, author and content of the mention from the source's h-entry.
+func describe(m *content.Mention, items []*util.Microformat) {
+	entry := util.Find(items, "h-entry")
+	if entry == nil {
+		if card := util.Find(items, "h-card"); card != nil {
+			m.Author = cardAuthor(card.Properties)
+		}
+		return
+	}
+
+	props := entry.Properties
+	for _, mt := range mentionTypes {
+		if slices.ContainsFunc(props[mt.property], func(v any) bool { return sameUrl(valueUrl(v), m.Target) }) {
+			m.Type = mt.kind
+			break
+		}
+	}
+
+	if u := firstString(props["url"]); u != "" {
+		m.Url = u
+	}
+	m.Published = firstString(props["published"])
+
+	m.Content = util.PropertyText(props["content"], maxContentWords)
+	if m.Content == "" {
+		m.Content = util.PropertyText(props["summary"], maxContentWords)
+	}
+
+	if authors := props["author"]; len(authors) > 0 {
+		switch a := authors[0].(type) {
+		case string:
+			if isHttpUrl(a) {
+				m.Author.Url = a
+			} else {
+				m.Author.Name = a
+			}
+		case map[string]any:
+			if p, ok := a["properties"].(map[string]any); ok {
+				m.Author = cardAuthor(toProperties(p))
+			}
+		}
+	} else if card := util.Find(items, "h-card"); card != nil {
+		m.Author = cardAuthor(card.Properties)
+	}
+}
+
+func cardAuthor(props map[string][]any) content.MentionAuthor {
+	return content.MentionAuthor{
+		Name:  firstString(props["name"]),
+		Url:   firstString(props["url"]),
+		Photo: firstString(props["photo"]),
+	}
+}
+
+func toProperties(m map[string]any) map[string][]any {
+	out := make(map[string][]any, len(m))
+	for k, v := range m {
+		if vals, ok := v.([]any); ok {
+			out[k] = vals
+		}
+	}
+	return out
+}
+
+// linksTo reports whether the source body links to target. HTML is checked for an element linking to
+// the target; other content types for the target URL appearing anywhere.
+func linksTo(body []byte, isHtml bool, target string) bool {
+	if !isHtml {
+		return bytes.Contains(body, []byte(target))
+	}
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	var found bool
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if found {
+			return
+		}
+		if n.Type == html.ElementNode {
+			for _, a := range n.Attr {
+				if (a.Key == "href" || a.Key == "src") && sameUrl(strings.TrimSpace(a.Val), target) {
+					found = true
+					return
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return found
+}
+
+func sameUrl(a string, b string) bool {
+	return a != "" && strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
+
+func isHttpUrl(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
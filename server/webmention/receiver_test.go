@@ -0,0 +1,226 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/publicnet"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+const testTarget = "https://example.org/posts/hello"
+
+// memoryStore serves a single post and keeps mentions in memory.
+type memoryStore struct {
+	content.ContentStore
+	deleted  bool
+	mentions []content.Mention
+}
+
+func (ms *memoryStore) Get(_ context.Context, url string) (*util.Mf2Document, error) {
+	if url != testTarget {
+		return nil, content.ErrNotFound
+	}
+	return &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"deleted": {ms.deleted}}}, nil
+}
+
+func (ms *memoryStore) SaveMention(_ context.Context, m content.Mention) error {
+	if m.Target != testTarget {
+		return content.ErrNotFound
+	}
+	ms.mentions = slices.DeleteFunc(ms.mentions, func(e content.Mention) bool { return e.Source == m.Source })
+	ms.mentions = append(ms.mentions, m)
+	return nil
+}
+
+func (ms *memoryStore) DeleteMention(_ context.Context, _ string, source string) error {
+	n := len(ms.mentions)
+	ms.mentions = slices.DeleteFunc(ms.mentions, func(e content.Mention) bool { return e.Source == source })
+	if len(ms.mentions) == n {
+		return content.ErrNotFound
+	}
+	return nil
+}
+
+func (ms *memoryStore) Mentions(_ context.Context, filter content.MentionFilter) ([]content.Mention, error) {
+	var out []content.Mention
+	for _, m := range ms.mentions {
+		if filter.Status == "" || m.Status == filter.Status {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func newTestReceiver(t *testing.T, client *http.Client, moderate bool) (*Receiver, *memoryStore, *jobs.Runner) {
+	t.Helper()
+	runner, err := jobs.NewRunner(&config.Jobs{})
+	if err != nil {
+		t.Fatalf("failed to create runner: %v", err)
+	}
+	store := &memoryStore{}
+	rc := NewReceiver(store, store, runner, client, moderate)
+	rc.lookup = func(_ context.Context, host string) ([]netip.Addr, error) {
+		if host == "private.example" {
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("192.168.1.10")}, nil
+		}
+		return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+	}
+	return rc, store, runner
+}
+
+func TestReceiver_Accept(t *testing.T) {
+	rc, store, runner := newTestReceiver(t, nil, false)
+	ctx := context.Background()
+
+	if err := rc.Accept(ctx, "ftp://a.example/", testTarget); !errors.Is(err, ErrInvalidMention) {
+		t.Fatalf("expected ErrInvalidMention for a non-http source, got %v", err)
+	}
+	if err := rc.Accept(ctx, testTarget, testTarget); !errors.Is(err, ErrInvalidMention) {
+		t.Fatalf("expected ErrInvalidMention for source == target, got %v", err)
+	}
+	if err := rc.Accept(ctx, "https://a.example/", "https://example.org/posts/missing"); !errors.Is(err, content.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for an unknown target, got %v", err)
+	}
+
+	store.deleted = true
+	if err := rc.Accept(ctx, "https://a.example/", testTarget); !errors.Is(err, content.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a deleted target, got %v", err)
+	}
+
+	store.deleted = false
+	if err := rc.Accept(ctx, "https://a.example/", testTarget); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p := runner.Pending(); len(p) != 1 || p[0].Kind != VerifyJobKind {
+		t.Fatalf("expected a queued verification, got %+v", p)
+	}
+}
+
+func TestReceiver_AcceptRefusesPrivateSources(t *testing.T) {
+	rc, _, runner := newTestReceiver(t, nil, false)
+
+	for _, source := range []string{
+		"http://127.0.0.1/",
+		"http://localhost:8080/",
+		"http://app.localhost/",
+		"http://10.0.0.1/",
+		"http://[::1]/",
+		"http://[::ffff:127.0.0.1]/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://100.64.0.1/",
+		"https://private.example/",
+	} {
+		if err := rc.Accept(context.Background(), source, testTarget); !errors.Is(err, ErrInvalidMention) || !errors.Is(err, publicnet.ErrPrivateAddress) {
+			t.Errorf("%s: expected a private address to be refused, got %v", source, err)
+		}
+	}
+	if p := runner.Pending(); len(p) != 0 {
+		t.Fatalf("expected nothing to be queued, got %+v", p)
+	}
+}
+
+func TestReceiver_VerifyRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<a href="` + testTarget + `">hi</a>`))
+	}))
+	defer srv.Close()
+
+	rc, store, _ := newTestReceiver(t, nil, false)
+	if err := rc.Verify(context.Background(), srv.URL, testTarget); !errors.Is(err, publicnet.ErrPrivateAddress) || !jobs.IsPermanent(err) {
+		t.Fatalf("expected the loopback source not to be fetched, got %v", err)
+	}
+	if len(store.mentions) != 0 {
+		t.Fatalf("expected no mention to be stored, got %+v", store.mentions)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://10.1.2.3/", nil)
+	if err := rc.client.CheckRedirect(req, []*http.Request{httptest.NewRequest(http.MethodGet, "https://a.example/", nil)}); !errors.Is(err, publicnet.ErrPrivateAddress) {
+		t.Fatalf("expected a redirect to a private address to be refused, got %v", err)
+	}
+}
+
+func TestReceiver_Verify(t *testing.T) {
+	pages := map[string]string{
+		"/like": `<div class="h-entry">
+			<a class="p-author h-card" href="https://alice.example/"><img class="u-photo" src="/alice.jpg">Alice</a>
+			liked <a class="u-like-of" href="` + testTarget + `">this</a>
+		</div>`,
+		"/reply": `<article class="h-entry">
+			<a class="u-in-reply-to" href="` + testTarget + `/">re</a>
+			<p class="e-content">Lovely post</p>
+			<time class="dt-published" datetime="2025-03-01T10:00:00Z">today</time>
+		</article>
+		<div class="h-card"><a class="p-name u-url" href="https://bob.example/">Bob</a></div>`,
+		"/unrelated": `<p>nothing to see</p>`,
+	}
+
+	mux := http.NewServeMux()
+	for path := range pages {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(pages[r.URL.Path]))
+		})
+	}
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	rc, store, _ := newTestReceiver(t, srv.Client(), true)
+	ctx := context.Background()
+
+	for _, path := range []string{"/like", "/reply", "/unrelated"} {
+		if err := rc.Verify(ctx, srv.URL+path, testTarget); err != nil {
+			t.Fatalf("verify %s failed: %v", path, err)
+		}
+	}
+
+	if len(store.mentions) != 2 {
+		t.Fatalf("expected two stored mentions, got %+v", store.mentions)
+	}
+
+	like, reply := store.mentions[0], store.mentions[1]
+	if like.Type != "like" || like.Status != MentionPending || like.Author.Name != "Alice" || like.Author.Url != "https://alice.example/" || like.Author.Photo != srv.URL+"/alice.jpg" {
+		t.Fatalf("unexpected like %+v", like)
+	}
+	if reply.Type != "reply" || reply.Content != "Lovely post" || reply.Published != "2025-03-01T10:00:00Z" || reply.Author.Name != "Bob" {
+		t.Fatalf("unexpected reply %+v", reply)
+	}
+
+	// Moderation decisions survive re-verification of an updated source.
+	if err := rc.Moderate(ctx, testTarget, srv.URL+"/like", MentionApproved); err != nil {
+		t.Fatalf("moderate failed: %v", err)
+	}
+	if err := rc.Verify(ctx, srv.URL+"/like", testTarget); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if approved, _ := rc.Mentions(ctx, content.MentionFilter{Status: MentionApproved}); len(approved) != 1 {
+		t.Fatalf("expected the like to stay approved, got %+v", approved)
+	}
+	if err := rc.Moderate(ctx, testTarget, srv.URL+"/like", "maybe"); !errors.Is(err, ErrInvalidMention) {
+		t.Fatalf("expected ErrInvalidMention for an unknown status, got %v", err)
+	}
+
+	// A source that no longer links to the target, or is gone, removes the mention.
+	pages["/like"] = `<p>changed my mind</p>`
+	if err := rc.Verify(ctx, srv.URL+"/like", testTarget); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	store.mentions = append(store.mentions, content.Mention{Source: srv.URL + "/gone", Target: testTarget})
+	if err := rc.Verify(ctx, srv.URL+"/gone", testTarget); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if len(store.mentions) != 1 || store.mentions[0].Type != "reply" {
+		t.Fatalf("expected only the reply to remain, got %+v", store.mentions)
+	}
+}
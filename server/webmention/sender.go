@@ -0,0 +1,155 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/publicnet"
+)
+
+// JobKind is the job kind used to send a single webmention.
+const JobKind = "webmention"
+
+const requestTimeout = 20 * time.Second
+
+type jobPayload struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// Sender notifies the sites a post links to. It listens for content changes and queues one job per
+// target, so a slow or broken receiver never holds up a micropub response.
+type Sender struct {
+	client *http.Client
+	jobs   *jobs.Runner
+	status *StatusLog
+	now    func() time.Time
+}
+
+// NewSender registers the webmention job with runner. Deliveries are recorded in status. Without a
+// client, endpoints are discovered and sent to with one that only connects to public addresses, as
+// any page a post links to can name one.
+func NewSender(runner *jobs.Runner, status *StatusLog, client *http.Client) *Sender {
+	if client == nil {
+		client = publicnet.NewClient(requestTimeout)
+	}
+
+	s := &Sender{client: client, jobs: runner, status: status, now: time.Now}
+	runner.Register(JobKind, s.runJob)
+
+	return s
+}
+
+// ContentChanged queues webmentions for every link in the new document and for links that the
+// previous version had, so that removed links are notified too and receivers can drop the mention.
+func (s *Sender) ContentChanged(_ context.Context, ev hooks.Event) {
+	targets := Targets(ev.Document)
+	for _, t := range Targets(ev.Previous) {
+		if !slices.Contains(targets, t) {
+			targets = append(targets, t)
+		}
+	}
+
+	for _, target := range targets {
+		if target == ev.Url {
+			continue
+		}
+
+		if err := s.Queue(ev.Url, target); err != nil {
+			log.Printf("error: failed to queue webmention from %q to %q: %v", ev.Url, target, err)
+		}
+	}
+}
+
+// Queue schedules a webmention from source to target.
+func (s *Sender) Queue(source string, target string) error {
+	if _, err := s.jobs.Enqueue(JobKind, jobPayload{Source: source, Target: target}); err != nil {
+		return err
+	}
+
+	return s.status.Record(Delivery{Source: source, Target: target, Status: StatusQueued, UpdatedAt: s.now()})
+}
+
+// Send discovers the target's endpoint and delivers the webmention.
+func (s *Sender) Send(ctx context.Context, source string, target string) (Delivery, error) {
+	d := Delivery{Source: source, Target: target}
+
+	endpoint, err := Discover(ctx, s.client, target)
+	if err != nil {
+		return d, err
+	}
+	d.Endpoint = endpoint
+
+	form := url.Values{"source": {source}, "target": {target}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return d, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return d, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+
+	d.Code = res.StatusCode
+	d.Location = res.Header.Get("Location")
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return d, &StatusError{Code: res.StatusCode}
+	}
+
+	return d, nil
+}
+
+func (s *Sender) runJob(ctx context.Context, job *jobs.Job) error {
+	var payload jobPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	d, err := s.Send(ctx, payload.Source, payload.Target)
+	d.UpdatedAt = s.now()
+
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		d.Status = StatusDelivered
+	case errors.Is(err, ErrNoEndpoint):
+		// Most sites don't accept webmentions; that is not a failure worth retrying.
+		d.Status = StatusNoEndpoint
+		err = nil
+	case errors.Is(err, publicnet.ErrPrivateAddress):
+		d.Status = StatusFailed
+		d.Error = err.Error()
+		err = jobs.Permanent(err)
+	case errors.As(err, &statusErr) && statusErr.Code >= 400 && statusErr.Code < 500 && statusErr.Code != http.StatusTooManyRequests:
+		d.Status = StatusFailed
+		d.Error = err.Error()
+		err = jobs.Permanent(err)
+	default:
+		d.Status = StatusRetrying
+		d.Error = err.Error()
+	}
+
+	if recordErr := s.status.Record(d); recordErr != nil {
+		log.Printf("error: failed to record webmention status: %v", recordErr)
+	}
+
+	if err != nil {
+		return fmt.Errorf("webmention from %q to %q: %w", payload.Source, payload.Target, err)
+	}
+
+	return nil
+}
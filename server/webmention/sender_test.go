@@ -0,0 +1,159 @@
+package webmention
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/publicnet"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func newTestSender(t *testing.T, client *http.Client) (*Sender, *jobs.Runner, *StatusLog) {
+	t.Helper()
+	runner, err := jobs.NewRunner(&config.Jobs{})
+	if err != nil {
+		t.Fatalf("failed to create runner: %v", err)
+	}
+	status, _ := NewStatusLog("")
+	return NewSender(runner, status, client), runner, status
+}
+
+func TestSender_QueuesCurrentAndRemovedTargets(t *testing.T) {
+	s, runner, status := newTestSender(t, nil)
+
+	previous := &util.Mf2Document{Properties: map[string][]any{"in-reply-to": {"https://old.example/"}}}
+	current := &util.Mf2Document{Properties: map[string][]any{"like-of": {"https://new.example/"}, "bookmark-of": {"https://example.org/post"}}}
+	s.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionUpdate, Url: "https://example.org/post", Document: current, Previous: previous})
+
+	var targets []string
+	for _, job := range runner.Pending() {
+		var payload jobPayload
+		_ = job.Decode(&payload)
+		targets = append(targets, payload.Target)
+	}
+	slices.Sort(targets)
+
+	if !slices.Equal(targets, []string{"https://new.example/", "https://old.example/"}) {
+		t.Fatalf("unexpected queued targets %v", targets)
+	}
+	if d := status.For("https://example.org/post"); len(d) != 2 || d[0].Status != StatusQueued {
+		t.Fatalf("expected queued statuses, got %+v", d)
+	}
+}
+
+func TestSender_RunJob(t *testing.T) {
+	var received []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /target", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</endpoint>; rel="webmention"`)
+	})
+	mux.HandleFunc("GET /rejecting", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</reject>; rel="webmention"`)
+	})
+	mux.HandleFunc("GET /plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+	})
+	mux.HandleFunc("POST /endpoint", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		received = append(received, r.PostForm.Get("source")+" -> "+r.PostForm.Get("target"))
+		w.Header().Set("Location", "https://status.example/1")
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("POST /reject", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "source does not link to target", http.StatusBadRequest)
+	})
+	mux.HandleFunc("POST /down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mux.HandleFunc("GET /flaky", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", `</down>; rel="webmention"`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	s, _, status := newTestSender(t, srv.Client())
+	source := "https://example.org/post"
+
+	run := func(target string) error {
+		job := &jobs.Job{Kind: JobKind, Payload: []byte(`{"source":"` + source + `","target":"` + target + `"}`)}
+		return s.runJob(context.Background(), job)
+	}
+
+	if err := run(srv.URL + "/target"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received) != 1 || received[0] != source+" -> "+srv.URL+"/target" {
+		t.Fatalf("unexpected webmentions received: %v", received)
+	}
+
+	if err := run(srv.URL + "/plain"); err != nil {
+		t.Fatalf("expected targets without an endpoint to succeed, got %v", err)
+	}
+	if err := run(srv.URL + "/rejecting"); !jobs.IsPermanent(err) {
+		t.Fatalf("expected a permanent error for a 400, got %v", err)
+	}
+	if err := run(srv.URL + "/flaky"); err == nil || jobs.IsPermanent(err) {
+		t.Fatalf("expected a retryable error for a 503, got %v", err)
+	}
+
+	got := map[string]Delivery{}
+	for _, d := range status.For(source) {
+		got[d.Target] = d
+	}
+	if d := got[srv.URL+"/target"]; d.Status != StatusDelivered || d.Code != http.StatusCreated || d.Location != "https://status.example/1" || d.Endpoint != srv.URL+"/endpoint" {
+		t.Fatalf("unexpected delivery %+v", d)
+	}
+	if got[srv.URL+"/plain"].Status != StatusNoEndpoint || got[srv.URL+"/rejecting"].Status != StatusFailed || got[srv.URL+"/flaky"].Status != StatusRetrying {
+		t.Fatalf("unexpected statuses %+v", got)
+	}
+}
+
+func TestSender_RefusesPrivateAddresses(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+		w.Header().Set("Link", `</endpoint>; rel="webmention"`)
+	}))
+	defer srv.Close()
+
+	s, _, status := newTestSender(t, nil)
+	source := "https://example.org/post"
+	job := &jobs.Job{Kind: JobKind, Payload: []byte(`{"source":"` + source + `","target":"` + srv.URL + `/target"}`)}
+	if err := s.runJob(context.Background(), job); !errors.Is(err, publicnet.ErrPrivateAddress) || !jobs.IsPermanent(err) {
+		t.Fatalf("expected a permanent private address error, got %v", err)
+	}
+	if hit {
+		t.Fatalf("expected the loopback target not to be fetched")
+	}
+	if d := status.For(source); len(d) != 1 || d[0].Status != StatusFailed {
+		t.Fatalf("expected the delivery to be marked failed, got %+v", d)
+	}
+}
+
+func TestStatusLog_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	sl, err := NewStatusLog(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := sl.Record(Delivery{Source: "s", Target: "t", Status: StatusDelivered}); err != nil {
+		t.Fatalf("record failed: %v", err)
+	}
+
+	reloaded, err := NewStatusLog(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := reloaded.For("s"); len(d) != 1 || d[0].Status != StatusDelivered {
+		t.Fatalf("expected persisted delivery, got %+v", d)
+	}
+}
@@ -0,0 +1,114 @@
+package webmention
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Delivery states recorded in the status log.
+const (
+	StatusQueued     = "queued"
+	StatusDelivered  = "delivered"
+	StatusNoEndpoint = "no-endpoint"
+	StatusRetrying   = "retrying"
+	StatusFailed     = "failed"
+)
+
+// Delivery is the outcome of sending a webmention from source to target.
+type Delivery struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Status   string `json:"status"`
+	Endpoint string `json:"endpoint,omitempty"`
+	// Code is the HTTP status returned by the endpoint.
+	Code int `json:"code,omitempty"`
+	// Location is the status URL some receivers return for asynchronous processing.
+	Location  string    `json:"location,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StatusLog records the latest delivery per source and target, optionally persisted to a JSON file.
+type StatusLog struct {
+	mu         sync.RWMutex
+	path       string
+	deliveries map[string]map[string]Delivery
+}
+
+func NewStatusLog(path string) (*StatusLog, error) {
+	sl := &StatusLog{path: path, deliveries: map[string]map[string]Delivery{}}
+	if path == "" {
+		return sl, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sl, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webmention status log: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &sl.deliveries); err != nil {
+		return nil, fmt.Errorf("failed to decode webmention status log: %w", err)
+	}
+
+	return sl, nil
+}
+
+// Record stores the delivery, replacing any earlier one for the same source and target.
+func (sl *StatusLog) Record(d Delivery) error {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	if sl.deliveries[d.Source] == nil {
+		sl.deliveries[d.Source] = map[string]Delivery{}
+	}
+	sl.deliveries[d.Source][d.Target] = d
+
+	return sl.save()
+}
+
+// For returns the deliveries sent for source, ordered by target.
+func (sl *StatusLog) For(source string) []Delivery {
+	sl.mu.RLock()
+	defer sl.mu.RUnlock()
+
+	out := make([]Delivery, 0, len(sl.deliveries[source]))
+	for _, d := range sl.deliveries[source] {
+		out = append(out, d)
+	}
+	slices.SortFunc(out, func(a, b Delivery) int { return strings.Compare(a.Target, b.Target) })
+
+	return out
+}
+
+// save writes the log to its file, if any. The caller must hold sl.mu.
+func (sl *StatusLog) save() error {
+	if sl.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(sl.deliveries)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(sl.path), 0755); err != nil {
+		return fmt.Errorf("failed to create webmention status directory: %w", err)
+	}
+
+	tmp := sl.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write webmention status log: %w", err)
+	}
+
+	return os.Rename(tmp, sl.path)
+}
@@ -0,0 +1,203 @@
+package websub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// JobKind is the job kind used to notify a single hub.
+const JobKind = "websub-publish"
+
+const (
+	defaultDebounce = 10 * time.Second
+	requestTimeout  = 20 * time.Second
+)
+
+type jobPayload struct {
+	Hub   string   `json:"hub"`
+	Feeds []string `json:"feeds"`
+}
+
+// Publisher notifies WebSub hubs that feeds changed. Changes are collected for a debounce window so a
+// burst of edits results in one ping per feed, which is then delivered to each hub as a job.
+type Publisher struct {
+	hubs     []string
+	feeds    []string
+	debounce time.Duration
+	jobs     *jobs.Runner
+	client   *http.Client
+
+	mu      sync.Mutex
+	pending []string
+	timer   *time.Timer
+}
+
+// NewPublisher registers the publish job with runner.
+func NewPublisher(cfg *config.WebSub, runner *jobs.Runner, client *http.Client) *Publisher {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+
+	p := &Publisher{hubs: cfg.Hubs, feeds: cfg.Feeds, debounce: cfg.Debounce, jobs: runner, client: client}
+	if p.debounce <= 0 {
+		p.debounce = defaultDebounce
+	}
+
+	runner.Register(JobKind, p.runJob)
+	return p
+}
+
+// ContentChanged collects the feeds affected by the change, before and after it, and schedules a flush.
+func (p *Publisher) ContentChanged(_ context.Context, ev hooks.Event) {
+	var feeds []string
+	for _, doc := range []*util.Mf2Document{ev.Document, ev.Previous} {
+		for _, feed := range FeedUrls(p.feeds, doc) {
+			if !slices.Contains(feeds, feed) {
+				feeds = append(feeds, feed)
+			}
+		}
+	}
+
+	if len(feeds) == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, feed := range feeds {
+		if !slices.Contains(p.pending, feed) {
+			p.pending = append(p.pending, feed)
+		}
+	}
+
+	if p.timer == nil {
+		p.timer = time.AfterFunc(p.debounce, p.Flush)
+	}
+}
+
+// Flush queues a publish job per hub for the feeds collected so far.
+func (p *Publisher) Flush() {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	feeds := p.pending
+	p.pending = nil
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.mu.Unlock()
+
+	if len(feeds) == 0 {
+		return
+	}
+
+	for _, hub := range p.hubs {
+		if _, err := p.jobs.Enqueue(JobKind, jobPayload{Hub: hub, Feeds: feeds}); err != nil {
+			log.Printf("error: failed to queue websub ping to %q: %v", hub, err)
+		}
+	}
+}
+
+// Publish tells the hub that the feed has new content.
+func (p *Publisher) Publish(ctx context.Context, hub string, feed string) error {
+	form := url.Values{"hub.mode": {"publish"}, "hub.url": {feed}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("hub responded with %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+func (p *Publisher) runJob(ctx context.Context, job *jobs.Job) error {
+	var payload jobPayload
+	if err := job.Decode(&payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	for _, feed := range payload.Feeds {
+		if err := p.Publish(ctx, payload.Hub, feed); err != nil {
+			return fmt.Errorf("websub ping of %q for %q: %w", payload.Hub, feed, err)
+		}
+	}
+
+	return nil
+}
+
+// FeedUrls expands the feed templates for a document. Templates may use {channel} and {type}, the
+// document's channel and discovered post type; a template whose placeholder has no value for the
+// document is skipped.
+func FeedUrls(templates []string, doc *util.Mf2Document) []string {
+	// Unlisted posts are left out of feeds.
+	if doc == nil || content.Visibility(*doc) == content.VisibilityUnlisted {
+		return nil
+	}
+
+	values := map[string]string{
+		"{channel}": firstString(doc.Properties["channel"]),
+		"{type}":    util.PostType(*doc),
+	}
+
+	var out []string
+	for _, tmpl := range templates {
+		feed, ok := expand(tmpl, values)
+		if ok && !slices.Contains(out, feed) {
+			out = append(out, feed)
+		}
+	}
+
+	return out
+}
+
+func expand(tmpl string, values map[string]string) (string, bool) {
+	for placeholder, value := range values {
+		if !strings.Contains(tmpl, placeholder) {
+			continue
+		}
+		if value == "" {
+			return "", false
+		}
+		tmpl = strings.ReplaceAll(tmpl, placeholder, url.PathEscape(value))
+	}
+
+	return tmpl, true
+}
+
+func firstString(values []any) string {
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package websub
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/server/hooks"
+	"github.com/indieinfra/scribble/server/jobs"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func TestFeedUrls(t *testing.T) {
+	templates := []string{
+		"https://example.org/feed.xml",
+		"https://example.org/{channel}/feed.xml",
+		"https://example.org/{type}s/feed.xml",
+	}
+
+	note := &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"hi"}, "channel": {"notes"}}}
+	// {channel} and {type} both expand to "notes"; the feed is only listed once.
+	want := []string{"https://example.org/feed.xml", "https://example.org/notes/feed.xml"}
+	if got := FeedUrls(templates, note); !slices.Equal(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	like := &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"like-of": {"https://a.example/"}}}
+	if got := FeedUrls(templates, like); !slices.Equal(got, []string{"https://example.org/feed.xml", "https://example.org/likes/feed.xml"}) {
+		t.Fatalf("unexpected feeds for a like without channel: %v", got)
+	}
+
+	unlisted := &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"hi"}, "visibility": {"unlisted"}}}
+	if got := FeedUrls(templates, unlisted); len(got) != 0 {
+		t.Fatalf("expected unlisted posts to be left out of feeds, got %v", got)
+	}
+}
+
+func TestPublisher_DebouncesAndPings(t *testing.T) {
+	var mu sync.Mutex
+	var pinged []string
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.PostForm.Get("hub.mode") != "publish" {
+			t.Errorf("unexpected hub.mode %q", r.PostForm.Get("hub.mode"))
+		}
+		mu.Lock()
+		pinged = append(pinged, r.PostForm.Get("hub.url"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer hub.Close()
+
+	runner, _ := jobs.NewRunner(&config.Jobs{})
+	p := NewPublisher(&config.WebSub{
+		Hubs:     []string{hub.URL},
+		Feeds:    []string{"https://example.org/feed.xml", "https://example.org/{channel}/feed.xml"},
+		Debounce: time.Hour,
+	}, runner, hub.Client())
+
+	doc := func(channel string) *util.Mf2Document {
+		return &util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"hi"}, "channel": {channel}}}
+	}
+	p.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionCreate, Document: doc("notes")})
+	p.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionCreate, Document: doc("notes")})
+	p.ContentChanged(context.Background(), hooks.Event{Action: hooks.ActionUpdate, Document: doc("photos"), Previous: doc("notes")})
+
+	if n := len(runner.Pending()); n != 0 {
+		t.Fatalf("expected nothing queued within the debounce window, got %d", n)
+	}
+
+	p.Flush()
+
+	pending := runner.Pending()
+	if len(pending) != 1 {
+		t.Fatalf("expected one job for the hub, got %d", len(pending))
+	}
+	if err := p.runJob(context.Background(), &pending[0]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"https://example.org/feed.xml", "https://example.org/notes/feed.xml", "https://example.org/photos/feed.xml"}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(pinged, want) {
+		t.Fatalf("expected pings %v, got %v", want, pinged)
+	}
+}
+
+func TestPublisher_FailingHubIsRetried(t *testing.T) {
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer hub.Close()
+
+	runner, _ := jobs.NewRunner(&config.Jobs{})
+	p := NewPublisher(&config.WebSub{Hubs: []string{hub.URL}}, runner, hub.Client())
+
+	job := &jobs.Job{Kind: JobKind, Payload: []byte(`{"hub":"` + hub.URL + `","feeds":["https://example.org/feed.xml"]}`)}
+	if err := p.runJob(context.Background(), job); err == nil || jobs.IsPermanent(err) {
+		t.Fatalf("expected a retryable error, got %v", err)
+	}
+}
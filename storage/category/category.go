@@ -0,0 +1,123 @@
+package category
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// Vocabulary tracks the distinct category values used across stored posts and how often each
+// one is used. It is updated incrementally as posts change, so queries never rescan the store.
+type Vocabulary struct {
+	mu     sync.RWMutex
+	byUrl  map[string][]string
+	counts map[string]int
+}
+
+func NewVocabulary() *Vocabulary {
+	return &Vocabulary{
+		byUrl:  map[string][]string{},
+		counts: map[string]int{},
+	}
+}
+
+// Load replaces the vocabulary with the categories of every non-deleted post held by the lister.
+func (v *Vocabulary) Load(ctx context.Context, lister content.Lister) error {
+	result, err := lister.List(ctx, content.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list content: %w", err)
+	}
+
+	v.mu.Lock()
+	v.byUrl = map[string][]string{}
+	v.counts = map[string]int{}
+	v.mu.Unlock()
+
+	for _, doc := range result.Items {
+		v.Set(util.PropertyText(doc.Properties["url"], 1), doc)
+	}
+
+	return nil
+}
+
+// Set records the categories of the post stored at url, replacing whatever was recorded for it
+// before. Deleted posts are removed from the vocabulary.
+func (v *Vocabulary) Set(url string, doc util.Mf2Document) {
+	if content.IsDeleted(doc) {
+		v.Remove(url)
+		return
+	}
+
+	var categories []string
+	for _, c := range doc.Properties["category"] {
+		// Person tags (h-cards or bare URLs) are not part of the tag vocabulary.
+		s, ok := c.(string)
+		if !ok || s == "" || strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+			continue
+		}
+		categories = append(categories, s)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.removeLocked(url)
+	if len(categories) == 0 {
+		return
+	}
+
+	v.byUrl[url] = categories
+	for _, c := range categories {
+		v.counts[c]++
+	}
+}
+
+// Remove forgets the categories recorded for the post stored at url.
+func (v *Vocabulary) Remove(url string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.removeLocked(url)
+}
+
+func (v *Vocabulary) removeLocked(url string) {
+	for _, c := range v.byUrl[url] {
+		v.counts[c]--
+		if v.counts[c] <= 0 {
+			delete(v.counts, c)
+		}
+	}
+	delete(v.byUrl, url)
+}
+
+// Categories returns the categories starting with prefix (case-insensitively), most used first.
+// A limit of zero returns every match.
+func (v *Vocabulary) Categories(prefix string, limit int) []string {
+	prefix = strings.ToLower(prefix)
+
+	v.mu.RLock()
+	out := make([]string, 0, len(v.counts))
+	for c := range v.counts {
+		if strings.HasPrefix(strings.ToLower(c), prefix) {
+			out = append(out, c)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if v.counts[out[i]] != v.counts[out[j]] {
+			return v.counts[out[i]] > v.counts[out[j]]
+		}
+		return out[i] < out[j]
+	})
+	v.mu.RUnlock()
+
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out
+}
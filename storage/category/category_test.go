@@ -0,0 +1,69 @@
+package category
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+func entry(categories ...any) util.Mf2Document {
+	return util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"category": categories}}
+}
+
+func TestVocabulary_FrequencyAndPrefix(t *testing.T) {
+	v := NewVocabulary()
+	v.Set("https://example.org/1", entry("go", "golang", "music"))
+	v.Set("https://example.org/2", entry("go", "Gardening", "https://alice.example"))
+	v.Set("https://example.org/3", entry("go", "music", map[string]any{"type": []any{"h-card"}}))
+
+	if got := v.Categories("", 0); !reflect.DeepEqual(got, []string{"go", "music", "Gardening", "golang"}) {
+		t.Fatalf("unexpected ordering: %v", got)
+	}
+	if got := v.Categories("G", 2); !reflect.DeepEqual(got, []string{"go", "Gardening"}) {
+		t.Fatalf("unexpected prefix match: %v", got)
+	}
+}
+
+func TestVocabulary_IncrementalUpdates(t *testing.T) {
+	v := NewVocabulary()
+	v.Set("https://example.org/1", entry("a", "b"))
+	v.Set("https://example.org/2", entry("b"))
+
+	v.Set("https://example.org/1", entry("c"))
+	if got := v.Categories("", 0); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Fatalf("expected replaced categories to be dropped, got %v", got)
+	}
+
+	deleted := entry("b")
+	deleted.Properties["deleted"] = []any{true}
+	v.Set("https://example.org/2", deleted)
+	v.Remove("https://example.org/1")
+
+	if got := v.Categories("", 0); len(got) != 0 {
+		t.Fatalf("expected empty vocabulary, got %v", got)
+	}
+}
+
+type stubLister struct{ items []util.Mf2Document }
+
+func (s *stubLister) List(context.Context, content.ListOptions) (*content.ListResult, error) {
+	return &content.ListResult{Items: s.items}, nil
+}
+
+func TestVocabulary_Load(t *testing.T) {
+	v := NewVocabulary()
+	v.Set("https://example.org/stale", entry("stale"))
+
+	doc := entry("fresh")
+	doc.Properties["url"] = []any{"https://example.org/fresh"}
+	if err := v.Load(context.Background(), &stubLister{items: []util.Mf2Document{doc}}); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	if got := v.Categories("", 0); !reflect.DeepEqual(got, []string{"fresh"}) {
+		t.Fatalf("unexpected vocabulary after load: %v", got)
+	}
+}
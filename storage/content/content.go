@@ -2,6 +2,8 @@ package content
 
 import (
 	"context"
+	"log"
+	"time"
 
 	"github.com/indieinfra/scribble/server/util"
 )
@@ -41,3 +43,153 @@ type ContentStore interface {
 	// traversing the git tree, a non-nil error will be returned
 	ExistsBySlug(ctx context.Context, slug string) (bool, error)
 }
+
+// ListOptions narrows and pages the documents returned by a Lister.
+type ListOptions struct {
+	// Limit caps the number of returned documents. Zero means no limit.
+	Limit int
+	// Offset skips the given number of matching documents.
+	Offset int
+	// After resumes a listing after the document with this URL, as returned in ListResult.After.
+	After string
+	// PostType restricts the listing to documents of the given discovered post type (e.g. "note").
+	PostType string
+	// IncludeDeleted includes documents marked deleted=true, which are skipped by default.
+	IncludeDeleted bool
+	// IncludeDrafts includes documents with post-status draft, which are skipped by default.
+	IncludeDrafts bool
+	// IncludeScheduled includes documents with post-status scheduled, which are skipped by default.
+	IncludeScheduled bool
+	// IncludePrivate includes documents with visibility private, which are skipped by default.
+	IncludePrivate bool
+}
+
+// ListResult holds one page of a listing.
+type ListResult struct {
+	// Items are ordered newest first and carry their public "url" property.
+	Items []util.Mf2Document
+	// After is the cursor for the next page, or empty when there are no more documents.
+	After string
+}
+
+// Lister is an optional interface for content stores that are able to enumerate their documents.
+type Lister interface {
+	// function List returns the documents matching the provided options, newest first.
+	List(ctx context.Context, opts ListOptions) (*ListResult, error)
+}
+
+// DocumentUpdater is an optional interface for content stores that can return the document an update
+// produced, saving the caller from reading it back.
+type DocumentUpdater interface {
+	// function UpdateDocument applies an update as Update does, returning the document's URL and its
+	// updated contents.
+	UpdateDocument(ctx context.Context, url string, replacements map[string][]any, additions map[string][]any, deletions any) (string, *util.Mf2Document, error)
+}
+
+// UpdateDocument applies an update through store and returns the document's URL and its updated
+// contents. Stores that aren't DocumentUpdaters are read back after the update; if that read fails,
+// the document is nil and the error is logged, since the update itself has been made.
+func UpdateDocument(ctx context.Context, store ContentStore, url string, replacements map[string][]any, additions map[string][]any, deletions any) (string, *util.Mf2Document, error) {
+	if updater, ok := store.(DocumentUpdater); ok {
+		return updater.UpdateDocument(ctx, url, replacements, additions, deletions)
+	}
+
+	newUrl, err := store.Update(ctx, url, replacements, additions, deletions)
+	if err != nil {
+		return newUrl, nil, err
+	}
+
+	doc, err := store.Get(ctx, newUrl)
+	if err != nil {
+		log.Printf("error: failed to read back %s after update: %v", newUrl, err)
+		return newUrl, nil, nil
+	}
+
+	return newUrl, doc, nil
+}
+
+// FileReader is an optional interface for content stores that can read auxiliary data files kept
+// alongside the content, such as a contacts list.
+type FileReader interface {
+	// function ReadFile returns the contents of the file at path, relative to the root of the store.
+	// If the file does not exist, ErrNotFound is returned.
+	ReadFile(ctx context.Context, path string) ([]byte, error)
+}
+
+// PathResolver returns the directory, relative to a store's content path, that a document should be
+// stored in. An empty string places the document directly in the content path. ctx is that of the
+// request writing the document.
+type PathResolver func(ctx context.Context, doc util.Mf2Document) string
+
+// Placer is an optional interface for content stores that can place documents in different
+// locations depending on their properties, such as the channel they were posted to.
+type Placer interface {
+	SetPathResolver(resolve PathResolver)
+}
+
+// Mention is a webmention received for one of the store's documents.
+type Mention struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// Type is how the source relates to the target: reply, like, repost, bookmark or mention.
+	Type string `json:"type"`
+	// Status is the moderation status: pending, approved or rejected.
+	Status    string        `json:"status"`
+	Author    MentionAuthor `json:"author"`
+	Url       string        `json:"url,omitempty"`
+	Content   string        `json:"content,omitempty"`
+	Published string        `json:"published,omitempty"`
+	Received  time.Time     `json:"received"`
+	Updated   time.Time     `json:"updated"`
+}
+
+type MentionAuthor struct {
+	Name  string `json:"name,omitempty"`
+	Url   string `json:"url,omitempty"`
+	Photo string `json:"photo,omitempty"`
+}
+
+// MentionFilter narrows the mentions returned by a MentionStore.
+type MentionFilter struct {
+	// Target restricts the result to mentions of the document with this URL. Empty means all documents.
+	Target string
+	// Status restricts the result to mentions with this moderation status.
+	Status string
+}
+
+// MentionStore is an optional interface for content stores that can keep received webmentions
+// alongside the documents they mention.
+type MentionStore interface {
+	// function SaveMention stores the mention, replacing any earlier mention with the same source and
+	// target. If the target document does not exist, ErrNotFound is returned.
+	SaveMention(ctx context.Context, m Mention) error
+
+	// function DeleteMention removes the mention of target by source. If there is none, ErrNotFound is returned.
+	DeleteMention(ctx context.Context, target string, source string) error
+
+	// function Mentions returns the stored mentions matching the filter, oldest first.
+	Mentions(ctx context.Context, filter MentionFilter) ([]Mention, error)
+}
+
+// Committer is who a change is recorded as made by, in stores that keep a history of changes.
+type Committer struct {
+	Name  string
+	Email string
+	// Me is the profile URL of the author behind the change.
+	Me string
+}
+
+type committerKeyType struct{}
+
+var committerKey = committerKeyType{}
+
+// WithCommitter returns a context that records changes made with it as made by c.
+func WithCommitter(ctx context.Context, c Committer) context.Context {
+	return context.WithValue(ctx, committerKey, c)
+}
+
+// CommitterFrom returns the committer recorded in ctx, if any.
+func CommitterFrom(ctx context.Context) (Committer, bool) {
+	c, ok := ctx.Value(committerKey).(Committer)
+	return c, ok
+}
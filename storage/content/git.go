@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -29,6 +30,8 @@ type GitContentStore struct {
 	repo   *git.Repository
 	tmpDir string
 	mu     sync.Mutex
+
+	resolvePath PathResolver
 }
 
 var NoErrFound error = errors.New("found")
@@ -197,9 +200,6 @@ func (cs *GitContentStore) Create(ctx context.Context, doc util.Mf2Document) (st
 		return "", false, err
 	}
 
-	filename := slug + ".json"
-	relPath := filepath.Join(cs.cfg.Path, filename)
-
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
@@ -207,128 +207,51 @@ func (cs *GitContentStore) Create(ctx context.Context, doc util.Mf2Document) (st
 		return "", false, fmt.Errorf("failed to update repo from remote: %w", err)
 	}
 
-	fullPath := filepath.Join(cs.tmpDir, relPath)
-	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
-		return "", false, fmt.Errorf("failed to create required directory structure: %w", err)
-	}
-
-	if err = os.WriteFile(fullPath, jsonBytes, 0644); err != nil {
-		return "", false, fmt.Errorf("failed to write file: %w", err)
-	}
-
-	wt, err := cs.repo.Worktree()
-	if err != nil {
-		return "", false, fmt.Errorf("failed to get new worktree: %w", err)
-	}
-
-	if _, err = wt.Add(relPath); err != nil {
-		return "", false, fmt.Errorf("failed to add file to git: %w", err)
-	}
-
-	_, err = wt.Commit(fmt.Sprintf("scribble(add): create content entry: %v", slug), &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  "scribble",
-			Email: "scribble@local",
-			When:  time.Now(),
-		},
-	})
-	if err != nil {
-		return "", false, fmt.Errorf("failed to create commit: %w", err)
-	}
-
-	if err := cs.repo.PushContext(ctx, &git.PushOptions{Auth: *cs.auth}); err != nil {
-		return "", false, fmt.Errorf("failed to push local: %w", err)
+	relPath := cs.documentPath(ctx, slug, doc)
+	if err := cs.commitDocument(ctx, "", relPath, jsonBytes, fmt.Sprintf("scribble(add): create content entry: %v", slug)); err != nil {
+		return "", false, err
 	}
 
 	return cs.cfg.PublicUrl + "/" + slug, false, nil
 }
 
 func (cs *GitContentStore) Update(ctx context.Context, url string, replacements map[string][]any, additions map[string][]any, deletions any) (string, error) {
-	slug, err := util.SlugFromURL(url)
-	if err != nil {
-		return url, err
-	}
-
-	cs.mu.Lock()
-	defer cs.mu.Unlock()
-
-	if err := cs.fetchAndFastForward(ctx); err != nil {
-		return url, fmt.Errorf("failed to update repo from remote: %w", err)
-	}
+	url, _, err := cs.UpdateDocument(ctx, url, replacements, additions, deletions)
+	return url, err
+}
 
-	doc, err := cs.readDocumentBySlug(slug)
+func (cs *GitContentStore) UpdateDocument(ctx context.Context, url string, replacements map[string][]any, additions map[string][]any, deletions any) (string, *util.Mf2Document, error) {
+	slug, err := util.SlugFromURL(url)
 	if err != nil {
-		return url, err
-	}
-	if doc == nil {
-		return url, ErrNotFound
-	}
-
-	if doc.Properties == nil {
-		doc.Properties = make(map[string][]any)
+		return url, nil, err
 	}
 
-	for key, values := range replacements {
-		doc.Properties[key] = values
-	}
+	doc, err := cs.rewriteDocument(ctx, slug, fmt.Sprintf("scribble(update): update content entry: %v", slug), func(doc *util.Mf2Document) {
+		for key, values := range replacements {
+			doc.Properties[key] = values
+		}
 
-	for key, values := range additions {
-		doc.Properties[key] = append(doc.Properties[key], values...)
-	}
+		for key, values := range additions {
+			doc.Properties[key] = append(doc.Properties[key], values...)
+		}
 
-	if deletes, ok := deletions.(map[string][]any); ok {
-		for key, valuesToRemove := range deletes {
-			remaining := cs.deleteValues(doc.Properties[key], valuesToRemove)
-			if len(remaining) == 0 {
+		if deletes, ok := deletions.(map[string][]any); ok {
+			for key, valuesToRemove := range deletes {
+				remaining := cs.deleteValues(doc.Properties[key], valuesToRemove)
+				if len(remaining) == 0 {
+					delete(doc.Properties, key)
+				} else {
+					doc.Properties[key] = remaining
+				}
+			}
+		} else if deletes, ok := deletions.([]string); ok {
+			for _, key := range deletes {
 				delete(doc.Properties, key)
-			} else {
-				doc.Properties[key] = remaining
 			}
 		}
-	} else if deletes, ok := deletions.([]string); ok {
-		for _, key := range deletes {
-			delete(doc.Properties, key)
-		}
-	}
-
-	jsonBytes, err := json.MarshalIndent(doc, "", "  ")
-	if err != nil {
-		return url, err
-	}
-
-	filename := slug + ".json"
-	relPath := filepath.Join(cs.cfg.Path, filename)
-	fullPath := filepath.Join(cs.tmpDir, relPath)
-
-	if err = os.WriteFile(fullPath, jsonBytes, 0644); err != nil {
-		return url, fmt.Errorf("failed to write file: %w", err)
-	}
-
-	wt, err := cs.repo.Worktree()
-	if err != nil {
-		return url, fmt.Errorf("failed to get worktree: %w", err)
-	}
-
-	if _, err = wt.Add(relPath); err != nil {
-		return url, fmt.Errorf("failed to add file to git: %w", err)
-	}
-
-	_, err = wt.Commit(fmt.Sprintf("scribble(update): update content entry: %v", slug), &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  "scribble",
-			Email: "scribble@local",
-			When:  time.Now(),
-		},
 	})
-	if err != nil {
-		return url, fmt.Errorf("failed to create commit: %w", err)
-	}
-
-	if err := cs.repo.PushContext(ctx, &git.PushOptions{Auth: *cs.auth}); err != nil {
-		return url, fmt.Errorf("failed to push local: %w", err)
-	}
 
-	return url, nil
+	return url, doc, err
 }
 
 func (cs *GitContentStore) Delete(ctx context.Context, url string) error {
@@ -354,7 +277,7 @@ func (cs *GitContentStore) Get(ctx context.Context, url string) (*util.Mf2Docume
 		return nil, fmt.Errorf("failed to update repo from remote: %w", err)
 	}
 
-	doc, err := cs.readDocumentBySlug(slug)
+	doc, _, err := cs.readDocumentBySlug(slug)
 	if err != nil {
 		return nil, err
 	}
@@ -365,123 +288,209 @@ func (cs *GitContentStore) Get(ctx context.Context, url string) (*util.Mf2Docume
 	return doc, nil
 }
 
-func (cs *GitContentStore) readDocumentBySlug(slug string) (*util.Mf2Document, error) {
-	head, err := cs.repo.Head()
-	if err != nil {
-		return nil, err
+// SetPathResolver changes where subsequently written documents are placed beneath the content path.
+func (cs *GitContentStore) SetPathResolver(resolve PathResolver) {
+	cs.mu.Lock()
+	cs.resolvePath = resolve
+	cs.mu.Unlock()
+}
+
+// documentPath returns the repository path a document with the given slug is written to. The caller
+// must hold cs.mu.
+func (cs *GitContentStore) documentPath(ctx context.Context, slug string, doc util.Mf2Document) string {
+	dir := ""
+	if cs.resolvePath != nil {
+		dir = cs.resolvePath(ctx, doc)
 	}
 
-	commit, err := cs.repo.CommitObject(head.Hash())
-	if err != nil {
-		return nil, err
+	if dir != "" && !filepath.IsLocal(dir) {
+		dir = ""
+	}
+
+	return filepath.Join(cs.cfg.Path, dir, slug+".json")
+}
+
+// locateDocument returns the repository path of the document with the given slug, searching every
+// directory beneath the content path, or an empty string if there is none.
+func (cs *GitContentStore) locateDocument(tree *object.Tree, slug string) string {
+	basePath := strings.TrimSuffix(cs.cfg.Path, "/") + "/"
+	filename := slug + ".json"
+
+	// Fast path: documents placed directly in the content path.
+	if _, err := tree.File(basePath + filename); err == nil {
+		return basePath + filename
 	}
 
-	tree, err := commit.Tree()
+	found := ""
+	_ = tree.Files().ForEach(func(f *object.File) error {
+		if strings.HasPrefix(f.Name, basePath) && path.Base(f.Name) == filename {
+			found = f.Name
+			return NoErrFound
+		}
+		return nil
+	})
+
+	return found
+}
+
+// readDocumentBySlug returns the document with the given slug and its repository path. A nil
+// document is returned when no readable document exists. The caller must hold cs.mu.
+func (cs *GitContentStore) readDocumentBySlug(slug string) (*util.Mf2Document, string, error) {
+	tree, err := cs.headTree()
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	filename := slug + ".json"
-	filePath := strings.TrimSuffix(cs.cfg.Path, "/") + "/" + filename
+	filePath := cs.locateDocument(tree, slug)
+	if filePath == "" {
+		return nil, "", nil
+	}
 
 	file, err := tree.File(filePath)
 	if err != nil {
-		return nil, nil
+		return nil, "", nil
 	}
 
 	r, err := file.Reader()
 	if err != nil {
-		return nil, nil
+		return nil, "", nil
 	}
 	defer r.Close()
 
 	data, err := io.ReadAll(r)
 	if err != nil {
-		return nil, nil
+		return nil, "", nil
 	}
 
 	var doc util.Mf2Document
 	if err := json.Unmarshal(data, &doc); err != nil {
-		return nil, nil
+		return nil, "", nil
 	}
 
-	return &doc, nil
+	return &doc, filePath, nil
 }
 
-func (cs *GitContentStore) setDeletedStatus(ctx context.Context, url string, deleted bool) (string, error) {
-	slug, err := util.SlugFromURL(url)
-	if err != nil {
-		return url, err
-	}
-
+// rewriteDocument reads the document with the given slug, applies mutate to it and commits the
+// result, which is returned. If the document now resolves to a different path, the file is moved.
+func (cs *GitContentStore) rewriteDocument(ctx context.Context, slug string, message string, mutate func(doc *util.Mf2Document)) (*util.Mf2Document, error) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
 	if err := cs.fetchAndFastForward(ctx); err != nil {
-		return url, fmt.Errorf("failed to update repo from remote: %w", err)
+		return nil, fmt.Errorf("failed to update repo from remote: %w", err)
 	}
 
-	doc, err := cs.readDocumentBySlug(slug)
+	doc, oldPath, err := cs.readDocumentBySlug(slug)
 	if err != nil {
-		return url, err
+		return nil, err
 	}
 	if doc == nil {
-		return url, ErrNotFound
+		return nil, ErrNotFound
 	}
 
 	if doc.Properties == nil {
 		doc.Properties = make(map[string][]any)
 	}
 
-	if deleted {
-		doc.Properties["deleted"] = []any{true}
-	} else {
-		doc.Properties["deleted"] = []any{false}
-	}
+	mutate(doc)
 
 	jsonBytes, err := json.MarshalIndent(doc, "", "  ")
 	if err != nil {
-		return url, err
+		return nil, err
 	}
 
-	filename := slug + ".json"
-	relPath := filepath.Join(cs.cfg.Path, filename)
+	if err := cs.commitDocument(ctx, oldPath, cs.documentPath(ctx, slug, *doc), jsonBytes, message); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// commitDocument writes data to relPath, removes oldPath if the document moved, then commits and
+// pushes the change. The caller must hold cs.mu.
+func (cs *GitContentStore) commitDocument(ctx context.Context, oldPath string, relPath string, data []byte, message string) error {
 	fullPath := filepath.Join(cs.tmpDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return fmt.Errorf("failed to create required directory structure: %w", err)
+	}
 
-	if err = os.WriteFile(fullPath, jsonBytes, 0644); err != nil {
-		return url, fmt.Errorf("failed to write file: %w", err)
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
 	}
 
 	wt, err := cs.repo.Worktree()
 	if err != nil {
-		return url, fmt.Errorf("failed to get worktree: %w", err)
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	if oldPath != "" && filepath.ToSlash(oldPath) != filepath.ToSlash(relPath) {
+		if _, err := wt.Remove(oldPath); err != nil {
+			return fmt.Errorf("failed to move file in git: %w", err)
+		}
+
+		// Received mentions follow their document.
+		if _, err := os.Stat(filepath.Join(cs.tmpDir, mentionsPath(oldPath))); err == nil {
+			if _, err := wt.Move(mentionsPath(oldPath), mentionsPath(relPath)); err != nil {
+				return fmt.Errorf("failed to move mentions in git: %w", err)
+			}
+		}
 	}
 
 	if _, err = wt.Add(relPath); err != nil {
-		return url, fmt.Errorf("failed to add file to git: %w", err)
+		return fmt.Errorf("failed to add file to git: %w", err)
 	}
 
-	action := "delete"
-	if !deleted {
-		action = "undelete"
+	return cs.commitAndPush(ctx, wt, message)
+}
+
+// commitAndPush commits the staged changes and pushes them. The caller must hold cs.mu.
+func (cs *GitContentStore) commitAndPush(ctx context.Context, wt *git.Worktree, message string) error {
+	author := &object.Signature{
+		Name:  "scribble",
+		Email: "scribble@local",
+		When:  time.Now(),
+	}
+	if c, ok := CommitterFrom(ctx); ok {
+		if c.Name != "" {
+			author.Name = c.Name
+		}
+		if c.Email != "" {
+			author.Email = c.Email
+		}
+		if c.Me != "" {
+			message += "\n\nMicropub-Author: " + c.Me
+		}
 	}
 
-	_, err = wt.Commit(fmt.Sprintf("scribble(%s): mark content entry as deleted=%v: %v", action, deleted, slug), &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  "scribble",
-			Email: "scribble@local",
-			When:  time.Now(),
-		},
-	})
+	_, err := wt.Commit(message, &git.CommitOptions{Author: author})
 	if err != nil {
-		return url, fmt.Errorf("failed to create commit: %w", err)
+		return fmt.Errorf("failed to create commit: %w", err)
 	}
 
 	if err := cs.repo.PushContext(ctx, &git.PushOptions{Auth: *cs.auth}); err != nil {
-		return url, fmt.Errorf("failed to push local: %w", err)
+		return fmt.Errorf("failed to push local: %w", err)
 	}
 
-	return url, nil
+	return nil
+}
+
+func (cs *GitContentStore) setDeletedStatus(ctx context.Context, url string, deleted bool) (string, error) {
+	slug, err := util.SlugFromURL(url)
+	if err != nil {
+		return url, err
+	}
+
+	action := "delete"
+	if !deleted {
+		action = "undelete"
+	}
+
+	message := fmt.Sprintf("scribble(%s): mark content entry as deleted=%v: %v", action, deleted, slug)
+	_, err = cs.rewriteDocument(ctx, slug, message, func(doc *util.Mf2Document) {
+		doc.Properties["deleted"] = []any{deleted}
+	})
+
+	return url, err
 }
 
 func (cs *GitContentStore) deleteValues(values []any, toRemove []any) []any {
@@ -517,17 +526,7 @@ func (cs *GitContentStore) ExistsBySlug(ctx context.Context, slug string) (bool,
 		return false, err
 	}
 
-	head, err := cs.repo.Head()
-	if err != nil {
-		return false, err
-	}
-
-	commit, err := cs.repo.CommitObject(head.Hash())
-	if err != nil {
-		return false, err
-	}
-
-	tree, err := commit.Tree()
+	tree, err := cs.headTree()
 	if err != nil {
 		return false, err
 	}
@@ -540,13 +539,101 @@ func (cs *GitContentStore) ExistsBySlug(ctx context.Context, slug string) (bool,
 		return false, err
 	}
 
+	err = cs.forEachDocument(tree, func(_ string, doc util.Mf2Document) error {
+		if strings.EqualFold(slug, documentSlug(doc)) {
+			return NoErrFound
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, NoErrFound) {
+		return true, nil
+	}
+
+	// err may be nil, meaning simply not found
+	return false, err
+}
+
+func (cs *GitContentStore) List(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if err := cs.fetchAndFastForward(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update repo from remote: %w", err)
+	}
+
+	tree, err := cs.headTree()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []util.Mf2Document
+	err = cs.forEachDocument(tree, func(name string, doc util.Mf2Document) error {
+		if !opts.IncludeDeleted && IsDeleted(doc) {
+			return nil
+		}
+
+		if !opts.IncludeDrafts && IsDraft(doc) {
+			return nil
+		}
+
+		if !opts.IncludeScheduled && IsScheduled(doc) {
+			return nil
+		}
+
+		if !opts.IncludePrivate && Visibility(doc) == VisibilityPrivate {
+			return nil
+		}
+
+		if opts.PostType != "" && !strings.EqualFold(opts.PostType, util.PostType(doc)) {
+			return nil
+		}
+
+		slug := documentSlug(doc)
+		if slug == "" {
+			slug = strings.TrimSuffix(filepath.Base(name), ".json")
+		}
+
+		if len(doc.Properties["url"]) == 0 {
+			doc.Properties["url"] = []any{cs.cfg.PublicUrl + "/" + slug}
+		}
+
+		items = append(items, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return PageDocuments(items, opts), nil
+}
+
+// headTree returns the tree of the current HEAD commit. The caller must hold cs.mu.
+func (cs *GitContentStore) headTree() (*object.Tree, error) {
+	head, err := cs.repo.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := cs.repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return commit.Tree()
+}
+
+// forEachDocument calls fn for every parseable JSON document beneath the configured content path.
+// Returning a non-nil error from fn stops the iteration and returns that error.
+func (cs *GitContentStore) forEachDocument(tree *object.Tree, fn func(name string, doc util.Mf2Document) error) error {
 	basePath := strings.TrimSuffix(cs.cfg.Path, "/") + "/"
-	err = tree.Files().ForEach(func(f *object.File) error {
+	return tree.Files().ForEach(func(f *object.File) error {
 		if !strings.HasPrefix(f.Name, basePath) {
 			return nil
 		}
 
-		if !strings.HasSuffix(f.Name, ".json") {
+		if !strings.HasSuffix(f.Name, ".json") || strings.HasSuffix(f.Name, mentionsSuffix) {
 			return nil
 		}
 
@@ -567,27 +654,50 @@ func (cs *GitContentStore) ExistsBySlug(ctx context.Context, slug string) (bool,
 			return nil
 		}
 
-		slugProps := doc.Properties["slug"]
-		if len(slugProps) == 0 {
-			return nil
+		if doc.Properties == nil {
+			doc.Properties = make(map[string][]any)
 		}
 
-		docSlug, ok := slugProps[0].(string)
-		if !ok {
-			return nil
-		}
+		return fn(f.Name, doc)
+	})
+}
 
-		if strings.EqualFold(slug, docSlug) {
-			return NoErrFound
+// documentSlug returns the first string value of the document's slug property, if any.
+func documentSlug(doc util.Mf2Document) string {
+	for _, v := range doc.Properties["slug"] {
+		if s, ok := v.(string); ok {
+			return s
 		}
+	}
 
-		return nil
-	})
+	return ""
+}
 
-	if errors.Is(err, NoErrFound) {
-		return true, nil
+func (cs *GitContentStore) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if err := cs.fetchAndFastForward(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update repo from remote: %w", err)
 	}
 
-	// err may be nil, meaning simply not found
-	return false, err
+	tree, err := cs.headTree()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := tree.File(filepath.ToSlash(filepath.Clean(path)))
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	r, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
 }
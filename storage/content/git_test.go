@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -181,6 +182,14 @@ func TestGitContentStore_Update(t *testing.T) {
 	if len(got.Properties["category"]) != 1 || got.Properties["category"][0] != "tech" {
 		t.Fatalf("category not added: %+v", got.Properties["category"])
 	}
+
+	_, updated, err := store.UpdateDocument(ctx, url, map[string][]any{"name": {"Again"}}, nil, nil)
+	if err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if updated == nil || updated.Properties["name"][0] != "Again" || len(updated.Properties["category"]) != 1 {
+		t.Fatalf("expected the updated document back, got %+v", updated)
+	}
 }
 
 func TestGitContentStore_DeleteUndelete(t *testing.T) {
@@ -269,3 +278,171 @@ func TestGitContentStore_ExistsBySlug(t *testing.T) {
 		t.Fatalf("expected missing slug to be false")
 	}
 }
+
+func TestGitContentStore_List(t *testing.T) {
+	store := newTestGitStore(t)
+	ctx := context.Background()
+
+	docs := []util.Mf2Document{
+		{Type: []string{"h-entry"}, Properties: map[string][]any{"slug": {"old"}, "content": {"old note"}, "published": {"2024-01-01T00:00:00Z"}}},
+		{Type: []string{"h-entry"}, Properties: map[string][]any{"slug": {"new"}, "name": {"Article"}, "content": {"body"}, "published": {"2024-03-01T00:00:00Z"}}},
+		{Type: []string{"h-entry"}, Properties: map[string][]any{"slug": {"mid"}, "content": {"mid note"}, "published": {"2024-02-01T00:00:00Z"}}},
+		{Type: []string{"h-entry"}, Properties: map[string][]any{"slug": {"gone"}, "content": {"gone"}, "published": {"2024-04-01T00:00:00Z"}}},
+		{Type: []string{"h-entry"}, Properties: map[string][]any{"slug": {"draft"}, "content": {"draft"}, "post-status": {"draft"}, "published": {"2024-05-01T00:00:00Z"}}},
+	}
+	for _, doc := range docs {
+		if _, _, err := store.Create(ctx, doc); err != nil {
+			t.Fatalf("create failed: %v", err)
+		}
+	}
+	if err := store.Delete(ctx, "https://example.test/gone"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	page, err := store.List(ctx, ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(page.Items) != 2 || page.Items[0].Properties["url"][0] != "https://example.test/new" || page.Items[1].Properties["url"][0] != "https://example.test/mid" {
+		t.Fatalf("unexpected first page: %+v", page.Items)
+	}
+	if page.After != "https://example.test/mid" {
+		t.Fatalf("unexpected cursor %q", page.After)
+	}
+
+	next, err := store.List(ctx, ListOptions{Limit: 2, After: page.After})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(next.Items) != 1 || next.Items[0].Properties["slug"][0] != "old" || next.After != "" {
+		t.Fatalf("unexpected second page: %+v", next)
+	}
+
+	notes, err := store.List(ctx, ListOptions{PostType: "note"})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(notes.Items) != 2 {
+		t.Fatalf("expected two notes, got %d", len(notes.Items))
+	}
+
+	all, err := store.List(ctx, ListOptions{IncludeDeleted: true, Offset: 1})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(all.Items) != 3 {
+		t.Fatalf("expected deleted post to be included, got %d items", len(all.Items))
+	}
+
+	drafts, err := store.List(ctx, ListOptions{IncludeDrafts: true, Limit: 1})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(drafts.Items) != 1 || drafts.Items[0].Properties["slug"][0] != "draft" {
+		t.Fatalf("expected draft to be included, got %+v", drafts.Items)
+	}
+}
+
+func TestGitContentStore_ReadFile(t *testing.T) {
+	store := newTestGitStore(t)
+	ctx := context.Background()
+
+	data, err := store.ReadFile(ctx, "README.md")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(data) != "init\n" {
+		t.Fatalf("unexpected contents %q", data)
+	}
+
+	if _, err := store.ReadFile(ctx, "missing.json"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGitContentStore_PathResolverPlacesAndMoves(t *testing.T) {
+	store := newTestGitStore(t)
+	ctx := context.Background()
+
+	store.SetPathResolver(func(_ context.Context, doc util.Mf2Document) string {
+		if ch, ok := doc.Properties["channel"]; ok && len(ch) > 0 {
+			return "channels/" + ch[0].(string)
+		}
+		return ""
+	})
+
+	doc := util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"slug":    {"routed"},
+		"content": {"hello"},
+		"channel": {"notes"},
+	}}
+
+	url, _, err := store.Create(ctx, doc)
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	tree := func() *object.Tree {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		tree, err := store.headTree()
+		if err != nil {
+			t.Fatalf("failed to read head: %v", err)
+		}
+		return tree
+	}
+
+	if _, err := tree().File("content/channels/notes/routed.json"); err != nil {
+		t.Fatalf("expected document in channel directory: %v", err)
+	}
+
+	if _, err := store.Update(ctx, url, map[string][]any{"channel": {"photos"}}, nil, nil); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	if _, err := tree().File("content/channels/notes/routed.json"); err == nil {
+		t.Fatalf("expected old file to be removed after the move")
+	}
+	if _, err := tree().File("content/channels/photos/routed.json"); err != nil {
+		t.Fatalf("expected document in new channel directory: %v", err)
+	}
+
+	got, err := store.Get(ctx, url)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if got.Properties["channel"][0] != "photos" {
+		t.Fatalf("unexpected channel %v", got.Properties["channel"])
+	}
+
+	exists, err := store.ExistsBySlug(ctx, "routed")
+	if err != nil || !exists {
+		t.Fatalf("expected slug in subdirectory to exist, got %v %v", exists, err)
+	}
+}
+
+func TestGitContentStore_RecordsCommitter(t *testing.T) {
+	store := newTestGitStore(t)
+	ctx := WithCommitter(context.Background(), Committer{Name: "Alice", Email: "alice@example.org", Me: "https://alice.example/"})
+
+	doc := util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"slug": {"by-alice"}, "content": {"hi"}}}
+	if _, _, err := store.Create(ctx, doc); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	head, err := store.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := store.repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if commit.Author.Name != "Alice" || commit.Author.Email != "alice@example.org" {
+		t.Fatalf("expected the commit to be authored by Alice, got %v", commit.Author)
+	}
+	if !strings.Contains(commit.Message, "Micropub-Author: https://alice.example/") {
+		t.Fatalf("expected the commit message to name the author, got %q", commit.Message)
+	}
+}
@@ -0,0 +1,114 @@
+package content
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/indieinfra/scribble/server/util"
+)
+
+// IsDeleted reports whether the document has been marked deleted=true.
+func IsDeleted(doc util.Mf2Document) bool {
+	for _, v := range doc.Properties["deleted"] {
+		if b, ok := v.(bool); ok && b {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsDraft reports whether the document has post-status draft.
+func IsDraft(doc util.Mf2Document) bool {
+	return hasPostStatus(doc, "draft")
+}
+
+// IsScheduled reports whether the document has post-status scheduled, meaning it is held back until
+// its published date.
+func IsScheduled(doc util.Mf2Document) bool {
+	return hasPostStatus(doc, "scheduled")
+}
+
+// Visibilities a post may have. Posts without a visibility property are public.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Visibility returns the document's visibility, lower-cased, or VisibilityPublic if it has none.
+func Visibility(doc util.Mf2Document) string {
+	for _, v := range doc.Properties["visibility"] {
+		if s, ok := v.(string); ok && s != "" {
+			return strings.ToLower(s)
+		}
+	}
+
+	return VisibilityPublic
+}
+
+func hasPostStatus(doc util.Mf2Document, status string) bool {
+	for _, v := range doc.Properties["post-status"] {
+		if s, ok := v.(string); ok && strings.EqualFold(s, status) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// documentUrl returns the first string value of the document's url property, if any.
+func documentUrl(doc util.Mf2Document) string {
+	for _, v := range doc.Properties["url"] {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// PageDocuments orders documents newest first by their published date and applies the paging
+// options. Documents without a parseable published date sort after dated ones, ordered by URL.
+// It is intended for stores that implement Lister by loading every matching document.
+func PageDocuments(items []util.Mf2Document, opts ListOptions) *ListResult {
+	sort.SliceStable(items, func(i, j int) bool {
+		ti, iok := util.PropertyTime(items[i], "published")
+		tj, jok := util.PropertyTime(items[j], "published")
+		switch {
+		case iok && jok && !ti.Equal(tj):
+			return ti.After(tj)
+		case iok != jok:
+			return iok
+		default:
+			return documentUrl(items[i]) < documentUrl(items[j])
+		}
+	})
+
+	if opts.After != "" {
+		start := len(items)
+		for i, doc := range items {
+			if documentUrl(doc) == opts.After {
+				start = i + 1
+				break
+			}
+		}
+		items = items[start:]
+	}
+
+	if opts.Offset > 0 {
+		items = items[min(opts.Offset, len(items)):]
+	}
+
+	result := &ListResult{Items: items}
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		result.Items = items[:opts.Limit]
+		result.After = documentUrl(result.Items[len(result.Items)-1])
+	}
+
+	if result.Items == nil {
+		result.Items = []util.Mf2Document{}
+	}
+
+	return result
+}
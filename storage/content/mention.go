@@ -0,0 +1,187 @@
+package content
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/indieinfra/scribble/server/util"
+)
+
+// mentionsSuffix names the sidecar file holding the mentions of a document, next to the document:
+// posts/hello.json keeps its mentions in posts/hello.mentions.json.
+const mentionsSuffix = ".mentions.json"
+
+func mentionsPath(docPath string) string {
+	return strings.TrimSuffix(docPath, ".json") + mentionsSuffix
+}
+
+func (cs *GitContentStore) SaveMention(ctx context.Context, m Mention) error {
+	return cs.changeMentions(ctx, m.Target, func(mentions []Mention) ([]Mention, string, error) {
+		i := slices.IndexFunc(mentions, func(e Mention) bool { return e.Source == m.Source })
+		if i >= 0 {
+			mentions[i] = m
+		} else {
+			mentions = append(mentions, m)
+		}
+
+		return mentions, fmt.Sprintf("scribble(webmention): %s %s from %s", m.Status, m.Type, m.Source), nil
+	})
+}
+
+func (cs *GitContentStore) DeleteMention(ctx context.Context, target string, source string) error {
+	return cs.changeMentions(ctx, target, func(mentions []Mention) ([]Mention, string, error) {
+		i := slices.IndexFunc(mentions, func(e Mention) bool { return e.Source == source })
+		if i < 0 {
+			return nil, "", ErrNotFound
+		}
+
+		return slices.Delete(mentions, i, i+1), fmt.Sprintf("scribble(webmention): remove mention from %s", source), nil
+	})
+}
+
+func (cs *GitContentStore) Mentions(ctx context.Context, filter MentionFilter) ([]Mention, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if err := cs.fetchAndFastForward(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update repo from remote: %w", err)
+	}
+
+	tree, err := cs.headTree()
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Mention
+	if filter.Target != "" {
+		slug, err := util.SlugFromURL(filter.Target)
+		if err != nil {
+			return nil, err
+		}
+
+		docPath := cs.locateDocument(tree, slug)
+		if docPath == "" {
+			return nil, ErrNotFound
+		}
+
+		if all, err = readMentions(tree, mentionsPath(docPath)); err != nil {
+			return nil, err
+		}
+	} else {
+		basePath := strings.TrimSuffix(cs.cfg.Path, "/") + "/"
+		err = tree.Files().ForEach(func(f *object.File) error {
+			if !strings.HasPrefix(f.Name, basePath) || !strings.HasSuffix(f.Name, mentionsSuffix) {
+				return nil
+			}
+
+			mentions, err := readMentions(tree, f.Name)
+			if err != nil {
+				return err
+			}
+			all = append(all, mentions...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]Mention, 0, len(all))
+	for _, m := range all {
+		if filter.Status == "" || m.Status == filter.Status {
+			out = append(out, m)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Mention) int { return a.Received.Compare(b.Received) })
+
+	return out, nil
+}
+
+// changeMentions applies change to the mentions of the document at target and commits the result.
+// The sidecar file is removed once the last mention is gone.
+func (cs *GitContentStore) changeMentions(ctx context.Context, target string, change func([]Mention) ([]Mention, string, error)) error {
+	slug, err := util.SlugFromURL(target)
+	if err != nil {
+		return err
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if err := cs.fetchAndFastForward(ctx); err != nil {
+		return fmt.Errorf("failed to update repo from remote: %w", err)
+	}
+
+	tree, err := cs.headTree()
+	if err != nil {
+		return err
+	}
+
+	docPath := cs.locateDocument(tree, slug)
+	if docPath == "" {
+		return ErrNotFound
+	}
+
+	path := mentionsPath(docPath)
+	mentions, err := readMentions(tree, path)
+	if err != nil {
+		return err
+	}
+
+	mentions, message, err := change(mentions)
+	if err != nil {
+		return err
+	}
+
+	if len(mentions) == 0 {
+		wt, err := cs.repo.Worktree()
+		if err != nil {
+			return fmt.Errorf("failed to get worktree: %w", err)
+		}
+		if _, err := wt.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove file from git: %w", err)
+		}
+		return cs.commitAndPush(ctx, wt, message)
+	}
+
+	data, err := json.MarshalIndent(mentions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return cs.commitDocument(ctx, "", path, data, message)
+}
+
+func readMentions(tree *object.Tree, path string) ([]Mention, error) {
+	file, err := tree.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var mentions []Mention
+	if err := json.Unmarshal(data, &mentions); err != nil {
+		return nil, fmt.Errorf("failed to decode mentions in %q: %w", path, err)
+	}
+
+	return mentions, nil
+}
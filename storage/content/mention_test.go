@@ -0,0 +1,88 @@
+package content
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/indieinfra/scribble/server/util"
+)
+
+func TestGitContentStore_Mentions(t *testing.T) {
+	store := newTestGitStore(t)
+	ctx := context.Background()
+
+	store.SetPathResolver(func(_ context.Context, doc util.Mf2Document) string {
+		if ch, ok := doc.Properties["channel"]; ok && len(ch) > 0 {
+			return ch[0].(string)
+		}
+		return ""
+	})
+
+	url, _, err := store.Create(ctx, util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"slug":    {"mentioned"},
+		"content": {"hello"},
+		"channel": {"notes"},
+	}})
+	if err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	like := Mention{Source: "https://a.example/like", Target: url, Type: "like", Status: "approved", Received: now}
+	reply := Mention{Source: "https://b.example/reply", Target: url, Type: "reply", Status: "pending", Content: "Nice", Received: now.Add(time.Minute)}
+
+	for _, m := range []Mention{like, reply} {
+		if err := store.SaveMention(ctx, m); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+	}
+
+	if err := store.SaveMention(ctx, Mention{Source: "https://c.example/", Target: "https://example.test/missing"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound for a missing target, got %v", err)
+	}
+
+	got, err := store.Mentions(ctx, MentionFilter{Target: url})
+	if err != nil {
+		t.Fatalf("mentions failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Source != like.Source || got[1].Content != "Nice" {
+		t.Fatalf("unexpected mentions %+v", got)
+	}
+
+	// Saving the same source again replaces the mention.
+	reply.Status = "approved"
+	if err := store.SaveMention(ctx, reply); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+	pending, err := store.Mentions(ctx, MentionFilter{Status: "pending"})
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("expected no pending mentions, got %+v %v", pending, err)
+	}
+
+	// The sidecar must not show up as a document, and must follow its document when it moves.
+	list, err := store.List(ctx, ListOptions{})
+	if err != nil || len(list.Items) != 1 {
+		t.Fatalf("expected only the post to be listed, got %+v %v", list, err)
+	}
+	if _, err := store.Update(ctx, url, map[string][]any{"channel": {"articles"}}, nil, nil); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	if got, err := store.Mentions(ctx, MentionFilter{Target: url}); err != nil || len(got) != 2 {
+		t.Fatalf("expected mentions to move with the post, got %+v %v", got, err)
+	}
+
+	if err := store.DeleteMention(ctx, url, like.Source); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if err := store.DeleteMention(ctx, url, like.Source); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting twice, got %v", err)
+	}
+	if err := store.DeleteMention(ctx, url, reply.Source); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if got, err := store.Mentions(ctx, MentionFilter{}); err != nil || len(got) != 0 {
+		t.Fatalf("expected no mentions left, got %+v %v", got, err)
+	}
+}
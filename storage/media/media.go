@@ -11,3 +11,10 @@ type MediaStore interface {
 	Upload(ctx context.Context, file *multipart.File, header *multipart.FileHeader) (string, error)
 	Delete(ctx context.Context, url string) error
 }
+
+// Owner is an optional interface for media stores that can tell their own URLs apart from media
+// hosted elsewhere.
+type Owner interface {
+	// function Owns reports whether url points at media kept in this store.
+	Owns(url string) bool
+}
@@ -119,6 +119,11 @@ func (s *S3MediaStore) Delete(ctx context.Context, urlStr string) error {
 	return nil
 }
 
+// Owns reports whether url is one this store hands out for uploaded media.
+func (s *S3MediaStore) Owns(url string) bool {
+	return strings.HasPrefix(url, s.objectURL(""))
+}
+
 func (s *S3MediaStore) objectKey(filename string) string {
 	name := path.Base(filename)
 	if name == "." || name == "" {
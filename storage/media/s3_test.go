@@ -43,3 +43,14 @@ func TestS3MediaStore_keyFromURL(t *testing.T) {
 		}
 	}
 }
+
+func TestS3MediaStore_Owns(t *testing.T) {
+	store := &S3MediaStore{bucket: "bucket", endpointHost: "s3.example.com", secure: true, publicBase: "https://media.example.org"}
+
+	if !store.Owns("https://media.example.org/2025/01/01/photo.jpg") {
+		t.Fatalf("expected store to own its own media")
+	}
+	if store.Owns("https://elsewhere.example/photo.jpg") || store.Owns("https://media.example.org.evil/photo.jpg") {
+		t.Fatalf("expected store not to own foreign media")
+	}
+}
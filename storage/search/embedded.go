@@ -0,0 +1,264 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+const (
+	// maxIndexedWords caps how much text of a single property is indexed.
+	maxIndexedWords = 10_000
+	snippetWords    = 30
+)
+
+// fieldWeights lists the indexed properties and how much a matching term in each contributes to the score.
+var fieldWeights = map[string]float64{
+	"name":     3,
+	"summary":  2,
+	"category": 2,
+	"content":  1,
+}
+
+type indexedDoc struct {
+	Result
+	Terms map[string]float64 `json:"terms"`
+}
+
+// EmbeddedIndex is an in-process inverted index. When a path is set, the indexed documents are
+// persisted to that file after every change and loaded back by NewEmbeddedIndex.
+type EmbeddedIndex struct {
+	mu       sync.RWMutex
+	path     string
+	docs     map[string]*indexedDoc
+	postings map[string]map[string]float64
+}
+
+func NewEmbeddedIndex(path string) (*EmbeddedIndex, error) {
+	idx := &EmbeddedIndex{
+		path:     path,
+		docs:     map[string]*indexedDoc{},
+		postings: map[string]map[string]float64{},
+	}
+
+	if path == "" {
+		return idx, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return idx, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read search index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &idx.docs); err != nil {
+		return nil, fmt.Errorf("failed to parse search index %q: %w", path, err)
+	}
+
+	for _, doc := range idx.docs {
+		idx.addPostings(doc)
+	}
+
+	return idx, nil
+}
+
+// Persistent reports whether the index is backed by a file.
+func (idx *EmbeddedIndex) Persistent() bool {
+	return idx.path != ""
+}
+
+// Len returns the number of indexed documents.
+func (idx *EmbeddedIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+func (idx *EmbeddedIndex) Index(ctx context.Context, url string, doc util.Mf2Document) error {
+	if content.IsDeleted(doc) {
+		return idx.Remove(ctx, url)
+	}
+
+	entry := &indexedDoc{
+		Result: Result{
+			Url:       url,
+			Type:      doc.Type,
+			Name:      util.PropertyText(doc.Properties["name"], maxIndexedWords),
+			Published: util.PropertyText(doc.Properties["published"], 1),
+		},
+		Terms: map[string]float64{},
+	}
+
+	for field, weight := range fieldWeights {
+		for _, term := range Tokenize(util.PropertyText(doc.Properties[field], maxIndexedWords)) {
+			entry.Terms[term] += weight
+		}
+	}
+
+	entry.Snippet = util.PropertyText(doc.Properties["summary"], snippetWords)
+	if entry.Snippet == "" {
+		entry.Snippet = util.PropertyText(doc.Properties["content"], snippetWords)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removePostings(url)
+	idx.docs[url] = entry
+	idx.addPostings(entry)
+
+	return idx.save()
+}
+
+func (idx *EmbeddedIndex) Remove(ctx context.Context, url string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.docs[url]; !ok {
+		return nil
+	}
+
+	idx.removePostings(url)
+	delete(idx.docs, url)
+
+	return idx.save()
+}
+
+func (idx *EmbeddedIndex) Reset(ctx context.Context) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.docs = map[string]*indexedDoc{}
+	idx.postings = map[string]map[string]float64{}
+
+	return idx.save()
+}
+
+func (idx *EmbeddedIndex) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	terms := Tokenize(query)
+	if len(terms) == 0 {
+		return []Result{}, nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Start from the rarest term so the intersection stays small.
+	sort.Slice(terms, func(i, j int) bool { return len(idx.postings[terms[i]]) < len(idx.postings[terms[j]]) })
+
+	scores := map[string]float64{}
+	for url, weight := range idx.postings[terms[0]] {
+		scores[url] = weight
+	}
+
+	for _, term := range terms[1:] {
+		postings := idx.postings[term]
+		for url := range scores {
+			weight, ok := postings[url]
+			if !ok {
+				delete(scores, url)
+				continue
+			}
+			scores[url] += weight
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for url, score := range scores {
+		r := idx.docs[url].Result
+		r.Score = score
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Published != results[j].Published {
+			return results[i].Published > results[j].Published
+		}
+		return results[i].Url < results[j].Url
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func (idx *EmbeddedIndex) addPostings(doc *indexedDoc) {
+	for term, weight := range doc.Terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = map[string]float64{}
+		}
+		idx.postings[term][doc.Url] = weight
+	}
+}
+
+func (idx *EmbeddedIndex) removePostings(url string) {
+	doc, ok := idx.docs[url]
+	if !ok {
+		return
+	}
+
+	for term := range doc.Terms {
+		delete(idx.postings[term], url)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+}
+
+// save writes the index to its file, if any. The caller must hold idx.mu.
+func (idx *EmbeddedIndex) save() error {
+	if idx.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(idx.docs)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return fmt.Errorf("failed to create search index directory: %w", err)
+	}
+
+	tmp := idx.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write search index: %w", err)
+	}
+
+	return os.Rename(tmp, idx.path)
+}
+
+// Tokenize lower-cases the text and splits it into terms of at least two letters or digits.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	seen := map[string]bool{}
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if len([]rune(f)) < 2 || seen[f] {
+			continue
+		}
+		seen[f] = true
+		terms = append(terms, f)
+	}
+
+	return terms
+}
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+func TestEmbeddedIndex_SearchRanking(t *testing.T) {
+	idx, err := NewEmbeddedIndex("")
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	ctx := context.Background()
+
+	_ = idx.Index(ctx, "https://example.org/titled", util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"name":    {"Go generics"},
+		"content": {map[string]any{"html": "<p>Thoughts on <b>generics</b> in Go</p>"}},
+	}})
+	_ = idx.Index(ctx, "https://example.org/note", util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"content":  {"I finally tried generics today"},
+		"category": {"go"},
+	}})
+	_ = idx.Index(ctx, "https://example.org/other", util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{
+		"content": {"nothing to see"},
+	}})
+
+	results, err := idx.Search(ctx, "go GENERICS", 10)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected two results, got %+v", results)
+	}
+	if results[0].Url != "https://example.org/titled" {
+		t.Fatalf("expected name match to rank first, got %+v", results)
+	}
+	if results[0].Snippet != "Thoughts on generics in Go" {
+		t.Fatalf("unexpected snippet %q", results[0].Snippet)
+	}
+
+	if results, _ := idx.Search(ctx, "generics nothing", 10); len(results) != 0 {
+		t.Fatalf("expected all terms to be required, got %+v", results)
+	}
+}
+
+func TestEmbeddedIndex_UpdateRemoveAndPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index", "search.json")
+	idx, err := NewEmbeddedIndex(path)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+	ctx := context.Background()
+
+	url := "https://example.org/post"
+	_ = idx.Index(ctx, url, util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"old words"}}})
+	_ = idx.Index(ctx, url, util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"new words"}}})
+
+	if results, _ := idx.Search(ctx, "old", 10); len(results) != 0 {
+		t.Fatalf("expected replaced terms to be dropped")
+	}
+
+	reloaded, err := NewEmbeddedIndex(path)
+	if err != nil {
+		t.Fatalf("failed to reload index: %v", err)
+	}
+	if results, _ := reloaded.Search(ctx, "new", 10); len(results) != 1 {
+		t.Fatalf("expected persisted document to be found after reload")
+	}
+
+	if err := idx.Index(ctx, url, util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"new words"}, "deleted": {true}}}); err != nil {
+		t.Fatalf("index failed: %v", err)
+	}
+	if idx.Len() != 0 {
+		t.Fatalf("expected deleted document to be removed")
+	}
+}
+
+type stubLister struct{ items []util.Mf2Document }
+
+func (s *stubLister) List(context.Context, content.ListOptions) (*content.ListResult, error) {
+	return &content.ListResult{Items: s.items}, nil
+}
+
+func TestRebuild(t *testing.T) {
+	idx, _ := NewEmbeddedIndex("")
+	ctx := context.Background()
+	_ = idx.Index(ctx, "https://example.org/stale", util.Mf2Document{Type: []string{"h-entry"}, Properties: map[string][]any{"content": {"stale"}}})
+
+	lister := &stubLister{items: []util.Mf2Document{
+		{Type: []string{"h-entry"}, Properties: map[string][]any{"url": {"https://example.org/fresh"}, "content": {"fresh"}}},
+	}}
+
+	n, err := Rebuild(ctx, idx, lister)
+	if err != nil || n != 1 {
+		t.Fatalf("rebuild returned %d, %v", n, err)
+	}
+	if results, _ := idx.Search(ctx, "stale", 10); len(results) != 0 {
+		t.Fatalf("expected rebuild to drop stale documents")
+	}
+	if results, _ := idx.Search(ctx, "fresh", 10); len(results) != 1 || results[0].Url != "https://example.org/fresh" {
+		t.Fatalf("expected rebuilt document to be searchable, got %+v", results)
+	}
+}
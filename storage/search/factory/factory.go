@@ -0,0 +1,57 @@
+package factory
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/indieinfra/scribble/config"
+	"github.com/indieinfra/scribble/storage/search"
+)
+
+// Factory builds a search index for the provided search config. A factory may return a nil
+// index to signal that search is disabled.
+type Factory func(*config.Search) (search.Index, error)
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Factory{}
+)
+
+// Register adds or replaces a search index factory for the given strategy name.
+func Register(strategy string, factory Factory) {
+	mu.Lock()
+	registry[strategy] = factory
+	mu.Unlock()
+}
+
+// Get retrieves a factory for the given strategy.
+func Get(strategy string) (Factory, bool) {
+	mu.RLock()
+	f, ok := registry[strategy]
+	mu.RUnlock()
+	return f, ok
+}
+
+// Create builds a search index using the registered factory for the configured strategy.
+func Create(cfg *config.Search) (search.Index, error) {
+	if f, ok := Get(cfg.Strategy); ok {
+		return f(cfg)
+	}
+
+	return nil, fmt.Errorf("unknown search strategy %q", cfg.Strategy)
+}
+
+func init() {
+	disabled := func(cfg *config.Search) (search.Index, error) {
+		return nil, nil
+	}
+	Register("", disabled)
+	Register("none", disabled)
+	Register("embedded", func(cfg *config.Search) (search.Index, error) {
+		path := ""
+		if cfg.Embedded != nil {
+			path = cfg.Embedded.Path
+		}
+		return search.NewEmbeddedIndex(path)
+	})
+}
@@ -0,0 +1,55 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/indieinfra/scribble/server/util"
+	"github.com/indieinfra/scribble/storage/content"
+)
+
+// Result is a single search hit.
+type Result struct {
+	Url       string   `json:"url"`
+	Type      []string `json:"type"`
+	Name      string   `json:"name,omitempty"`
+	Snippet   string   `json:"snippet,omitempty"`
+	Published string   `json:"published,omitempty"`
+	Score     float64  `json:"score"`
+}
+
+type Index interface {
+	// function Index adds or replaces the document stored at url in the index.
+	Index(ctx context.Context, url string, doc util.Mf2Document) error
+
+	// function Remove drops the document stored at url from the index. Removing an unknown url is not an error.
+	Remove(ctx context.Context, url string) error
+
+	// function Search returns at most limit documents matching every term of the query, best match first.
+	Search(ctx context.Context, query string, limit int) ([]Result, error)
+
+	// function Reset removes every document from the index.
+	Reset(ctx context.Context) error
+}
+
+// Rebuild resets the index and re-indexes every non-deleted document held by the content store.
+// It returns the number of indexed documents.
+func Rebuild(ctx context.Context, idx Index, lister content.Lister) (int, error) {
+	result, err := lister.List(ctx, content.ListOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list content: %w", err)
+	}
+
+	if err := idx.Reset(ctx); err != nil {
+		return 0, err
+	}
+
+	for _, doc := range result.Items {
+		url := util.PropertyText(doc.Properties["url"], 1)
+		if err := idx.Index(ctx, url, doc); err != nil {
+			return 0, fmt.Errorf("failed to index %q: %w", url, err)
+		}
+	}
+
+	return len(result.Items), nil
+}